@@ -0,0 +1,128 @@
+package corefx
+
+import (
+	"fmt"
+	"github.com/getsentry/sentry-go"
+	"go.uber.org/fx"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	// BannerLog log the banner as a single record at info level.
+	BannerLog = "log"
+	// BannerPretty print the banner as text on stderr.
+	BannerPretty = "pretty"
+	// BannerOff disable the banner.
+	BannerOff = "off"
+)
+
+// BannerConfig optional interface that a CoreConfig can implement to control the banner printed when the app started.
+type BannerConfig interface {
+	// BannerValue "log", "pretty" or "off".
+	// Empty to print pretty banner in development and debug profiles with console logs, and log it otherwise.
+	BannerValue() string
+}
+
+type BannerEnv struct {
+	Banner string `json:"banner" mapstructure:"banner"`
+}
+
+func (e BannerEnv) BannerValue() string {
+	return e.Banner
+}
+
+var _ BannerConfig = (*BannerEnv)(nil)
+
+type bannerParams struct {
+	fx.In
+	Config CoreConfig
+	Logger *slog.Logger
+	Report *LifecycleReport
+	Sentry *sentry.Hub
+	Output logOutput `optional:"true"`
+}
+
+// isConsoleLogOutput whether logs of cfg are written to the stderr console.
+func isConsoleLogOutput(cfg CoreConfig) bool {
+	if isStructuredLogFormat(cfg.LogFormatValue()) {
+		return false
+	}
+	c, ok := cfg.(LogOutputConfig)
+	if !ok {
+		return true
+	}
+	switch c.LogOutputValue() {
+	case "", LogOutputStderr:
+		return true
+	case LogOutputStdout, LogOutputDiscard:
+		return false
+	}
+	// Other outputs are files, which are written alongside the console if the config is a LogFileConfig.
+	_, ok = cfg.(LogFileConfig)
+	return ok
+}
+
+// registerBanner print the banner of the app once it started: app name, version, profile, config locations, log level
+// and enabled corefx modules.
+func registerBanner(p bannerParams) {
+	mode := ""
+	if c, ok := p.Config.(BannerConfig); ok {
+		mode = c.BannerValue()
+	}
+	if mode == "" {
+		mode = BannerLog
+		profile := p.Config.ProfileValue()
+		if (profile == ProfileDevelopment || profile == ProfileDebug) && isConsoleLogOutput(p.Config) && p.Output.Handler == nil {
+			mode = BannerPretty
+		}
+	}
+	if mode == BannerOff {
+		return
+	}
+
+	p.Report.onStarted(func(modules []string, startup time.Duration) {
+		if p.Sentry.Client() != nil {
+			modules = append(modules, "sentry")
+		}
+		// Ignore the error, the config was loaded from these locations.
+		locations, _ := configLocationsOf(p.Config)
+		if mode == BannerPretty {
+			writeBanner(os.Stderr, p.Config, locations, modules, startup)
+			return
+		}
+		// The app, version and profile attributes are added by the logger.
+		p.Logger.Info("Started app",
+			slog.Duration("startup", startup),
+			slog.String("config", strings.Join(locations, ",")),
+			slog.String("log_level", logLevelOf(p.Config).String()),
+			slog.String("modules", strings.Join(modules, ",")))
+	})
+}
+
+// writeBanner write the pretty banner into w.
+func writeBanner(w io.Writer, cfg CoreConfig, locations []string, modules []string, startup time.Duration) {
+	name := cfg.AppNameValue()
+	if name == "" {
+		name = "app"
+	}
+	var b strings.Builder
+	b.WriteString("\n  " + name)
+	if cfg.AppVersionValue() != "" {
+		b.WriteString(" " + cfg.AppVersionValue())
+	}
+	if cfg.ProfileValue() != "" {
+		b.WriteString(" (" + cfg.ProfileValue() + ")")
+	}
+	b.WriteString(" started in " + startup.Round(time.Millisecond).String() + "\n")
+	fmt.Fprintf(&b, "    config:  %s\n", strings.Join(locations, ", "))
+	fmt.Fprintf(&b, "    log:     %s\n", strings.ToLower(logLevelOf(cfg).String()))
+	if len(modules) > 0 {
+		fmt.Fprintf(&b, "    modules: %s\n", strings.Join(modules, ", "))
+	}
+	b.WriteString("\n")
+	_, _ = io.WriteString(w, b.String())
+}
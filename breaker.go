@@ -0,0 +1,298 @@
+package corefx
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// breakerStats expvar stats of circuit breakers, served on /debug/vars of the debug server.
+// Keys are <breaker>.state, <breaker>.opens, <breaker>.failures and <breaker>.rejected.
+var breakerStats = expvar.NewMap("corefx_breaker")
+
+// ErrBreakerOpen returned by Breaker when calls are rejected because the breaker is open.
+var ErrBreakerOpen = errors.New("error circuit breaker is open")
+
+// BreakerState state of a Breaker.
+type BreakerState int
+
+const (
+	// BreakerClosed calls are allowed.
+	BreakerClosed BreakerState = iota
+	// BreakerOpen calls are rejected until the open timeout elapse.
+	BreakerOpen
+	// BreakerHalfOpen a limited number of trial calls are allowed, which close the breaker if they succeed.
+	BreakerHalfOpen
+)
+
+func (s BreakerState) String() string {
+	switch s {
+	case BreakerClosed:
+		return "closed"
+	case BreakerOpen:
+		return "open"
+	case BreakerHalfOpen:
+		return "half-open"
+	default:
+		return fmt.Sprintf("BreakerState(%d)", int(s))
+	}
+}
+
+// BreakerConfig optional interface that a HTTPClientConfig can implement to protect the hosts called by the http
+// client using circuit breakers, see Breaker.
+type BreakerConfig interface {
+	BreakerValue() BreakerOptions
+}
+
+// BreakerOptions the circuit_breaker config section. Zero values are replaced by their defaults.
+type BreakerOptions struct {
+	// FailureThreshold consecutive failures that open the breaker, zero means 5.
+	FailureThreshold int `json:"failure_threshold" mapstructure:"failure_threshold" default:"5"`
+	// OpenTimeout duration the breaker stay open before allowing trial calls, zero means 30s.
+	OpenTimeout time.Duration `json:"open_timeout" mapstructure:"open_timeout" default:"30s"`
+	// HalfOpenCalls number of trial calls allowed when half-open, the breaker close once they all succeed.
+	// Zero means 1.
+	HalfOpenCalls int `json:"half_open_calls" mapstructure:"half_open_calls" default:"1"`
+	// Logger log the state changes, nil means slog.Default.
+	Logger *slog.Logger `json:"-" mapstructure:"-"`
+	// Clock measure the open timeout, nil means the real clock.
+	Clock Clock `json:"-" mapstructure:"-"`
+}
+
+// normalized return the options with the zero values replaced by their defaults.
+func (o BreakerOptions) normalized() BreakerOptions {
+	if o.FailureThreshold <= 0 {
+		o.FailureThreshold = 5
+	}
+	if o.OpenTimeout <= 0 {
+		o.OpenTimeout = 30 * time.Second
+	}
+	if o.HalfOpenCalls <= 0 {
+		o.HalfOpenCalls = 1
+	}
+	if o.Logger == nil {
+		o.Logger = slog.Default()
+	}
+	if o.Clock == nil {
+		o.Clock = RealClock()
+	}
+	return o
+}
+
+type BreakerEnv struct {
+	Breaker BreakerOptions `json:"circuit_breaker" mapstructure:"circuit_breaker"`
+}
+
+func (e BreakerEnv) BreakerValue() BreakerOptions {
+	return e.Breaker
+}
+
+var _ BreakerConfig = (*BreakerEnv)(nil)
+
+// Breaker a circuit breaker, which reject calls for a while after consecutive failures so a failing dependency can
+// recover, instead of piling up calls waiting for timeouts.
+// State changes are logged, and the state and counters are published as the corefx_breaker expvar.
+type Breaker struct {
+	name    string
+	options BreakerOptions
+	// published state and opening time in unix nanoseconds, read by the expvar, which must not lock mu as the
+	// stats are updated while holding it.
+	published         atomic.Int32
+	publishedOpenedAt atomic.Int64
+
+	mu        sync.Mutex
+	state     BreakerState
+	failures  int
+	openedAt  time.Time
+	trials    int
+	successes int
+}
+
+// NewBreaker create a closed breaker named name, which identify it in logs and stats.
+func NewBreaker(name string, options BreakerOptions) *Breaker {
+	b := &Breaker{name: name, options: options.normalized()}
+	breakerStats.Set(name+".state", breakerStateVar{b})
+	return b
+}
+
+// Name return the name of the breaker.
+func (b *Breaker) Name() string {
+	return b.name
+}
+
+// State return the current state of the breaker.
+func (b *Breaker) State() BreakerState {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.expire()
+	return b.state
+}
+
+// Check return ErrBreakerOpen if the breaker is open, for example to report the dependency as unhealthy.
+func (b *Breaker) Check(_ context.Context) error {
+	if b.State() == BreakerOpen {
+		return fmt.Errorf("%w [%s]", ErrBreakerOpen, b.name)
+	}
+	return nil
+}
+
+// Do call f if allowed, recording its error as a failure. Return ErrBreakerOpen without calling f if rejected.
+// Errors caused by the cancellation of ctx are not recorded as failures.
+func (b *Breaker) Do(ctx context.Context, f func(ctx context.Context) error) error {
+	done, err := b.Allow()
+	if err != nil {
+		return err
+	}
+	err = f(ctx)
+	done(err == nil || ctx.Err() != nil)
+	return err
+}
+
+// Allow check whether a call is allowed. If allowed, done must be called with the outcome of the call.
+// Otherwise, return ErrBreakerOpen.
+func (b *Breaker) Allow() (done func(success bool), err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.expire()
+	switch b.state {
+	case BreakerOpen:
+		breakerStats.Add(b.name+".rejected", 1)
+		return nil, fmt.Errorf("%w [%s]", ErrBreakerOpen, b.name)
+	case BreakerHalfOpen:
+		if b.trials >= b.options.HalfOpenCalls {
+			breakerStats.Add(b.name+".rejected", 1)
+			return nil, fmt.Errorf("%w [%s]", ErrBreakerOpen, b.name)
+		}
+		b.trials++
+	}
+	state := b.state
+	var once sync.Once
+	return func(success bool) {
+		once.Do(func() {
+			b.record(state, success)
+		})
+	}, nil
+}
+
+// record the outcome of a call allowed in state.
+func (b *Breaker) record(state BreakerState, success bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if !success {
+		breakerStats.Add(b.name+".failures", 1)
+	}
+	// Ignore outcomes of calls allowed before the last state change.
+	if state != b.state {
+		return
+	}
+	switch b.state {
+	case BreakerClosed:
+		if success {
+			b.failures = 0
+			return
+		}
+		b.failures++
+		if b.failures >= b.options.FailureThreshold {
+			b.transition(BreakerOpen)
+		}
+	case BreakerHalfOpen:
+		if !success {
+			b.transition(BreakerOpen)
+			return
+		}
+		b.successes++
+		if b.successes >= b.options.HalfOpenCalls {
+			b.transition(BreakerClosed)
+		}
+	}
+}
+
+// expire switch an open breaker to half-open once the open timeout elapsed. Must be called with mu held.
+func (b *Breaker) expire() {
+	if b.state == BreakerOpen && b.options.Clock.Now().Sub(b.openedAt) >= b.options.OpenTimeout {
+		b.transition(BreakerHalfOpen)
+	}
+}
+
+// transition change the state and reset the counters. Must be called with mu held.
+func (b *Breaker) transition(state BreakerState) {
+	b.state = state
+	b.published.Store(int32(state))
+	b.failures, b.trials, b.successes = 0, 0, 0
+	switch state {
+	case BreakerOpen:
+		b.openedAt = b.options.Clock.Now()
+		b.publishedOpenedAt.Store(b.openedAt.UnixNano())
+		breakerStats.Add(b.name+".opens", 1)
+		b.options.Logger.Warn("Circuit breaker opened", slog.String("breaker", b.name),
+			slog.Duration("open_timeout", b.options.OpenTimeout))
+	case BreakerClosed:
+		b.options.Logger.Info("Circuit breaker closed", slog.String("breaker", b.name))
+	}
+}
+
+// Transport wrap next so requests are rejected with ErrBreakerOpen while the breaker is open.
+// Network errors and 5xx responses are recorded as failures.
+func (b *Breaker) Transport(next http.RoundTripper) http.RoundTripper {
+	return &breakerTransport{next: next, breaker: func(*http.Request) *Breaker { return b }}
+}
+
+// breakerStateVar expvar of the state of a breaker.
+type breakerStateVar struct {
+	breaker *Breaker
+}
+
+// String return the state of the breaker, an open breaker whose open timeout elapsed is reported as half-open, like
+// State, even if no call was made since.
+func (v breakerStateVar) String() string {
+	b := v.breaker
+	state := BreakerState(b.published.Load())
+	if state == BreakerOpen && b.options.Clock.Now().Sub(time.Unix(0, b.publishedOpenedAt.Load())) >= b.options.OpenTimeout {
+		state = BreakerHalfOpen
+	}
+	return `"` + state.String() + `"`
+}
+
+// breakerTransport reject requests while the breaker of the request is open.
+type breakerTransport struct {
+	next    http.RoundTripper
+	breaker func(req *http.Request) *Breaker
+}
+
+func (t *breakerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	done, err := t.breaker(req).Allow()
+	if err != nil {
+		return nil, err
+	}
+	res, err := t.next.RoundTrip(req)
+	if err != nil {
+		done(req.Context().Err() != nil)
+		return nil, err
+	}
+	done(res.StatusCode < http.StatusInternalServerError)
+	return res, nil
+}
+
+// hostBreakers breakers of each host called by the http client, created on first call.
+type hostBreakers struct {
+	mu       sync.Mutex
+	options  BreakerOptions
+	breakers map[string]*Breaker
+}
+
+func (h *hostBreakers) breakerOf(req *http.Request) *Breaker {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	b, ok := h.breakers[req.URL.Host]
+	if !ok {
+		b = NewBreaker(httpClientLoggerName+"."+req.URL.Host, h.options)
+		h.breakers[req.URL.Host] = b
+	}
+	return b
+}
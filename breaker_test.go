@@ -0,0 +1,207 @@
+package corefx_test
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"github.com/mawngo/go-corefx"
+	"github.com/mawngo/go-corefx/corefxtest"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var errBreakerTest = errors.New("error test")
+
+// newTestBreaker create a breaker named after the test, opening after 2 failures for 10s, with 2 half-open calls.
+func newTestBreaker(t *testing.T) (*corefx.Breaker, *corefxtest.FakeClock) {
+	clock := corefxtest.NewFakeClock(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	b := corefx.NewBreaker(t.Name(), corefx.BreakerOptions{
+		FailureThreshold: 2,
+		OpenTimeout:      10 * time.Second,
+		HalfOpenCalls:    2,
+		Logger:           slog.New(slog.NewTextHandler(io.Discard, nil)),
+		Clock:            clock,
+	})
+	return b, clock
+}
+
+func breakerStatOf(t *testing.T, key string) string {
+	v := expvar.Get("corefx_breaker").(*expvar.Map).Get(t.Name() + "." + key)
+	if v == nil {
+		return ""
+	}
+	return v.String()
+}
+
+func fail(_ context.Context) error {
+	return errBreakerTest
+}
+
+func succeed(_ context.Context) error {
+	return nil
+}
+
+func TestBreakerOpen(t *testing.T) {
+	b, _ := newTestBreaker(t)
+	ctx := context.Background()
+	_ = b.Do(ctx, fail)
+	_ = b.Do(ctx, succeed)
+	_ = b.Do(ctx, fail)
+	if b.State() != corefx.BreakerClosed {
+		t.Fatalf("State() = %s, want closed, a success reset the consecutive failures", b.State())
+	}
+	_ = b.Do(ctx, fail)
+	if b.State() != corefx.BreakerOpen {
+		t.Fatalf("State() = %s, want open", b.State())
+	}
+
+	called := false
+	err := b.Do(ctx, func(context.Context) error {
+		called = true
+		return nil
+	})
+	if called || !errors.Is(err, corefx.ErrBreakerOpen) {
+		t.Errorf("Do() called = %v, error = %v, want rejected with ErrBreakerOpen", called, err)
+	}
+	if err := b.Check(ctx); !errors.Is(err, corefx.ErrBreakerOpen) {
+		t.Errorf("Check() error = %v, want ErrBreakerOpen", err)
+	}
+	if got := breakerStatOf(t, "state"); got != `"open"` {
+		t.Errorf("state expvar = %s, want \"open\"", got)
+	}
+	if got := breakerStatOf(t, "failures"); got != "3" {
+		t.Errorf("failures expvar = %s, want 3", got)
+	}
+	if got := breakerStatOf(t, "rejected"); got != "1" {
+		t.Errorf("rejected expvar = %s, want 1", got)
+	}
+}
+
+func TestBreakerHalfOpen(t *testing.T) {
+	tests := []struct {
+		name     string
+		outcomes []bool
+		want     corefx.BreakerState
+	}{
+		{name: "trials succeed", outcomes: []bool{true, true}, want: corefx.BreakerClosed},
+		{name: "first trial fail", outcomes: []bool{false, true}, want: corefx.BreakerOpen},
+		{name: "last trial fail", outcomes: []bool{true, false}, want: corefx.BreakerOpen},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, clock := newTestBreaker(t)
+			_ = b.Do(context.Background(), fail)
+			_ = b.Do(context.Background(), fail)
+			clock.Add(10 * time.Second)
+			if b.State() != corefx.BreakerHalfOpen {
+				t.Fatalf("State() = %s, want half-open", b.State())
+			}
+
+			var trials []func(bool)
+			for range tt.outcomes {
+				done, err := b.Allow()
+				if err != nil {
+					t.Fatalf("Allow() error = %v, want trial allowed", err)
+				}
+				trials = append(trials, done)
+			}
+			if _, err := b.Allow(); !errors.Is(err, corefx.ErrBreakerOpen) {
+				t.Fatalf("Allow() error = %v, want ErrBreakerOpen after the half-open calls", err)
+			}
+			for i, success := range tt.outcomes {
+				trials[i](success)
+			}
+			if b.State() != tt.want {
+				t.Errorf("State() = %s, want %s", b.State(), tt.want)
+			}
+		})
+	}
+}
+
+func TestBreakerStaleOutcome(t *testing.T) {
+	b, clock := newTestBreaker(t)
+	stale, err := b.Allow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = b.Do(context.Background(), fail)
+	_ = b.Do(context.Background(), fail)
+	clock.Add(10 * time.Second)
+	trial, err := b.Allow()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The outcome of a call allowed while closed must not decide the half-open trials.
+	stale(false)
+	if b.State() != corefx.BreakerHalfOpen {
+		t.Fatalf("State() = %s, want half-open after a stale failure", b.State())
+	}
+	trial(true)
+	// Calling done again is ignored.
+	trial(false)
+	done, err := b.Allow()
+	if err != nil {
+		t.Fatal(err)
+	}
+	done(true)
+	if b.State() != corefx.BreakerClosed {
+		t.Errorf("State() = %s, want closed", b.State())
+	}
+}
+
+func TestBreakerStateExpvarExpire(t *testing.T) {
+	b, clock := newTestBreaker(t)
+	_ = b.Do(context.Background(), fail)
+	_ = b.Do(context.Background(), fail)
+	clock.Add(10 * time.Second)
+	// No call is made, the expvar must not report the breaker as open forever.
+	if got := breakerStatOf(t, "state"); got != `"half-open"` {
+		t.Errorf("state expvar = %s, want \"half-open\"", got)
+	}
+	if b.State() != corefx.BreakerHalfOpen {
+		t.Errorf("State() = %s, want half-open", b.State())
+	}
+}
+
+func TestBreakerCancelled(t *testing.T) {
+	b, _ := newTestBreaker(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for range 3 {
+		_ = b.Do(ctx, func(ctx context.Context) error {
+			return ctx.Err()
+		})
+	}
+	if b.State() != corefx.BreakerClosed {
+		t.Errorf("State() = %s, want closed, cancelled calls are not failures", b.State())
+	}
+}
+
+func TestBreakerTransport(t *testing.T) {
+	var status atomic.Int32
+	status.Store(http.StatusInternalServerError)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(int(status.Load()))
+	}))
+	defer server.Close()
+	b, _ := newTestBreaker(t)
+	client := &http.Client{Transport: b.Transport(http.DefaultTransport)}
+
+	for range 2 {
+		res, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = res.Body.Close()
+	}
+	status.Store(http.StatusOK)
+	if _, err := client.Get(server.URL); !errors.Is(err, corefx.ErrBreakerOpen) {
+		t.Errorf("Get() error = %v, want ErrBreakerOpen after 5xx responses", err)
+	}
+}
@@ -0,0 +1,66 @@
+package corefx
+
+import (
+	"runtime/debug"
+	"sync"
+	"time"
+)
+
+// develVersion module version of binaries built from a checkout that is not stamped with a version.
+const develVersion = "(devel)"
+
+// BuildInfo version control info of the running binary, embedded by the go command.
+type BuildInfo struct {
+	// Version of the main module, for example v1.2.3, or "(devel)" if not stamped.
+	Version   string `json:"version,omitempty"`
+	GoVersion string `json:"go_version,omitempty"`
+	// Revision VCS commit hash.
+	Revision   string    `json:"revision,omitempty"`
+	CommitTime time.Time `json:"commit_time"`
+	// Modified whether the working tree had uncommitted changes.
+	Modified bool `json:"modified"`
+}
+
+var readBuildInfo = sync.OnceValue(func() BuildInfo {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return BuildInfo{}
+	}
+	build := BuildInfo{Version: info.Main.Version, GoVersion: info.GoVersion}
+	for _, setting := range info.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			build.Revision = setting.Value
+		case "vcs.time":
+			build.CommitTime, _ = time.Parse(time.RFC3339, setting.Value)
+		case "vcs.modified":
+			build.Modified = setting.Value == "true"
+		}
+	}
+	return build
+})
+
+// ReadBuildInfo return the build info of the running binary, read from runtime/debug.ReadBuildInfo.
+// Fields are empty if the binary was built without module or VCS info, for example using go run or -buildvcs=false.
+func ReadBuildInfo() BuildInfo {
+	return readBuildInfo()
+}
+
+// AppVersion return the version to report for the app: the module version if stamped, otherwise the short revision
+// with a "-dirty" suffix if modified, or empty if unknown.
+func (b BuildInfo) AppVersion() string {
+	if b.Version != "" && b.Version != develVersion {
+		return b.Version
+	}
+	if b.Revision == "" {
+		return ""
+	}
+	version := b.Revision
+	if len(version) > 12 {
+		version = version[:12]
+	}
+	if b.Modified {
+		version += "-dirty"
+	}
+	return version
+}
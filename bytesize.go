@@ -0,0 +1,83 @@
+package corefx
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ByteSize size in bytes, which can be configured using human-readable string, for example "512MB" or "1GiB".
+type ByteSize uint64
+
+const (
+	Byte     ByteSize = 1
+	KiloByte          = 1000 * Byte
+	MegaByte          = 1000 * KiloByte
+	GigaByte          = 1000 * MegaByte
+	TeraByte          = 1000 * GigaByte
+	KibiByte          = 1024 * Byte
+	MebiByte          = 1024 * KibiByte
+	GibiByte          = 1024 * MebiByte
+	TebiByte          = 1024 * GibiByte
+)
+
+// byteSizeUnits units ordered from largest to smallest, used for both parsing and formatting.
+var byteSizeUnits = []struct {
+	name string
+	size ByteSize
+}{
+	{"TiB", TebiByte},
+	{"TB", TeraByte},
+	{"GiB", GibiByte},
+	{"GB", GigaByte},
+	{"MiB", MebiByte},
+	{"MB", MegaByte},
+	{"KiB", KibiByte},
+	{"KB", KiloByte},
+	{"B", Byte},
+}
+
+// ParseByteSize parse a human-readable size, for example "512MB", "1.5GiB" or "1024".
+// Units are case-insensitive, KB, MB, GB, TB are powers of 1000 and KiB, MiB, GiB, TiB are powers of 1024.
+// Number without unit is in bytes.
+func ParseByteSize(s string) (ByteSize, error) {
+	value := strings.TrimSpace(s)
+	unit := Byte
+	for _, u := range byteSizeUnits {
+		if len(value) > len(u.name) && strings.EqualFold(value[len(value)-len(u.name):], u.name) {
+			value, unit = strings.TrimSpace(value[:len(value)-len(u.name)]), u.size
+			break
+		}
+	}
+	if n, err := strconv.ParseUint(value, 10, 64); err == nil {
+		return ByteSize(n) * unit, nil
+	}
+	f, err := strconv.ParseFloat(value, 64)
+	if err != nil || f < 0 {
+		return 0, fmt.Errorf("error invalid byte size [%s]", s)
+	}
+	return ByteSize(f * float64(unit)), nil
+}
+
+// String format the size using the largest unit that represents it exactly, for example "512MB" or "1GiB".
+func (b ByteSize) String() string {
+	for _, u := range byteSizeUnits {
+		if b != 0 && b%u.size == 0 {
+			return strconv.FormatUint(uint64(b/u.size), 10) + u.name
+		}
+	}
+	return "0B"
+}
+
+func (b ByteSize) MarshalText() ([]byte, error) {
+	return []byte(b.String()), nil
+}
+
+func (b *ByteSize) UnmarshalText(text []byte) error {
+	size, err := ParseByteSize(string(text))
+	if err != nil {
+		return err
+	}
+	*b = size
+	return nil
+}
@@ -0,0 +1,78 @@
+package corefx
+
+import (
+	"time"
+)
+
+// Clock source of time of corefx components, such as the Scheduler, WorkerManager, LeaseElector and
+// ShutdownCoordinator. The real clock is provided by NewModule, which can be replaced in tests to control time,
+// for example fx.Decorate(func(corefx.Clock) corefx.Clock { return fakeClock }).
+type Clock interface {
+	// Now return the current time.
+	Now() time.Time
+	// NewTicker return a Ticker that send the time on its channel every d.
+	NewTicker(d time.Duration) Ticker
+	// NewTimer return a Timer that send the time on its channel after d.
+	NewTimer(d time.Duration) Timer
+	// After return a channel that receive the time after d.
+	After(d time.Duration) <-chan time.Time
+	// Sleep pause the current goroutine for d.
+	Sleep(d time.Duration)
+}
+
+// Ticker a time.Ticker of a Clock.
+type Ticker interface {
+	C() <-chan time.Time
+	Stop()
+	Reset(d time.Duration)
+}
+
+// Timer a time.Timer of a Clock.
+type Timer interface {
+	C() <-chan time.Time
+	Stop() bool
+	Reset(d time.Duration) bool
+}
+
+// RealClock return the Clock of the system time.
+func RealClock() Clock {
+	return realClock{}
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time {
+	return time.Now()
+}
+
+func (realClock) NewTicker(d time.Duration) Ticker {
+	return realTicker{time.NewTicker(d)}
+}
+
+func (realClock) NewTimer(d time.Duration) Timer {
+	return realTimer{time.NewTimer(d)}
+}
+
+func (realClock) After(d time.Duration) <-chan time.Time {
+	return time.After(d)
+}
+
+func (realClock) Sleep(d time.Duration) {
+	time.Sleep(d)
+}
+
+type realTicker struct {
+	*time.Ticker
+}
+
+func (t realTicker) C() <-chan time.Time {
+	return t.Ticker.C
+}
+
+type realTimer struct {
+	*time.Timer
+}
+
+func (t realTimer) C() <-chan time.Time {
+	return t.Timer.C
+}
@@ -0,0 +1,111 @@
+package corefx
+
+import (
+	"errors"
+	"github.com/spf13/viper"
+	"strings"
+	"time"
+)
+
+// ConfigRegistry read config values by dot separated key, for example "server.port", from the merged config files,
+// remote config, env and flags, for code that need dynamic keys without struct fields, such as plugins and feature
+// toggles. Values are read from the current config, so they reflect config reloads.
+// Missing keys return the zero value, use IsSet to check whether a key is set.
+type ConfigRegistry struct {
+	watcher *ConfigWatcher
+}
+
+// NewConfigRegistry create a registry reading the config loaded by the watcher.
+func NewConfigRegistry(watcher *ConfigWatcher) *ConfigRegistry {
+	return &ConfigRegistry{watcher: watcher}
+}
+
+// viper return the viper instance of the current config, or an empty one if the config is not loaded.
+func (r *ConfigRegistry) viper() *viper.Viper {
+	if v := r.watcher.Viper(); v != nil {
+		return v
+	}
+	return viper.New()
+}
+
+// Get return the value of key, nil if not set.
+func (r *ConfigRegistry) Get(key string) any {
+	return r.viper().Get(key)
+}
+
+// IsSet whether key is set in any source.
+func (r *ConfigRegistry) IsSet(key string) bool {
+	return r.viper().IsSet(key)
+}
+
+// AllKeys return all keys that hold a value, nested keys are dot separated.
+func (r *ConfigRegistry) AllKeys() []string {
+	return r.viper().AllKeys()
+}
+
+func (r *ConfigRegistry) GetString(key string) string {
+	return r.viper().GetString(key)
+}
+
+func (r *ConfigRegistry) GetBool(key string) bool {
+	return r.viper().GetBool(key)
+}
+
+func (r *ConfigRegistry) GetInt(key string) int {
+	return r.viper().GetInt(key)
+}
+
+func (r *ConfigRegistry) GetInt64(key string) int64 {
+	return r.viper().GetInt64(key)
+}
+
+func (r *ConfigRegistry) GetFloat64(key string) float64 {
+	return r.viper().GetFloat64(key)
+}
+
+// GetDuration return the value of key parsed using the time.ParseDuration format, or as nanoseconds if it is a number.
+func (r *ConfigRegistry) GetDuration(key string) time.Duration {
+	return r.viper().GetDuration(key)
+}
+
+func (r *ConfigRegistry) GetTime(key string) time.Time {
+	return r.viper().GetTime(key)
+}
+
+// GetStringSlice return the value of key as a slice, a string value is split by commas.
+func (r *ConfigRegistry) GetStringSlice(key string) []string {
+	v := r.viper()
+	if s, ok := v.Get(key).(string); ok {
+		if s == "" {
+			return []string{}
+		}
+		values := strings.Split(s, ",")
+		for i := range values {
+			values[i] = strings.TrimSpace(values[i])
+		}
+		return values
+	}
+	return v.GetStringSlice(key)
+}
+
+func (r *ConfigRegistry) GetIntSlice(key string) []int {
+	return r.viper().GetIntSlice(key)
+}
+
+func (r *ConfigRegistry) GetStringMap(key string) map[string]any {
+	return r.viper().GetStringMap(key)
+}
+
+func (r *ConfigRegistry) GetStringMapString(key string) map[string]string {
+	return r.viper().GetStringMapString(key)
+}
+
+// Unmarshal decode the value of key, usually a nested section, into out using the mapstructure tags of out.
+// Unlike Section, defaults and validation of the section are not applied.
+func (r *ConfigRegistry) Unmarshal(key string, out any) error {
+	v := r.watcher.Viper()
+	if v == nil {
+		return errors.New("error config is not loaded")
+	}
+	return v.UnmarshalKey(key, out)
+}
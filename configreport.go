@@ -0,0 +1,226 @@
+package corefx
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/spf13/viper"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+// Origins of config values, see ConfigValueOrigin.
+const (
+	ConfigOriginDefault = "default"
+	ConfigOriginFile    = "file"
+	ConfigOriginRemote  = "remote"
+	ConfigOriginEnv     = "env"
+	ConfigOriginFlag    = "flag"
+	// ConfigOriginCustom value set by a user defined ConfigSource.
+	ConfigOriginCustom = "custom"
+)
+
+// ConfigValueOrigin the effective value of a config key and where it came from.
+type ConfigValueOrigin struct {
+	// Key dot separated config key, for example database.url.
+	Key string `json:"key"`
+	// Value the effective value, secret values are masked.
+	Value any `json:"value"`
+	// Origin kind of the source that set the value, for example ConfigOriginFile.
+	Origin string `json:"origin"`
+	// Location of the source, for example the file path, the env name or the flag name.
+	Location string `json:"location,omitempty"`
+}
+
+type configOrigin struct {
+	origin   string
+	location string
+}
+
+// configOrigins track the source of config values while loading.
+type configOrigins struct {
+	origins  map[string]configOrigin
+	settings map[string]any
+}
+
+func newConfigOrigins() *configOrigins {
+	return &configOrigins{origins: map[string]configOrigin{}, settings: map[string]any{}}
+}
+
+// record attribute values of v that changed since the last record to the source.
+// The locationOf func return the location of a key in the source, can be nil.
+func (o *configOrigins) record(v *viper.Viper, origin string, locationOf func(key string) string) {
+	if o == nil {
+		return
+	}
+	settings := map[string]any{}
+	flattenSettings(settings, "", v.AllSettings())
+	for key, value := range settings {
+		if old, ok := o.settings[key]; ok && reflect.DeepEqual(old, value) {
+			continue
+		}
+		location := ""
+		if locationOf != nil {
+			location = locationOf(key)
+		}
+		o.origins[key] = configOrigin{origin: origin, location: location}
+	}
+	o.settings = settings
+}
+
+// recordKeys attribute the leaf keys of settings to the source, whether their values are changed or not.
+func (o *configOrigins) recordKeys(settings map[string]any, origin string, locationOf func(key string) string) {
+	if o == nil {
+		return
+	}
+	values := map[string]any{}
+	flattenSettings(values, "", settings)
+	for key := range values {
+		location := ""
+		if locationOf != nil {
+			location = locationOf(key)
+		}
+		o.origins[key] = configOrigin{origin: origin, location: location}
+	}
+}
+
+// isSet whether the key, or one of its parent keys, is set by a source other than the defaults.
+func (o *configOrigins) isSet(key string) bool {
+	if o == nil {
+		return false
+	}
+	for {
+		if origin, ok := o.origins[key]; ok && origin.origin != ConfigOriginDefault {
+			return true
+		}
+		i := strings.LastIndex(key, ".")
+		if i < 0 {
+			return false
+		}
+		key = key[:i]
+	}
+}
+
+// set attribute the value of key to the source.
+func (o *configOrigins) set(key string, origin string, location string) {
+	if o == nil {
+		return
+	}
+	o.origins[key] = configOrigin{origin: origin, location: location}
+}
+
+// report return the effective values of cfg and their origins, sorted by key.
+// Values that are not attributed to any source are reported as defaults.
+func (o *configOrigins) report(cfg any) ([]ConfigValueOrigin, error) {
+	dump, err := DumpConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+	values := map[string]any{}
+	flattenSettings(values, "", dump)
+
+	report := make([]ConfigValueOrigin, 0, len(values))
+	for key, value := range values {
+		origin := configOrigin{origin: ConfigOriginDefault}
+		if o != nil {
+			if recorded, ok := o.origins[key]; ok {
+				origin = recorded
+			}
+		}
+		report = append(report, ConfigValueOrigin{
+			Key:      key,
+			Value:    value,
+			Origin:   origin.origin,
+			Location: origin.location,
+		})
+	}
+	sort.Slice(report, func(i, j int) bool {
+		return report[i].Key < report[j].Key
+	})
+	return report, nil
+}
+
+// flattenSettings put the leaf values of settings into values by their dot separated keys.
+func flattenSettings(values map[string]any, prefix string, settings map[string]any) {
+	for key, value := range settings {
+		key = joinConfigPath(prefix, strings.ToLower(key))
+		if nested, ok := value.(map[string]any); ok && len(nested) > 0 {
+			flattenSettings(values, key, nested)
+			continue
+		}
+		values[key] = value
+	}
+}
+
+// originSource ConfigSource that know the origin of its values, used to report config origins.
+type originSource struct {
+	origin     string
+	locationOf func(key string) string
+	settingsOf func(v *viper.Viper, cfg any) (map[string]any, error)
+}
+
+// sourceOf create a ConfigSource that merge the settings returned by settingsOf, which may be nil.
+func sourceOf(origin string, locationOf func(key string) string,
+	settingsOf func(v *viper.Viper, cfg any) (map[string]any, error)) ConfigSource {
+	return originSource{origin: origin, locationOf: locationOf, settingsOf: settingsOf}
+}
+
+func (s originSource) MergeInto(v *viper.Viper, cfg any) error {
+	settings, err := s.settingsOf(v, cfg)
+	if err != nil || settings == nil {
+		return err
+	}
+	return v.MergeConfigMap(settings)
+}
+
+// mergeSource merge source into v, recording the origin of values into origins if not nil.
+// Keys of built-in sources are recorded even if their values are unchanged, values of other sources are attributed
+// to ConfigOriginCustom if they are changed.
+func mergeSource(v *viper.Viper, cfg any, source ConfigSource, origins *configOrigins) error {
+	s, ok := source.(originSource)
+	if !ok {
+		if err := source.MergeInto(v, cfg); err != nil {
+			return err
+		}
+		origins.record(v, ConfigOriginCustom, nil)
+		return nil
+	}
+	settings, err := s.settingsOf(v, cfg)
+	if err != nil || settings == nil {
+		return err
+	}
+	if err := v.MergeConfigMap(settings); err != nil {
+		return err
+	}
+	origins.record(v, s.origin, s.locationOf)
+	origins.recordKeys(settings, s.origin, s.locationOf)
+	return nil
+}
+
+// fixedLocation return a location func that always return location.
+func fixedLocation(location string) func(key string) string {
+	return func(_ string) string {
+		return location
+	}
+}
+
+// WriteConfigReport write the config report into w as a table aligned by tabs, see ConfigWatcher.ConfigReport.
+// Values are written as JSON.
+func WriteConfigReport(w io.Writer, report []ConfigValueOrigin) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	if _, err := fmt.Fprintln(tw, "KEY\tVALUE\tORIGIN\tLOCATION"); err != nil {
+		return err
+	}
+	for _, entry := range report {
+		value, err := json.Marshal(entry.Value)
+		if err != nil {
+			return err
+		}
+		if _, err := fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", entry.Key, value, entry.Origin, entry.Location); err != nil {
+			return err
+		}
+	}
+	return tw.Flush()
+}
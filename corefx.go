@@ -2,22 +2,30 @@
 
 import (
 	"bytes"
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/mitchellh/mapstructure"
+	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
+	"github.com/subosito/gotenv"
 	"go.uber.org/fx"
 	"io/fs"
 	"log/slog"
+	"os"
 	"path/filepath"
 	"reflect"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
 )
 
 const (
 	ConfigFolder = "configs"
 	ConfigFile   = "app.json"
+	DotenvFile   = ".env"
 )
 const (
 	ProfileProduction  = "production"
@@ -31,25 +39,52 @@ type CoreConfig interface {
 	// AppVersionValue application version.
 	AppVersionValue() string
 	// AppConfigLocationValue base config file to load from.
-	// Config file must in JSON format.
+	// Config file must be in JSON or YAML format, detected using the file extension (.json, .yaml, .yml).
+	// Remote locations are also supported: consul://host:8500/path/to/key.json, etcd://host:2379/path/to/key.json
+	// and http(s)://host/path/to/app.json, see HTTPConfigLocationConfig to configure http(s) requests.
+	// Use consul+https:// and etcd+https:// to connect to Consul and etcd using https.
+	// Directories of files, such as mounted Kubernetes ConfigMap and Secret, are supported using dir:/path/to/dir,
+	// see DirSource.
 	// Return empty string to disable loading from a config file.
 	// Default implementations read config from file:./configs/app.json.
 	AppConfigLocationValue() (string, error)
 	// AppAutomaticEnvValue enable read env variable into config struct automatically.
 	AppAutomaticEnvValue() bool
+	// AppEnvPrefixValue prefix of env variables, for example MYAPP will read log_level from MYAPP_LOG_LEVEL.
+	// Return empty string to read env variables without prefix.
+	AppEnvPrefixValue() string
+	// AppDotenvLocationValue dotenv file to load into the process environment before reading env variables.
+	// Variables already set in the environment are not overridden, missing file is ignored.
+	// Return empty string to disable loading dotenv file.
+	// Default implementations read file:.env next to the binary.
+	AppDotenvLocationValue() (string, error)
 	// ProfileValue application env profile (production,development,debug).
+	// When set, profile specific config files (for example configs/app.production.json) are merged on top of the
+	// base config files.
 	ProfileValue() string
 	// RequiredValues the list of field that must specify.
 	// This method must return a list of pointers to specified field on the same object.
+	// Alternatively, tag the field with `required:"true"`, which also work for nested pointers, slices and maps.
 	RequiredValues() []any
 	// LogLevelValue application log level.
 	LogLevelValue() string
-	// LogFormatValue the format of log, accept "text", "json"
+	// LogFormatValue the format of log, accept "text", "json", "logfmt", "ecs" (Elastic Common Schema JSON),
+	// "gcp" (Google Cloud Logging structured JSON)
 	LogFormatValue() string
 	// IsProd shorthand production profile checking.
 	IsProd() bool
 }
 
+// MultiLocationConfig optional interface that a CoreConfig can implement to load config from multiple locations.
+// Useful for local development overrides without touching the base config.
+type MultiLocationConfig interface {
+	// AppConfigLocationsValue list of config files to load from, for example:
+	// []string{"file:configs/app.json", "file:configs/app.local.json"}.
+	// Files are merged in order, later files override earlier ones, missing files are ignored.
+	// When implemented, AppConfigLocationValue is ignored.
+	AppConfigLocationsValue() ([]string, error)
+}
+
 // nolint:staticcheck
 type CoreEnv struct {
 	AppName    string `json:"app_name" mapstructure:"app_name"`
@@ -63,6 +98,18 @@ func (e CoreEnv) AppAutomaticEnvValue() bool {
 	return true
 }
 
+func (e CoreEnv) AppEnvPrefixValue() string {
+	return ""
+}
+
+func (e CoreEnv) AppDotenvLocationValue() (string, error) {
+	executable, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+	return "file:" + filepath.Join(filepath.Dir(executable), DotenvFile), nil
+}
+
 func (e CoreEnv) AppConfigLocationValue() (string, error) {
 	path := filepath.Join(".", ConfigFolder, ConfigFile)
 	path, err := filepath.Abs(path)
@@ -84,7 +131,11 @@ func (e CoreEnv) AppNameValue() string {
 	return e.AppName
 }
 
+// AppVersionValue return the configured app version, or the version of the binary, see BuildInfo.AppVersion.
 func (e CoreEnv) AppVersionValue() string {
+	if e.AppVersion == "" {
+		return ReadBuildInfo().AppVersion()
+	}
 	return e.AppVersion
 }
 
@@ -111,19 +162,36 @@ func NewEnv() CoreEnv {
 // NewModule Create a module that autoconfigure slog, sentry and populate configuration from file or environment.
 // The env config object must implement CoreConfig, and registered using AsConfigFor to be autopopulated.
 // The env config must also register as SentryConfig to enable sentry feature.
+// Records logged using slog while the config is loaded are buffered, then written once the logger is created.
+// If the config cannot be loaded or the logger cannot be created, the buffered records are written into stderr.
+// NewModule is equivalent to New(WithSentry()).
 func NewModule() fx.Option {
+	return New(WithSentry())
+}
+
+// New create a module like NewModule, composed with opts, so only the enabled subsystems are configured.
+// Sentry is only initialized if WithSentry is used, other modules of this package can be passed as opts.
+// For example, corefx.New(corefx.WithSentry(), corefx.WithLocalLogger(), corefx.NewHTTPServerModule()).
+func New(opts ...fx.Option) fx.Option {
 	return fx.Options(
-		UseSlogLogger(),
+		useFxEventLogger(),
 		fx.Module("corefx",
-			fx.Provide(NewGlobalSlogLogger),
-			fx.Decorate(func(p LoadJSONConfigParams) (CoreConfig, error) {
-				err := LoadJSONConfig(p)
-				return p.Config, err
-			}),
+			fx.Provide(newSlogLoggers),
+			fx.Provide(NewConfigWatcher),
+			fx.Provide(NewConfigRegistry),
+			fx.Provide(newLoadedConfig),
+			fx.Provide(NewPanicHandler),
+			fx.Provide(ReadBuildInfo),
+			fx.Provide(RealClock),
+			fx.Decorate(loadCoreConfig),
 			fx.Invoke(func(_ *slog.Logger) {
 				// force initialization of logger, which also initialize config.
 			}),
+			fx.Invoke(logEffectiveConfig),
+			fx.Invoke(registerConfigChangeListeners),
+			fx.Invoke(registerBanner),
 		),
+		fx.Options(opts...),
 	)
 }
 
@@ -142,6 +210,34 @@ func(t T) T { return t },
 	)
 }
 
+// Binding interface that T implements, checked at compile time, see Bind.
+type Binding[T any] struct {
+	as any
+}
+
+// Bind create a binding of T to the interface I, for Implements.
+// Go cannot constrain a type parameter by another one, so the conversion as is required for the compiler to check
+// that T implements I, for example corefx.Bind(func(c *myConfig) corefx.CoreConfig { return c }).
+// The conversion is only used for type checking.
+func Bind[T any, I any](_ func(T) I) Binding[T] {
+	return Binding[T]{as: new(I)}
+}
+
+// Implements register already registered type T under the interfaces of bindings, like As, but checked at compile time.
+// For example:
+//
+//	fx.Provide(corefx.Implements(
+//		corefx.Bind(func(c *myConfig) corefx.CoreConfig { return c }),
+//		corefx.Bind(func(c *myConfig) corefx.SentryConfig { return c }),
+//	))
+func Implements[T any](bindings ...Binding[T]) any {
+	types := make([]any, 0, len(bindings))
+	for _, binding := range bindings {
+		types = append(types, binding.as)
+	}
+	return As[T](types...)
+}
+
 // From create a function that accepts and return self.
 // This method can be used with other As... methods of multiple fx packages when you want to keep both the original type and annotated type
 // after annotated.
@@ -151,103 +247,581 @@ func From[T any]() any {
 }
 
 // LoadJSONConfigInto load json config into cfg pointer.
-func LoadJSONConfigInto(cfg any, automaticEnv bool, defaultCfgPath string) error {
+// The config file can be either JSON or YAML, detected using the file extension.
+// Keys in the config file always follow the json tag of cfg fields.
+// Zero fields tagged with `default:"value"` are set before the config files and env are merged.
+// Placeholders like ${ENV_VAR} or ${ENV_VAR:default} in string values are replaced after merging.
+// When multiple config paths are specified, they are merged in order, later files override earlier ones.
+// A fresh viper instance is used for each call, use LoadJSONConfigWithViper to inspect it after loading.
+func LoadJSONConfigInto(cfg any, automaticEnv bool, cfgPaths ...string) error {
+	return LoadJSONConfigWithViper(viper.New(), cfg, automaticEnv, cfgPaths...)
+}
+
+// LoadJSONConfigWithViper load json config into cfg pointer using the specified viper instance.
+// See LoadJSONConfigInto.
+func LoadJSONConfigWithViper(v *viper.Viper, cfg any, automaticEnv bool, cfgPaths ...string) error {
+	if err := loadJSONConfigWithViper(v, cfg, automaticEnv, nil, cfgPaths...); err != nil {
+		return err
+	}
+	return expandPlaceholders(cfg)
+}
+
+// loadJSONConfigWithViper load json config into cfg pointer, recording the origin of values into origins if not nil.
+// Env and flags are read by viper on access, so their origins are recorded separately, see overrideOrigins.
+func loadJSONConfigWithViper(v *viper.Viper, cfg any, automaticEnv bool, origins *configOrigins, cfgPaths ...string) error {
 	if reflect.ValueOf(cfg).Type().Kind() != reflect.Pointer {
 		return errors.New("error LoadConfigInto require a pointer to config struct")
 	}
+	if err := applyDefaults(cfg); err != nil {
+		return err
+	}
 
 	cfgJSONBytes, err := json.Marshal(cfg)
 	if err != nil {
 		return err
 	}
-	viper.SetConfigType("json")
+	v.SetConfigType("json")
 	if automaticEnv {
-		viper.SetEnvKeyReplacer(strings.NewReplacer(`.`, `__`))
-		viper.AutomaticEnv()
+		v.SetEnvKeyReplacer(strings.NewReplacer(`.`, `__`))
+		v.AutomaticEnv()
 	}
 
-	// Load default required keys from struct.
-	if err := viper.ReadConfig(bytes.NewReader(cfgJSONBytes)); err != nil {
+	// Load default required keys from struct, merging to keep keys of previous loads that are not in the struct,
+	// such as sections.
+	if err := v.MergeConfig(bytes.NewReader(cfgJSONBytes)); err != nil {
 		return err
 	}
+	origins.record(v, ConfigOriginDefault, nil)
+	return mergeConfigLocations(v, cfg, origins, cfgPaths...)
+}
 
-	// Handle the config file.
-	if strings.HasPrefix(defaultCfgPath, "file:") {
-		viper.SetConfigFile(defaultCfgPath[5:])
-		// Merge required file into default required, ignore if not exist.
-		if err := viper.MergeInConfig(); err != nil && !errors.Is(err, fs.ErrNotExist) {
-			return err
+// mergeConfigLocations merge config files and remote config of cfgPaths into v, ignoring missing ones,
+// then unmarshal v into cfg. Defaults are not applied again, so explicit zero values of previous merges are kept.
+func mergeConfigLocations(v *viper.Viper, cfg any, origins *configOrigins, cfgPaths ...string) error {
+	for _, cfgPath := range cfgPaths {
+		switch {
+		case strings.HasPrefix(cfgPath, "file:"):
+			if err := mergeSource(v, cfg, FileSource(cfgPath), origins); err != nil {
+				return err
+			}
+		case strings.HasPrefix(cfgPath, "dir:"):
+			if err := mergeSource(v, cfg, DirSource(cfgPath), origins); err != nil {
+				return err
+			}
+		case isRemoteLocation(cfgPath):
+			if err := mergeSource(v, cfg, RemoteSource(cfgPath), origins); err != nil {
+				return err
+			}
 		}
 	}
-	return viper.Unmarshal(cfg, func(config *mapstructure.DecoderConfig) {
-		config.TagName = "json"
-		config.Squash = true
-	})
+	return v.Unmarshal(cfg, jsonDecoderConfig)
+}
+
+// jsonDecoderConfig configure decoder to use json tag and squash embedded structs.
+func jsonDecoderConfig(config *mapstructure.DecoderConfig) {
+	config.TagName = "json"
+	config.Squash = true
+	config.DecodeHook = configDecodeHook()
+}
+
+// configDecodeHook decode strings from config files and env into typed values:
+// time.Duration using time.ParseDuration, types implementing encoding.TextUnmarshaler such as time.Time (RFC3339)
+// and ByteSize, and comma separated slices.
+func configDecodeHook() mapstructure.DecodeHookFunc {
+	return mapstructure.ComposeDecodeHookFunc(
+		mapstructure.StringToTimeDurationHookFunc(),
+		mapstructure.TextUnmarshallerHookFunc(),
+		mapstructure.StringToSliceHookFunc(","),
+	)
+}
+
+// configTypeOf return the viper config type of file based on its extension.
+// Unknown extensions are treated as json.
+func configTypeOf(path string) string {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		return "yaml"
+	default:
+		return "json"
+	}
 }
 
 type LoadJSONConfigParams struct {
 	fx.In
-	Config CoreConfig
+	Config          CoreConfig
+	StructValidator StructValidator  `optional:"true"`
+	SecretResolvers []SecretResolver `group:"secret_resolvers"`
+	FlagSet         *pflag.FlagSet   `optional:"true"`
+	Sources         configSources    `optional:"true"`
+}
+
+type loadCoreConfigParams struct {
+	fx.In
+	LoadJSONConfigParams
+	Watcher *ConfigWatcher
+	Local   localLoggerFlag `optional:"true"`
+}
+
+// loadCoreConfig load the config of NewModule using the watcher. Unless WithLocalLogger is used, records logged while
+// the config is loaded are buffered by the deferred logger until the global logger is created. The deferred logger is
+// detached once loaded, so whatever is resolved or fail before the creation of the logger cannot leave it installed.
+func loadCoreConfig(p loadCoreConfigParams) (CoreConfig, error) {
+	if !p.Local {
+		installDeferredLogger()
+	}
+	err := p.Watcher.load(p.LoadJSONConfigParams)
+	detachDeferredLogger()
+	if err != nil {
+		discardDeferredLogs()
+		return p.Config, &ConfigError{Err: err}
+	}
+	return p.Config, nil
 }
 
 // LoadJSONConfig load config into CoreConfig.
+// When the config implements ConfigSourcesConfig, the config is loaded from its sources using LoadConfig.
 func LoadJSONConfig(p LoadJSONConfigParams) error {
-	configLocation, err := p.Config.AppConfigLocationValue()
+	_, err := LoadJSONConfigViper(p)
+	return err
+}
+
+// LoadJSONConfigViper load config into CoreConfig and return the viper instance used for loading.
+// See LoadJSONConfig.
+func LoadJSONConfigViper(p LoadJSONConfigParams) (*viper.Viper, error) {
+	return loadJSONConfigViper(p, nil)
+}
+
+// loadJSONConfigViper load config into CoreConfig, recording the origin of values into origins.
+// The origins are also used to check required fields allowing zero value, a new one is used if nil.
+func loadJSONConfigViper(p LoadJSONConfigParams, origins *configOrigins) (*viper.Viper, error) {
+	if origins == nil {
+		origins = newConfigOrigins()
+	}
+	if p.Sources == nil {
+		if err := loadDotenv(p.Config); err != nil {
+			return nil, err
+		}
+	}
+	v := viper.New()
+	envPrefix := p.Config.AppEnvPrefixValue()
+	if err := loadConfigValues(v, p, envPrefix, origins); err != nil {
+		return nil, err
+	}
+
+	if err := resolveSecrets(context.Background(), p.Config, p.SecretResolvers); err != nil {
+		return nil, err
+	}
+	if err := checkRequired(p.Config, envPrefix, origins.isSet); err != nil {
+		return nil, err
+	}
+	if err := validateConfig(p.Config); err != nil {
+		return nil, err
+	}
+	if p.StructValidator != nil {
+		if err := p.StructValidator(p.Config); err != nil {
+			return nil, err
+		}
+	}
+	return v, nil
+}
+
+// loadConfigValues load values into config from its sources or from its locations, env and flags.
+func loadConfigValues(v *viper.Viper, p LoadJSONConfigParams, envPrefix string, origins *configOrigins) error {
+	if p.Sources != nil {
+		if err := loadConfigWithViper(v, p.Config, origins, p.Sources...); err != nil {
+			return err
+		}
+		return expandPlaceholders(p.Config)
+	}
+	if sourced, ok := p.Config.(ConfigSourcesConfig); ok {
+		sources, err := sourced.AppConfigSourcesValue()
+		if err != nil {
+			return err
+		}
+		if err := loadConfigWithViper(v, p.Config, origins, sources...); err != nil {
+			return err
+		}
+		return expandPlaceholders(p.Config)
+	}
+
+	configLocations, err := configLocationsOf(p.Config)
+	if err != nil {
+		return err
+	}
+	v.SetEnvPrefix(envPrefix)
+	if err := bindPFlags(v, p.FlagSet); err != nil {
+		return err
+	}
+	automaticEnv := p.Config.AppAutomaticEnvValue()
+	if err := loadJSONConfigWithViper(v, p.Config, automaticEnv, origins, configLocations...); err != nil {
+		return err
+	}
+
+	// Merge profile specific config files on top of loaded config, for example app.production.json.
+	if profile := p.Config.ProfileValue(); profile != "" {
+		profileLocations := profileLocationsOf(configLocations, profile)
+		if err := mergeConfigLocations(v, p.Config, origins, profileLocations...); err != nil {
+			return err
+		}
+	}
+	if origins != nil {
+		overrideOrigins(origins, p.FlagSet, automaticEnv, envPrefix)
+	}
+	return expandPlaceholders(p.Config)
+}
+
+// overrideOrigins attribute values overridden by env and explicitly set flags, which viper read on access.
+func overrideOrigins(origins *configOrigins, flagSet *pflag.FlagSet, automaticEnv bool, envPrefix string) {
+	if automaticEnv {
+		for key := range origins.settings {
+			envName := envNameOf(key, envPrefix)
+			if value, ok := os.LookupEnv(envName); ok && value != "" {
+				origins.set(key, ConfigOriginEnv, envName)
+			}
+		}
+	}
+	if flagSet != nil {
+		flagSet.Visit(func(flag *pflag.Flag) {
+			origins.set(strings.ReplaceAll(flag.Name, "-", "_"), ConfigOriginFlag, "--"+flag.Name)
+		})
+	}
+}
+
+// WithPFlags bind command line flags into config, flags take precedence over env, config file and defaults.
+// Must be used together with NewModule.
+// Flag names are mapped to config keys by replacing "-" with "_", for example --log-level set log_level.
+// Only flags that are explicitly set override the config, the flag default values are used as the last resort.
+func WithPFlags(flagSet *pflag.FlagSet) fx.Option {
+	return fx.Supply(flagSet)
+}
+
+// bindPFlags bind flags into viper using their config keys.
+func bindPFlags(v *viper.Viper, flagSet *pflag.FlagSet) error {
+	if flagSet == nil {
+		return nil
+	}
+	var err error
+	flagSet.VisitAll(func(flag *pflag.Flag) {
+		if bindErr := v.BindPFlag(strings.ReplaceAll(flag.Name, "-", "_"), flag); bindErr != nil {
+			err = errors.Join(err, bindErr)
+		}
+	})
+	return err
+}
+
+// loadDotenv load the dotenv file of config into the process environment, without overriding existing variables.
+func loadDotenv(cfg CoreConfig) error {
+	location, err := cfg.AppDotenvLocationValue()
 	if err != nil {
 		return err
 	}
-	if err := LoadJSONConfigInto(p.Config, p.Config.AppAutomaticEnvValue(), configLocation); err != nil {
+	if !strings.HasPrefix(location, "file:") {
+		return nil
+	}
+	if err := gotenv.Load(location[5:]); err != nil && !errors.Is(err, fs.ErrNotExist) {
 		return err
 	}
+	return nil
+}
+
+// configLocationsOf return config locations of CoreConfig.
+// Support MultiLocationConfig, fallback to CoreConfig.AppConfigLocationValue.
+func configLocationsOf(cfg CoreConfig) ([]string, error) {
+	if multi, ok := cfg.(MultiLocationConfig); ok {
+		return multi.AppConfigLocationsValue()
+	}
+	configLocation, err := cfg.AppConfigLocationValue()
+	if err != nil {
+		return nil, err
+	}
+	return []string{configLocation}, nil
+}
+
+// profileLocationsOf return the profile specific locations of file config locations.
+// For example, file:configs/app.json with production profile become file:configs/app.production.json.
+func profileLocationsOf(locations []string, profile string) []string {
+	profileLocations := make([]string, 0, len(locations))
+	for _, location := range locations {
+		if !strings.HasPrefix(location, "file:") {
+			continue
+		}
+		ext := filepath.Ext(location)
+		profileLocations = append(profileLocations, strings.TrimSuffix(location, ext)+"."+profile+ext)
+	}
+	return profileLocations
+}
+
+// MissingConfigField a required config field that is not set.
+type MissingConfigField struct {
+	// FieldPath path of the field in the config struct, for example Database.URL.
+	FieldPath string
+	// ConfigKey key of the field in config files, for example database.url.
+	ConfigKey string
+	// EnvName env variable of the field, for example DATABASE__URL.
+	EnvName string
+}
 
-	requireds := p.Config.RequiredValues()
-	if len(requireds) == 0 {
+func (f MissingConfigField) String() string {
+	return fmt.Sprintf("[%s] is required, consider setting value: [%s] in config file or [%s] in env",
+		f.FieldPath, f.ConfigKey, f.EnvName)
+}
+
+// ConfigError error returned when the config failed to load or is invalid, wrapping the cause, such as a
+// MissingConfigError.
+type ConfigError struct {
+	Err error
+}
+
+func (e *ConfigError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
+
+// MissingConfigError error returned when required config fields are not set, listing every missing field.
+// Use errors.As to render your own message, for example:
+//
+//	var missing *corefx.MissingConfigError
+//	if errors.As(err, &missing) {
+//		fmt.Println("Please set env:", strings.Join(missing.EnvNames(), ", "))
+//	}
+type MissingConfigError struct {
+	Fields []MissingConfigField
+}
+
+// newMissingConfigError return a MissingConfigError of fields, or nil if there is no missing field.
+func newMissingConfigError(fields []MissingConfigField) error {
+	if len(fields) == 0 {
 		return nil
 	}
-	return checkRequired(p.Config, requireds...)
+	return &MissingConfigError{Fields: fields}
+}
+
+func (e *MissingConfigError) Error() string {
+	messages := make([]string, 0, len(e.Fields))
+	for _, field := range e.Fields {
+		messages = append(messages, field.String())
+	}
+	return strings.Join(messages, "\n")
+}
+
+// FieldPaths return the struct paths of missing fields.
+func (e *MissingConfigError) FieldPaths() []string {
+	paths := make([]string, 0, len(e.Fields))
+	for _, field := range e.Fields {
+		paths = append(paths, field.FieldPath)
+	}
+	return paths
+}
+
+// ConfigKeys return the config file keys of missing fields.
+func (e *MissingConfigError) ConfigKeys() []string {
+	keys := make([]string, 0, len(e.Fields))
+	for _, field := range e.Fields {
+		keys = append(keys, field.ConfigKey)
+	}
+	return keys
+}
+
+// EnvNames return the env variables of missing fields.
+func (e *MissingConfigError) EnvNames() []string {
+	names := make([]string, 0, len(e.Fields))
+	for _, field := range e.Fields {
+		names = append(names, field.EnvName)
+	}
+	return names
+}
+
+// checkRequired check fields tagged with `required:"true"` and the fields pointed by RequiredValues of cfg.
+// The isSet func report whether a config key is explicitly set, used by fields allowing zero value.
+// Return a MissingConfigError listing every missing field.
+func checkRequired(cfg CoreConfig, envPrefix string, isSet func(configPath string) bool) error {
+	c := requiredChecker{envPrefix: envPrefix, isSet: isSet}
+	c.walkTags(reflect.ValueOf(cfg), "", "")
+	if requireds := cfg.RequiredValues(); len(requireds) > 0 {
+		for _, ptr := range requireds {
+			if reflect.ValueOf(ptr).Type().Kind() != reflect.Pointer {
+				return errors.New("error requiredValues must return array of pointer")
+			}
+		}
+		c.walkValues(reflect.ValueOf(cfg).Elem(), "", "", requireds)
+	}
+	return newMissingConfigError(c.missing)
+}
+
+// checkRequiredTagsAt check all fields tagged with `required:"true"` of s, which is loaded from the config key
+// configPath, including fields of nested structs, pointers, slices and maps.
+// Return a MissingConfigError listing every missing field.
+func checkRequiredTagsAt(s any, configPath string, envPrefix string, isSet func(configPath string) bool) error {
+	c := requiredChecker{envPrefix: envPrefix, isSet: isSet}
+	c.walkTags(reflect.ValueOf(s), "", configPath)
+	return newMissingConfigError(c.missing)
+}
+
+// requiredChecker collect missing required fields of a config.
+type requiredChecker struct {
+	envPrefix string
+	// isSet report whether a config key is explicitly set, can be nil.
+	isSet   func(configPath string) bool
+	missing []MissingConfigField
+}
+
+// requiredOf parse the required tag of field, for example `required:"true"` or `required:"true,allowzero"`.
+// Fields allowing zero value are only missing if their config key is not set.
+func requiredOf(field reflect.StructField) (required bool, allowZero bool) {
+	value, options, _ := strings.Cut(field.Tag.Get("required"), ",")
+	required, _ = strconv.ParseBool(value)
+	return required, options == "allowzero"
+}
+
+// isUnset whether a required value is not set: nil pointers and interfaces, empty slices and maps,
+// and zero values of other kinds. A pointer to a zero value is considered set.
+func isUnset(v reflect.Value) bool {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		return v.IsNil()
+	case reflect.Slice, reflect.Map:
+		return v.Len() == 0
+	default:
+		return v.IsZero()
+	}
 }
 
-func checkRequired(s any, vals ...any) error {
-	c := reflect.ValueOf(s).Elem()
-	for i := range vals {
-		ptr := vals[i]
-		if reflect.ValueOf(ptr).Type().Kind() != reflect.Pointer {
-			return errors.New("error requiredValues must return array of pointer")
+// isMissing whether the value of a required field is missing.
+func (c *requiredChecker) isMissing(v reflect.Value, configPath string, allowZero bool) bool {
+	if !isUnset(v) {
+		return false
+	}
+	if allowZero {
+		return c.isSet == nil || !c.isSet(configPath)
+	}
+	return true
+}
+
+func (c *requiredChecker) addMissing(fieldPath string, configPath string) {
+	for _, field := range c.missing {
+		if field.FieldPath == fieldPath {
+			return
+		}
+	}
+	c.missing = append(c.missing, MissingConfigField{
+		FieldPath: fieldPath,
+		ConfigKey: configPath,
+		EnvName:   envNameOf(configPath, c.envPrefix),
+	})
+}
+
+// walkValues check the fields of struct v pointed by vals, including fields of nested structs.
+func (c *requiredChecker) walkValues(v reflect.Value, fieldPath string, configPath string, vals []any) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		childFieldPath := joinConfigPath(fieldPath, field.Name)
+		childConfigPath := joinConfigPath(configPath, configNameOf(field))
+		value := v.Field(i)
+		if field.Type.Kind() == reflect.Struct {
+			// Embedded structs are squashed into the parent.
+			if field.Anonymous {
+				c.walkValues(value, fieldPath, configPath, vals)
+			} else {
+				c.walkValues(value, childFieldPath, childConfigPath, vals)
+			}
+		}
+
+		// Find field belong to required.
+		if !slices.Contains(vals, value.Addr().Interface()) {
+			continue
+		}
+		_, allowZero := requiredOf(field)
+		if c.isMissing(value, childConfigPath, allowZero) {
+			c.addMissing(childFieldPath, childConfigPath)
 		}
-		f := reflect.ValueOf(ptr).Elem()
+	}
+}
 
-		for i := 0; i < c.NumField(); i++ {
-			valueField := c.Field(i)
+// walkTags check fields tagged with `required:"true"` of v, including fields of nested structs, pointers, slices
+// and maps.
+func (c *requiredChecker) walkTags(v reflect.Value, fieldPath string, configPath string) {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if !v.IsNil() {
+			c.walkTags(v.Elem(), fieldPath, configPath)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			c.walkTags(v.Index(i), fieldPath+"["+strconv.Itoa(i)+"]", joinConfigPath(configPath, strconv.Itoa(i)))
+		}
+	case reflect.Map:
+		for _, k := range sortedMapKeys(v) {
+			key := fmt.Sprint(k.Interface())
+			c.walkTags(v.MapIndex(k), fieldPath+"["+key+"]", joinConfigPath(configPath, key))
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			configName := configNameOf(field)
+			if configName == "-" {
+				continue
+			}
 
-			// Nested struct
-			if valueField.Type().Kind() == reflect.Struct {
-				ptr := reflect.New(valueField.Type())
-				ptr.Elem().Set(valueField.Addr().Elem())
-				err := checkRequired(valueField.Addr().Interface(), vals...)
-				if err != nil {
-					return err
-				}
+			childFieldPath := joinConfigPath(fieldPath, field.Name)
+			childConfigPath := joinConfigPath(configPath, configName)
+			// Embedded structs are squashed into the parent.
+			if field.Anonymous && field.Type.Kind() == reflect.Struct {
+				childFieldPath, childConfigPath = fieldPath, configPath
 			}
 
-			// Find field belong to required
-			if valueField.Addr().Interface() == f.Addr().Interface() {
-				isUnset := reflect.ValueOf(ptr).Elem().IsZero()
-				if !isUnset {
-					continue
-				}
-
-				field := c.Type().Field(i)
-				configName := field.Tag.Get("json")
-				if configName == "" {
-					configName = field.Tag.Get("mapstructure")
-				}
-				if configName == "" {
-					configName = field.Name
-				}
-				return fmt.Errorf("[%s] is config, consider setting value: [%s] in config file or [%s] in env",
-					field.Name, configName, strings.ToUpper(configName))
+			value := v.Field(i)
+			if required, allowZero := requiredOf(field); required && c.isMissing(value, childConfigPath, allowZero) {
+				c.addMissing(childFieldPath, childConfigPath)
+				continue
 			}
+			c.walkTags(value, childFieldPath, childConfigPath)
 		}
+	default:
 	}
-	return nil
+}
+
+// configNameOf return the config key of a struct field, using json tag, mapstructure tag or field name.
+func configNameOf(field reflect.StructField) string {
+	for _, tag := range []string{"json", "mapstructure"} {
+		if name, _, _ := strings.Cut(field.Tag.Get(tag), ","); name != "" {
+			return name
+		}
+	}
+	return field.Name
+}
+
+// envNameOf return the env variable name of a config key.
+func envNameOf(configPath string, envPrefix string) string {
+	envName := strings.ToUpper(strings.ReplaceAll(configPath, ".", "__"))
+	if envPrefix != "" {
+		envName = strings.ToUpper(envPrefix) + "_" + envName
+	}
+	return envName
+}
+
+// sortedMapKeys return keys of map value, sorted by their string representation.
+func sortedMapKeys(v reflect.Value) []reflect.Value {
+	keys := v.MapKeys()
+	sort.Slice(keys, func(i, j int) bool {
+		return fmt.Sprint(keys[i].Interface()) < fmt.Sprint(keys[j].Interface())
+	})
+	return keys
+}
+
+func joinConfigPath(parent string, name string) string {
+	if parent == "" {
+		return name
+	}
+	return parent + "." + name
 }
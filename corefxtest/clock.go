@@ -0,0 +1,176 @@
+package corefxtest
+
+import (
+	"github.com/mawngo/go-corefx"
+	"go.uber.org/fx"
+	"sort"
+	"sync"
+	"time"
+)
+
+// WithClock replace the corefx.Clock of the app by clock, for example a FakeClock.
+func WithClock(clock corefx.Clock) fx.Option {
+	return fx.Decorate(func(corefx.Clock) corefx.Clock {
+		return clock
+	})
+}
+
+// FakeClock a corefx.Clock whose time only advance using Add or Set.
+// Timers, tickers and sleeps fire when the time is advanced past their deadline.
+type FakeClock struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	now     time.Time
+	waiters []*fakeWaiter
+}
+
+var _ corefx.Clock = (*FakeClock)(nil)
+
+// NewFakeClock create a FakeClock starting at now.
+func NewFakeClock(now time.Time) *FakeClock {
+	c := &FakeClock{now: now}
+	c.cond = sync.NewCond(&c.mu)
+	return c
+}
+
+func (c *FakeClock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *FakeClock) NewTicker(d time.Duration) corefx.Ticker {
+	if d <= 0 {
+		panic("non-positive interval for FakeClock.NewTicker")
+	}
+	return fakeTicker{c.newWaiter(d, d)}
+}
+
+func (c *FakeClock) NewTimer(d time.Duration) corefx.Timer {
+	return c.newWaiter(d, 0)
+}
+
+func (c *FakeClock) After(d time.Duration) <-chan time.Time {
+	return c.NewTimer(d).C()
+}
+
+func (c *FakeClock) Sleep(d time.Duration) {
+	<-c.After(d)
+}
+
+// Add advance the time by d, firing the timers, tickers and sleeps whose deadline is reached.
+func (c *FakeClock) Add(d time.Duration) {
+	c.Set(c.Now().Add(d))
+}
+
+// Set advance the time to now, firing the timers, tickers and sleeps whose deadline is reached.
+// The time never go backward.
+func (c *FakeClock) Set(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for {
+		sort.SliceStable(c.waiters, func(i, j int) bool {
+			return c.waiters[i].at.Before(c.waiters[j].at)
+		})
+		if len(c.waiters) == 0 || c.waiters[0].at.After(now) {
+			break
+		}
+		w := c.waiters[0]
+		if w.at.After(c.now) {
+			c.now = w.at
+		}
+		select {
+		case w.ch <- c.now:
+		default:
+		}
+		if w.period > 0 {
+			w.at = w.at.Add(w.period)
+		} else {
+			c.remove(w)
+		}
+	}
+	if now.After(c.now) {
+		c.now = now
+	}
+}
+
+// BlockUntil wait until at least n timers, tickers or sleeps are waiting for the time to advance.
+// Useful to advance the time only once the goroutines under test are waiting.
+func (c *FakeClock) BlockUntil(n int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for len(c.waiters) < n {
+		c.cond.Wait()
+	}
+}
+
+func (c *FakeClock) newWaiter(d time.Duration, period time.Duration) *fakeWaiter {
+	c.mu.Lock()
+	w := &fakeWaiter{clock: c, period: period, ch: make(chan time.Time, 1)}
+	c.mu.Unlock()
+	w.Reset(d)
+	return w
+}
+
+// remove stop waiting w, return false if w was not waiting. Must be called with mu held.
+func (c *FakeClock) remove(w *fakeWaiter) bool {
+	for i, waiter := range c.waiters {
+		if waiter == w {
+			c.waiters = append(c.waiters[:i], c.waiters[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// fakeWaiter a timer, or a ticker if period is positive, of a FakeClock.
+type fakeWaiter struct {
+	clock  *FakeClock
+	at     time.Time
+	period time.Duration
+	ch     chan time.Time
+}
+
+func (w *fakeWaiter) C() <-chan time.Time {
+	return w.ch
+}
+
+func (w *fakeWaiter) Stop() bool {
+	w.clock.mu.Lock()
+	defer w.clock.mu.Unlock()
+	return w.clock.remove(w)
+}
+
+func (w *fakeWaiter) Reset(d time.Duration) bool {
+	c := w.clock
+	c.mu.Lock()
+	active := c.remove(w)
+	if w.period > 0 {
+		w.period = d
+	}
+	w.at = c.now.Add(d)
+	c.waiters = append(c.waiters, w)
+	c.cond.Broadcast()
+	c.mu.Unlock()
+	if d <= 0 && w.period == 0 {
+		// Fire expired timers immediately, like time.Timer.
+		c.Set(c.Now())
+	}
+	return active
+}
+
+// fakeTicker adapt a fakeWaiter to corefx.Ticker.
+type fakeTicker struct {
+	*fakeWaiter
+}
+
+func (t fakeTicker) Stop() {
+	t.fakeWaiter.Stop()
+}
+
+func (t fakeTicker) Reset(d time.Duration) {
+	if d <= 0 {
+		panic("non-positive interval for FakeClock.Ticker.Reset")
+	}
+	t.fakeWaiter.Reset(d)
+}
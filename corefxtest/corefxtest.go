@@ -0,0 +1,28 @@
+// Package corefxtest provide helpers to test fx apps using corefx, without touching config files, env or the global
+// logger of other tests.
+package corefxtest
+
+import (
+	"github.com/mawngo/go-corefx"
+	"go.uber.org/fx"
+	"log/slog"
+	"testing"
+)
+
+// NewModule create a replacement of corefx.NewModule for tests, which provide cfg as corefx.CoreConfig and load it using
+// only its current values and default tags, instead of config files, dotenv, env and flags.
+// Required fields and validators are still checked. Logs are written into t using NewTestLogger, including fx
+// events logged by corefx.UseSlogLogger, and sentry is disabled as corefx.SentryConfig is not provided.
+// The logger is local to the app, see corefx.WithLocalLogger, so slog.Default and the log package are left untouched.
+// For example, fxtest.New(t, corefxtest.NewModule(t, &myConfig{}), fx.Invoke(...)).
+func NewModule(t testing.TB, cfg corefx.CoreConfig) fx.Option {
+	t.Helper()
+	return fx.Options(
+		corefx.NewModule(),
+		corefx.WithLocalLogger(),
+		fx.Supply(cfg, fx.Annotate(cfg, fx.As(new(corefx.CoreConfig)))),
+		corefx.WithConfigSources(corefx.DefaultsSource()),
+		// Records are filtered by the log levels of the config.
+		corefx.WithLogOutput(NewTestLogger(t, slog.LevelDebug).Handler()),
+	)
+}
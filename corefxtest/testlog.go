@@ -0,0 +1,48 @@
+package corefxtest
+
+import (
+	"log/slog"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// NewTestLogger create a logger that write records into t using t.Logf, records below level are dropped.
+// A nil level drop records below info, like slog handlers. Records logged after the test finished are dropped.
+// The time attribute is omitted, as the test output already show the elapsed time.
+func NewTestLogger(t testing.TB, level slog.Leveler) *slog.Logger {
+	w := &testWriter{t: t}
+	t.Cleanup(w.close)
+	return slog.New(slog.NewTextHandler(w, &slog.HandlerOptions{
+		Level: level,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if len(groups) == 0 && a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+			return a
+		},
+	}))
+}
+
+// testWriter write each record into t.
+type testWriter struct {
+	mu   sync.Mutex
+	t    testing.TB
+	done bool
+}
+
+func (w *testWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	// Logging after the test finished panic.
+	if !w.done {
+		w.t.Logf("%s", strings.TrimSuffix(string(p), "\n"))
+	}
+	return len(p), nil
+}
+
+func (w *testWriter) close() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.done = true
+}
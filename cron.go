@@ -0,0 +1,214 @@
+package corefx
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronDescriptors predefined schedules, as supported by most cron implementations.
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var (
+	cronMonthNames = map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}
+	cronWeekdayNames = map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}
+)
+
+// cronSchedule a parsed cron expression, each field is a bit set of the matching values.
+type cronSchedule struct {
+	minute, hour, dom, month, dow uint64
+	// domAny and dowAny whether the day of month or day of week field is "*", days match both fields if any of them
+	// is "*", otherwise either field.
+	domAny, dowAny bool
+	// every fixed interval of @every schedules, zero for cron expressions.
+	every time.Duration
+	loc   *time.Location
+}
+
+// parseCron parse a cron expression in the standard 5 fields format (minute, hour, day of month, month, day of week),
+// supporting lists, ranges, steps, month and weekday names, and the @yearly, @monthly, @weekly, @daily, @hourly and
+// @every <duration> descriptors.
+// The expression is evaluated in loc, unless prefixed by CRON_TZ=<zone> or TZ=<zone>.
+func parseCron(expr string, loc *time.Location) (*cronSchedule, error) {
+	expr = strings.TrimSpace(expr)
+	if strings.HasPrefix(expr, "CRON_TZ=") || strings.HasPrefix(expr, "TZ=") {
+		zone, rest, _ := strings.Cut(expr, " ")
+		_, zone, _ = strings.Cut(zone, "=")
+		var err error
+		if loc, err = time.LoadLocation(zone); err != nil {
+			return nil, fmt.Errorf("invalid timezone [%s]: %w", zone, err)
+		}
+		expr = strings.TrimSpace(rest)
+	}
+
+	if every, ok := strings.CutPrefix(expr, "@every "); ok {
+		interval, err := time.ParseDuration(strings.TrimSpace(every))
+		if err != nil {
+			return nil, fmt.Errorf("invalid interval: %w", err)
+		}
+		if interval <= 0 {
+			return nil, fmt.Errorf("invalid interval [%s], must be positive", interval)
+		}
+		return &cronSchedule{every: interval, loc: loc}, nil
+	}
+	if descriptor, ok := cronDescriptors[expr]; ok {
+		expr = descriptor
+	}
+
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("expected 5 fields, got %d", len(fields))
+	}
+	s := &cronSchedule{
+		loc:    loc,
+		domAny: strings.HasPrefix(fields[2], "*"),
+		dowAny: strings.HasPrefix(fields[4], "*"),
+	}
+	var err error
+	if s.minute, err = parseCronField(fields[0], 0, 59, nil); err != nil {
+		return nil, fmt.Errorf("invalid minute: %w", err)
+	}
+	if s.hour, err = parseCronField(fields[1], 0, 23, nil); err != nil {
+		return nil, fmt.Errorf("invalid hour: %w", err)
+	}
+	if s.dom, err = parseCronField(fields[2], 1, 31, nil); err != nil {
+		return nil, fmt.Errorf("invalid day of month: %w", err)
+	}
+	if s.month, err = parseCronField(fields[3], 1, 12, cronMonthNames); err != nil {
+		return nil, fmt.Errorf("invalid month: %w", err)
+	}
+	// Both 0 and 7 are sunday.
+	if s.dow, err = parseCronField(fields[4], 0, 7, cronWeekdayNames); err != nil {
+		return nil, fmt.Errorf("invalid day of week: %w", err)
+	}
+	if s.dow&(1<<7) != 0 {
+		s.dow = s.dow&^(1<<7) | 1
+	}
+	return s, nil
+}
+
+// parseCronField parse a comma separated list of values, ranges and steps into a bit set of values between low and
+// high. The names map lowercase names to values, can be nil.
+func parseCronField(field string, low int, high int, names map[string]int) (uint64, error) {
+	var bits uint64
+	for _, part := range strings.Split(field, ",") {
+		values, stepValue, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepValue); err != nil || step <= 0 {
+				return 0, fmt.Errorf("invalid step [%s]", part)
+			}
+		}
+
+		start, end := low, high
+		if values != "*" {
+			startValue, endValue, isRange := strings.Cut(values, "-")
+			var err error
+			if start, err = parseCronValue(startValue, names); err != nil {
+				return 0, err
+			}
+			switch {
+			case isRange:
+				if end, err = parseCronValue(endValue, names); err != nil {
+					return 0, err
+				}
+			case !hasStep:
+				// A single value, while "n/step" means from n to high.
+				end = start
+			}
+		}
+		if start < low || end > high || start > end {
+			return 0, fmt.Errorf("value [%s] out of range [%d-%d]", part, low, high)
+		}
+		for i := start; i <= end; i += step {
+			bits |= 1 << i
+		}
+	}
+	return bits, nil
+}
+
+func parseCronValue(value string, names map[string]int) (int, error) {
+	if v, ok := names[strings.ToLower(value)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value [%s]", value)
+	}
+	return v, nil
+}
+
+// next return the first activation time strictly after t, or zero time if the schedule never activate in the next
+// five years, for example on February 30th.
+// Activations skipped by a DST transition, for example 02:30 when clocks jump from 02:00 to 03:00, do not happen.
+// Activations repeated by a DST transition, for example 01:30 when clocks go back from 02:00 to 01:00, happen once,
+// unless the schedule match every hour, in which case each hour is activated.
+func (s *cronSchedule) next(t time.Time) time.Time {
+	if s.every > 0 {
+		return t.Add(s.every)
+	}
+	t = t.In(s.loc)
+	from := wallClockOf(t)
+	t = t.Add(time.Minute - time.Duration(t.Second())*time.Second - time.Duration(t.Nanosecond()))
+	limit := t.AddDate(5, 0, 0)
+	for t.Before(limit) {
+		if s.month&(1<<int(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, s.loc)
+			continue
+		}
+		if !s.matchDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, s.loc)
+			continue
+		}
+		// Hours and minutes are added instead of using time.Date, so ambiguous times of DST transitions do not move
+		// backward.
+		if s.hour&(1<<t.Hour()) == 0 {
+			t = t.Add(time.Duration(60-t.Minute()) * time.Minute)
+			continue
+		}
+		if s.minute&(1<<t.Minute()) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		// The clock went back, skip the times already activated before the transition.
+		if s.hour != cronAllHours && !wallClockOf(t).After(from) {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// cronAllHours hour field matching every hour.
+const cronAllHours = 1<<24 - 1
+
+// wallClockOf return the wall clock time of t, truncated to the minute, ignoring its timezone offset.
+func wallClockOf(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), t.Minute(), 0, 0, time.UTC)
+}
+
+// matchDay whether the day of t match the day of month and day of week fields.
+func (s *cronSchedule) matchDay(t time.Time) bool {
+	dom := s.dom&(1<<t.Day()) != 0
+	dow := s.dow&(1<<int(t.Weekday())) != 0
+	if s.domAny || s.dowAny {
+		return dom && dow
+	}
+	return dom || dow
+}
@@ -0,0 +1,218 @@
+package corefx
+
+import (
+	"strings"
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func TestParseCron(t *testing.T) {
+	tests := []struct {
+		expr    string
+		wantErr string
+	}{
+		{expr: "* * * * *"},
+		{expr: "*/15 0-6,22-23 1,15 jan-mar,dec mon-fri"},
+		{expr: "0 9 * * 7"},
+		{expr: "5/10 * * * *"},
+		{expr: "@daily"},
+		{expr: "@every 90s"},
+		{expr: "CRON_TZ=America/New_York 0 3 * * *"},
+		{expr: "TZ=UTC @hourly"},
+		{expr: "* * * *", wantErr: "expected 5 fields, got 4"},
+		{expr: "60 * * * *", wantErr: "invalid minute"},
+		{expr: "* 24 * * *", wantErr: "invalid hour"},
+		{expr: "* * 0 * *", wantErr: "invalid day of month"},
+		{expr: "* * * 13 *", wantErr: "invalid month"},
+		{expr: "* * * * 8", wantErr: "invalid day of week"},
+		{expr: "5-1 * * * *", wantErr: "out of range"},
+		{expr: "*/0 * * * *", wantErr: "invalid step"},
+		{expr: "x * * * *", wantErr: "invalid value [x]"},
+		{expr: "@every 0s", wantErr: "must be positive"},
+		{expr: "@every soon", wantErr: "invalid interval"},
+		{expr: "CRON_TZ=Nowhere/City * * * * *", wantErr: "invalid timezone"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			_, err := parseCron(tt.expr, time.UTC)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("parseCron(%q) error = %v", tt.expr, err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("parseCron(%q) error = %v, want %q", tt.expr, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestParseCronFields(t *testing.T) {
+	s, err := parseCron("*/20 1-3 10/10 jan,JUL sun,7", time.UTC)
+	if err != nil {
+		t.Fatalf("parseCron() error = %v", err)
+	}
+	want := cronSchedule{
+		minute: 1<<0 | 1<<20 | 1<<40,
+		hour:   1<<1 | 1<<2 | 1<<3,
+		dom:    1<<10 | 1<<20 | 1<<30,
+		month:  1<<1 | 1<<7,
+		// 7 is sunday.
+		dow: 1 << 0,
+		loc: time.UTC,
+	}
+	if *s != want {
+		t.Errorf("parseCron() = %+v, want %+v", *s, want)
+	}
+}
+
+func TestCronNext(t *testing.T) {
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name string
+		expr string
+		loc  *time.Location
+		from string
+		want []string
+	}{
+		{
+			name: "every minute truncate seconds",
+			expr: "* * * * *",
+			from: "2024-01-01T10:00:30Z",
+			want: []string{"2024-01-01T10:01:00Z", "2024-01-01T10:02:00Z"},
+		},
+		{
+			name: "strictly after",
+			expr: "0 10 * * *",
+			from: "2024-01-01T10:00:00Z",
+			want: []string{"2024-01-02T10:00:00Z"},
+		},
+		{
+			name: "steps and ranges",
+			expr: "*/20 9-10 * * *",
+			from: "2024-01-01T10:30:00Z",
+			want: []string{"2024-01-01T10:40:00Z", "2024-01-02T09:00:00Z", "2024-01-02T09:20:00Z"},
+		},
+		{
+			name: "month rollover",
+			expr: "0 0 1 mar *",
+			from: "2024-01-15T00:00:00Z",
+			want: []string{"2024-03-01T00:00:00Z", "2025-03-01T00:00:00Z"},
+		},
+		{
+			name: "leap day",
+			expr: "0 0 29 2 *",
+			from: "2024-03-01T00:00:00Z",
+			want: []string{"2028-02-29T00:00:00Z"},
+		},
+		{
+			name: "never",
+			expr: "0 0 30 2 *",
+			from: "2024-01-01T00:00:00Z",
+			want: []string{"0001-01-01T00:00:00Z"},
+		},
+		{
+			name: "day of week 7 is sunday",
+			expr: "0 12 * * 7",
+			// 2024-01-01 is a monday.
+			from: "2024-01-01T00:00:00Z",
+			want: []string{"2024-01-07T12:00:00Z", "2024-01-14T12:00:00Z"},
+		},
+		{
+			name: "day of month or day of week",
+			expr: "0 0 10 * mon",
+			from: "2024-01-01T00:00:00Z",
+			want: []string{"2024-01-08T00:00:00Z", "2024-01-10T00:00:00Z", "2024-01-15T00:00:00Z"},
+		},
+		{
+			name: "day of week only",
+			expr: "0 0 * * mon",
+			from: "2024-01-01T00:00:00Z",
+			want: []string{"2024-01-08T00:00:00Z", "2024-01-15T00:00:00Z"},
+		},
+		{
+			name: "day of month starting with star and day of week",
+			expr: "0 0 */10 * mon",
+			from: "2023-12-31T00:00:00Z",
+			want: []string{"2024-01-01T00:00:00Z", "2024-03-11T00:00:00Z"},
+		},
+		{
+			name: "every",
+			expr: "@every 90s",
+			from: "2024-01-01T00:00:10Z",
+			want: []string{"2024-01-01T00:01:40Z", "2024-01-01T00:03:10Z"},
+		},
+		{
+			name: "descriptor",
+			expr: "@weekly",
+			from: "2024-01-01T00:00:00Z",
+			want: []string{"2024-01-07T00:00:00Z"},
+		},
+		{
+			name: "timezone prefix",
+			expr: "CRON_TZ=America/New_York 0 3 * * *",
+			from: "2024-01-01T00:00:00Z",
+			want: []string{"2024-01-01T08:00:00Z", "2024-01-02T08:00:00Z"},
+		},
+		{
+			name: "dst gap is skipped",
+			expr: "30 2 * * *",
+			loc:  newYork,
+			from: "2024-03-09T03:00:00-05:00",
+			want: []string{"2024-03-11T02:30:00-04:00"},
+		},
+		{
+			name: "dst gap every hour",
+			expr: "30 * * * *",
+			loc:  newYork,
+			from: "2024-03-10T01:00:00-05:00",
+			want: []string{"2024-03-10T01:30:00-05:00", "2024-03-10T03:30:00-04:00"},
+		},
+		{
+			name: "dst repeated time run once",
+			expr: "30 1 * * *",
+			loc:  newYork,
+			from: "2024-11-03T00:00:00-04:00",
+			want: []string{"2024-11-03T01:30:00-04:00", "2024-11-04T01:30:00-05:00"},
+		},
+		{
+			name: "dst repeated hour every hour",
+			expr: "30 * * * *",
+			loc:  newYork,
+			from: "2024-11-03T00:45:00-04:00",
+			want: []string{"2024-11-03T01:30:00-04:00", "2024-11-03T01:30:00-05:00", "2024-11-03T02:30:00-05:00"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			loc := tt.loc
+			if loc == nil {
+				loc = time.UTC
+			}
+			s, err := parseCron(tt.expr, loc)
+			if err != nil {
+				t.Fatalf("parseCron(%q) error = %v", tt.expr, err)
+			}
+			from, err := time.Parse(time.RFC3339, tt.from)
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, w := range tt.want {
+				want, err := time.Parse(time.RFC3339, w)
+				if err != nil {
+					t.Fatal(err)
+				}
+				next := s.next(from)
+				if !next.Equal(want) {
+					t.Fatalf("next(%s) = %s, want %s", from.Format(time.RFC3339), next.Format(time.RFC3339), w)
+				}
+				from = next
+			}
+		})
+	}
+}
@@ -0,0 +1,236 @@
+package corefx
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"expvar"
+	"fmt"
+	"go.uber.org/fx"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/pprof"
+	"runtime/debug"
+	"strings"
+)
+
+type DebugConfig interface {
+	// DebugAddrValue address of the debug server, for example "localhost:6060".
+	// Listening on all interfaces, for example ":6060", expose the profiles and admin endpoints to the network,
+	// which should be protected using the debug auth.
+	DebugAddrValue() string
+	// DebugEnabledValue force enable the debug server in production profile.
+	// The debug server is always enabled in other profiles.
+	DebugEnabledValue() bool
+	// DebugTLSCertFileValue certificate file, or inline PEM, to serve TLS, empty to serve plain HTTP.
+	DebugTLSCertFileValue() string
+	// DebugTLSKeyFileValue private key file, or inline PEM, of the TLS certificate.
+	DebugTLSKeyFileValue() string
+	// DebugTLSCAFileValue certificate authorities file, or inline PEM, to verify client certificates.
+	// If set, clients must present a certificate signed by them (mTLS). Require TLS.
+	DebugTLSCAFileValue() string
+	// DebugAuthTokenValue bearer token required to access the debug server.
+	DebugAuthTokenValue() string
+	// DebugAuthUsernameValue username of the basic auth required to access the debug server.
+	DebugAuthUsernameValue() string
+	// DebugAuthPasswordValue password of the basic auth required to access the debug server.
+	DebugAuthPasswordValue() string
+}
+
+type DebugEnv struct {
+	DebugAddr         string `json:"debug_addr" mapstructure:"debug_addr" default:"localhost:6060"`
+	DebugEnabled      bool   `json:"debug_enabled" mapstructure:"debug_enabled"`
+	DebugTLSCertFile  string `json:"debug_tls_cert_file" mapstructure:"debug_tls_cert_file"`
+	DebugTLSKeyFile   string `json:"debug_tls_key_file" mapstructure:"debug_tls_key_file" secret:"true"`
+	DebugTLSCAFile    string `json:"debug_tls_ca_file" mapstructure:"debug_tls_ca_file"`
+	DebugAuthToken    string `json:"debug_auth_token" mapstructure:"debug_auth_token" secret:"true"`
+	DebugAuthUsername string `json:"debug_auth_username" mapstructure:"debug_auth_username"`
+	DebugAuthPassword string `json:"debug_auth_password" mapstructure:"debug_auth_password" secret:"true"`
+}
+
+func (e DebugEnv) DebugAddrValue() string {
+	return e.DebugAddr
+}
+
+func (e DebugEnv) DebugEnabledValue() bool {
+	return e.DebugEnabled
+}
+
+func (e DebugEnv) DebugTLSCertFileValue() string {
+	return e.DebugTLSCertFile
+}
+
+func (e DebugEnv) DebugTLSKeyFileValue() string {
+	return e.DebugTLSKeyFile
+}
+
+func (e DebugEnv) DebugTLSCAFileValue() string {
+	return e.DebugTLSCAFile
+}
+
+func (e DebugEnv) DebugAuthTokenValue() string {
+	return e.DebugAuthToken
+}
+
+func (e DebugEnv) DebugAuthUsernameValue() string {
+	return e.DebugAuthUsername
+}
+
+func (e DebugEnv) DebugAuthPasswordValue() string {
+	return e.DebugAuthPassword
+}
+
+var _ DebugConfig = (*DebugEnv)(nil)
+
+type DebugServerParams struct {
+	fx.In
+	Loaded    loadedConfig
+	Config    DebugConfig      `optional:"true"`
+	Levels    *LevelController `optional:"true"`
+	Watcher   *ConfigWatcher   `optional:"true"`
+	Workers   *WorkerManager   `optional:"true"`
+	Logger    *slog.Logger
+	Lifecycle fx.Lifecycle
+}
+
+// NewDebugServer create the debug server, which serve:
+//   - /debug/pprof/ the net/http/pprof profiles.
+//   - /debug/vars the expvar variables.
+//   - /debug/info the build info, the effective config and its origins, with secret values masked, and the worker
+//     states if NewWorkerModule is used.
+//   - /admin/loglevel the global log level, which can be changed using PUT, see LevelController.
+//
+// Requests must be authenticated using the bearer token or the basic auth of the config if set, and the server
+// require client certificates if the TLS CA is set.
+// Return nil server if disabled.
+func NewDebugServer(p DebugServerParams) (*http.Server, error) {
+	config := p.Config
+	if config == nil {
+		env := DebugEnv{}
+		if err := applyDefaults(&env); err != nil {
+			return nil, err
+		}
+		config = env
+	}
+	if p.Loaded.config.IsProd() && !config.DebugEnabledValue() {
+		p.Logger.Debug("Debug server is disabled in production profile")
+		return nil, nil
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+	if p.Levels != nil {
+		mux.Handle("/admin/loglevel", p.Levels)
+	}
+	mux.HandleFunc("/debug/info", func(w http.ResponseWriter, _ *http.Request) {
+		dump, err := DumpConfig(p.Loaded.config)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		info := map[string]any{"config": dump}
+		if p.Watcher != nil {
+			if report, err := p.Watcher.ConfigReport(); err == nil {
+				info["config_origins"] = report
+			}
+		}
+		if p.Workers != nil {
+			info["workers"] = p.Workers.States()
+		}
+		info["build_info"] = ReadBuildInfo()
+		if build, ok := debug.ReadBuildInfo(); ok {
+			info["build"] = build
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(info)
+	})
+
+	var handler http.Handler = mux
+	if config.DebugAuthTokenValue() != "" || config.DebugAuthUsernameValue() != "" {
+		handler = debugAuthMiddleware(config, handler)
+	} else if config.DebugTLSCAFileValue() == "" && (p.Loaded.config.IsProd() || !isLoopbackAddr(config.DebugAddrValue())) {
+		p.Logger.Warn("Debug server is enabled without authentication", slog.String("addr", config.DebugAddrValue()))
+	}
+	server := &http.Server{
+		Addr:    config.DebugAddrValue(),
+		Handler: handler,
+	}
+	if config.DebugTLSCAFileValue() != "" && config.DebugTLSCertFileValue() == "" {
+		return nil, errors.New("error debug server tls ca requires tls cert")
+	}
+	if config.DebugTLSCertFileValue() != "" {
+		t, err := NewTLS(p.Logger, TLSEnv{
+			TLSCert:       config.DebugTLSCertFileValue(),
+			TLSKey:        config.DebugTLSKeyFileValue(),
+			TLSCA:         config.DebugTLSCAFileValue(),
+			TLSClientAuth: config.DebugTLSCAFileValue() != "",
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error loading debug server tls: %w", err)
+		}
+		server.TLSConfig = t.ServerConfig()
+	}
+	serveHTTP(p.Lifecycle, p.Logger, server)
+	return server, nil
+}
+
+// debugAuthMiddleware require requests to carry the bearer token or the basic auth of the config.
+func debugAuthMiddleware(config DebugConfig, next http.Handler) http.Handler {
+	token := config.DebugAuthTokenValue()
+	username := config.DebugAuthUsernameValue()
+	password := config.DebugAuthPasswordValue()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if token != "" {
+			if bearer, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok &&
+				subtle.ConstantTimeCompare([]byte(bearer), []byte(token)) == 1 {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+		if username != "" {
+			if u, pw, ok := r.BasicAuth(); ok &&
+				subtle.ConstantTimeCompare([]byte(u), []byte(username))&
+					subtle.ConstantTimeCompare([]byte(pw), []byte(password)) == 1 {
+				next.ServeHTTP(w, r)
+				return
+			}
+			w.Header().Set("WWW-Authenticate", `Basic realm="debug"`)
+		} else {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+		}
+		http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+	})
+}
+
+// NewDebugModule serve pprof, expvar and build/config info on a separate debug server.
+// The server is provided as *http.Server named "debug_server".
+// Must be used together with NewModule.
+// The debug server is disabled in production profile unless explicitly enabled.
+// The env config can register as DebugConfig to configure the server, otherwise the DebugEnv defaults are used.
+func NewDebugModule() fx.Option {
+	return fx.Module("corefx.debug",
+		fx.Provide(fx.Annotate(NewDebugServer, fx.ResultTags(`name:"debug_server"`))),
+		fx.Invoke(fx.Annotate(func(_ *http.Server) {
+			// force initialization of the server.
+		}, fx.ParamTags(`name:"debug_server"`))),
+	)
+}
+
+// isLoopbackAddr whether addr only listen on the loopback interface.
+func isLoopbackAddr(addr string) bool {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return false
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -0,0 +1,67 @@
+package corefx
+
+import (
+	"errors"
+	"fmt"
+	"github.com/mitchellh/mapstructure"
+	"reflect"
+)
+
+// applyDefaults set fields tagged with `default:"value"` that are still zero, including fields of nested structs.
+// Values are decoded the same as config values, for example durations use time.ParseDuration and slices are comma
+// separated like `default:"a,b,c"`.
+// Defaults are applied before config files and env are merged, so they have the lowest precedence.
+func applyDefaults(cfg any) error {
+	var errs []error
+	walkDefaults(reflect.ValueOf(cfg), "", &errs)
+	return errors.Join(errs...)
+}
+
+func walkDefaults(v reflect.Value, fieldPath string, errs *[]error) {
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if !v.IsNil() {
+			walkDefaults(v.Elem(), fieldPath, errs)
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			childFieldPath := joinConfigPath(fieldPath, field.Name)
+			if field.Anonymous && field.Type.Kind() == reflect.Struct {
+				childFieldPath = fieldPath
+			}
+
+			value := v.Field(i)
+			if def, ok := field.Tag.Lookup("default"); ok && value.IsZero() && value.CanSet() {
+				if err := decodeDefault(def, value); err != nil {
+					*errs = append(*errs, fmt.Errorf("[%s] invalid default value [%s]: %w", childFieldPath, def, err))
+				}
+				continue
+			}
+			walkDefaults(value, childFieldPath, errs)
+		}
+	default:
+	}
+}
+
+// decodeDefault decode the default tag value into v.
+func decodeDefault(def string, v reflect.Value) error {
+	target := reflect.New(v.Type())
+	decoder, err := mapstructure.NewDecoder(&mapstructure.DecoderConfig{
+		DecodeHook:       configDecodeHook(),
+		WeaklyTypedInput: true,
+		Result:           target.Interface(),
+	})
+	if err != nil {
+		return err
+	}
+	if err := decoder.Decode(def); err != nil {
+		return err
+	}
+	v.Set(target.Elem())
+	return nil
+}
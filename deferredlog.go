@@ -0,0 +1,173 @@
+package corefx
+
+import (
+	"context"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// maxDeferredRecords max number of records buffered before the global logger is created, later records are dropped.
+const maxDeferredRecords = 1000
+
+// deferredLogs buffer of the deferred logger installed by NewModule, until the global logger is created.
+var deferredLogs atomic.Pointer[deferredState]
+
+// installDeferredLogger set slog.Default to a logger that buffer records until the global logger is created,
+// so records logged before the config is loaded are written using the configured format and level.
+func installDeferredLogger() {
+	if _, ok := slog.Default().Handler().(*deferredHandler); ok {
+		return
+	}
+	state := &deferredState{previous: slog.Default(), logWriter: log.Writer(), logFlags: log.Flags()}
+	deferredLogs.Store(state)
+	slog.SetDefault(slog.New(&deferredHandler{state: state}))
+}
+
+// replayDeferredLogs write the buffered records into handler, later records are passed directly to handler.
+func replayDeferredLogs(handler slog.Handler) {
+	if state := deferredLogs.Swap(nil); state != nil {
+		state.replay(handler)
+	}
+}
+
+// restoreDeferredLogger write the buffered records into handler like replayDeferredLogs, then restore slog.Default
+// and the output of the log package to the ones before the deferred logger was installed.
+func restoreDeferredLogger(handler slog.Handler) {
+	state := deferredLogs.Swap(nil)
+	if state == nil {
+		return
+	}
+	state.replay(handler)
+	state.detach()
+}
+
+// detachDeferredLogger restore slog.Default and the output of the log package to the ones before the deferred logger
+// was installed, the buffered records are kept until replayed. Loggers derived from the deferred logger keep
+// buffering records until then.
+func detachDeferredLogger() {
+	if state := deferredLogs.Load(); state != nil {
+		state.detach()
+	}
+}
+
+// discardDeferredLogs write the buffered records into stderr and restore the previous slog.Default and output of the
+// log package, used when the global logger cannot be created. Only warn and error records are written, like the
+// default logger before setup. Does nothing if the deferred logger is not installed.
+func discardDeferredLogs() {
+	restoreDeferredLogger(slog.NewTextHandler(os.Stderr, &slog.HandlerOptions{Level: slog.LevelWarn}))
+}
+
+type deferredRecord struct {
+	ctx     context.Context
+	record  slog.Record
+	handler *deferredHandler
+}
+
+// deferredState records shared by a deferred handler and its derived handlers.
+type deferredState struct {
+	mu      sync.Mutex
+	records []deferredRecord
+	dropped int
+	target  slog.Handler
+	// previous slog.Default and log package output before the deferred logger was installed.
+	previous  *slog.Logger
+	logWriter io.Writer
+	logFlags  int
+}
+
+// detach restore slog.Default and the output of the log package, if the deferred logger of s is still the default.
+func (s *deferredState) detach() {
+	if h, ok := slog.Default().Handler().(*deferredHandler); ok && h.state == s {
+		slog.SetDefault(s.previous)
+		log.SetOutput(s.logWriter)
+		log.SetFlags(s.logFlags)
+	}
+}
+
+func (s *deferredState) replay(target slog.Handler) {
+	s.mu.Lock()
+	records, dropped := s.records, s.dropped
+	s.records, s.dropped, s.target = nil, 0, target
+	s.mu.Unlock()
+
+	for _, r := range records {
+		handler := r.handler.handlerOf(target)
+		if handler.Enabled(r.ctx, r.record.Level) {
+			_ = handler.Handle(r.ctx, r.record)
+		}
+	}
+	if dropped > 0 && target.Enabled(context.Background(), slog.LevelWarn) {
+		r := slog.NewRecord(time.Now(), slog.LevelWarn, "Dropped log records logged before setup", 0)
+		r.AddAttrs(slog.Int("dropped", dropped))
+		_ = target.Handle(context.Background(), r)
+	}
+}
+
+// deferredHandler buffer records until a target handler is set, then pass records to the target handler.
+type deferredHandler struct {
+	state  *deferredState
+	parent *deferredHandler
+	// derive apply the attributes or group of this handler to the handler of parent.
+	derive func(slog.Handler) slog.Handler
+}
+
+// handlerOf return the target handler with the attributes and groups of h.
+func (h *deferredHandler) handlerOf(target slog.Handler) slog.Handler {
+	if h.parent == nil {
+		return target
+	}
+	return h.derive(h.parent.handlerOf(target))
+}
+
+func (h *deferredHandler) targetOf() slog.Handler {
+	h.state.mu.Lock()
+	defer h.state.mu.Unlock()
+	return h.state.target
+}
+
+func (h *deferredHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	if target := h.targetOf(); target != nil {
+		return h.handlerOf(target).Enabled(ctx, level)
+	}
+	// Buffer all records, they are filtered when replayed.
+	return true
+}
+
+func (h *deferredHandler) Handle(ctx context.Context, r slog.Record) error {
+	h.state.mu.Lock()
+	target := h.state.target
+	if target == nil {
+		if len(h.state.records) < maxDeferredRecords {
+			h.state.records = append(h.state.records, deferredRecord{ctx: ctx, record: r.Clone(), handler: h})
+		} else {
+			h.state.dropped++
+		}
+		h.state.mu.Unlock()
+		return nil
+	}
+	h.state.mu.Unlock()
+	return h.handlerOf(target).Handle(ctx, r)
+}
+
+func (h *deferredHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+	return &deferredHandler{state: h.state, parent: h, derive: func(next slog.Handler) slog.Handler {
+		return next.WithAttrs(attrs)
+	}}
+}
+
+func (h *deferredHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	return &deferredHandler{state: h.state, parent: h, derive: func(next slog.Handler) slog.Handler {
+		return next.WithGroup(name)
+	}}
+}
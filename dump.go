@@ -0,0 +1,113 @@
+package corefx
+
+import (
+	"log/slog"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// SecretMask the value that replace secret config values in config dump.
+const SecretMask = "******"
+
+// secretKeyNames config keys named like these, or suffixed by _name, are considered secret even without the secret tag.
+var secretKeyNames = []string{"password", "dsn", "token", "secret"}
+
+// DumpConfig return the config as a JSON-marshalable map, with secret values masked.
+// A value is secret if its field is tagged with `secret:"true"`,
+// or its key is named like *_password, *_dsn, *_token or *_secret.
+func DumpConfig(cfg any) (map[string]any, error) {
+	dump, err := configMapOf(cfg)
+	if err != nil {
+		return nil, err
+	}
+	maskSecrets(dump, reflect.TypeOf(cfg))
+	return dump, nil
+}
+
+// logEffectiveConfig log the effective config at debug level.
+func logEffectiveConfig(logger *slog.Logger, cfg CoreConfig) {
+	dump, err := DumpConfig(cfg)
+	if err != nil {
+		logger.Warn("Cannot dump effective config", slog.Any("err", err))
+		return
+	}
+	logger.Debug("Effective config", slog.Any("config", dump))
+}
+
+// maskSecrets mask secret values of data, which is the json representation of a value of type t.
+// The type t is used to detect fields with secret tag, t can be nil if unknown.
+func maskSecrets(data any, t reflect.Type) {
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch data := data.(type) {
+	case map[string]any:
+		fields := map[string]reflect.StructField{}
+		if t != nil && t.Kind() == reflect.Struct {
+			collectConfigFields(t, fields)
+		}
+		for key, value := range data {
+			field, ok := fields[key]
+			if isSecretKey(key) || (ok && isSecretField(field)) {
+				data[key] = maskSecret(value)
+				continue
+			}
+			switch {
+			case ok:
+				maskSecrets(value, field.Type)
+			case t != nil && t.Kind() == reflect.Map:
+				maskSecrets(value, t.Elem())
+			default:
+				maskSecrets(value, nil)
+			}
+		}
+	case []any:
+		var elemType reflect.Type
+		if t != nil && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+			elemType = t.Elem()
+		}
+		for i := range data {
+			maskSecrets(data[i], elemType)
+		}
+	}
+}
+
+// collectConfigFields collect fields of struct type t by their config name, including fields of embedded structs.
+func collectConfigFields(t reflect.Type, fields map[string]reflect.StructField) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			collectConfigFields(field.Type, fields)
+			continue
+		}
+		fields[configNameOf(field)] = field
+	}
+}
+
+func isSecretField(field reflect.StructField) bool {
+	secret, _ := strconv.ParseBool(field.Tag.Get("secret"))
+	return secret
+}
+
+func isSecretKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, name := range secretKeyNames {
+		if key == name || strings.HasSuffix(key, "_"+name) {
+			return true
+		}
+	}
+	return false
+}
+
+// maskSecret mask a secret value, empty values are kept to show that the secret is not configured.
+func maskSecret(value any) any {
+	if value == nil || value == "" {
+		return value
+	}
+	return SecretMask
+}
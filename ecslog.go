@@ -0,0 +1,60 @@
+package corefx
+
+import (
+	"io"
+	"log/slog"
+	"strings"
+)
+
+// ecsVersion version of the Elastic Common Schema written by newECSHandler.
+const ecsVersion = "8.11.0"
+
+// ecsFieldNames ECS field name of the attributes added by corefx.
+var ecsFieldNames = map[string]string{
+	"app":        "service.name",
+	"version":    "service.version",
+	"profile":    "service.environment",
+	"trace_id":   "trace.id",
+	"span_id":    "span.id",
+	"request_id": "http.request.id",
+	"err":        "error.message",
+	"error":      "error.message",
+}
+
+// newECSHandler create a JSON handler writing records using the Elastic Common Schema field names:
+// @timestamp, log.level, message, log.origin, and the ECS fields of the app, trace and error attributes.
+func newECSHandler(w io.Writer, level slog.Leveler, addSource bool) slog.Handler {
+	handler := slog.NewJSONHandler(w, &slog.HandlerOptions{
+		Level:     level,
+		AddSource: addSource,
+		ReplaceAttr: func(groups []string, attr slog.Attr) slog.Attr {
+			if len(groups) > 0 {
+				return attr
+			}
+			switch attr.Key {
+			case slog.TimeKey:
+				attr.Key = "@timestamp"
+			case slog.LevelKey:
+				attr.Key = "log.level"
+				attr.Value = slog.StringValue(strings.ToLower(attr.Value.String()))
+			case slog.MessageKey:
+				attr.Key = "message"
+			case slog.SourceKey:
+				source, ok := attr.Value.Any().(*slog.Source)
+				if !ok || source == nil {
+					return attr
+				}
+				return slog.Group("log.origin",
+					slog.Group("file", slog.String("name", source.File), slog.Int("line", source.Line)),
+					slog.String("function", source.Function),
+				)
+			default:
+				if name, ok := ecsFieldNames[attr.Key]; ok {
+					attr.Key = name
+				}
+			}
+			return attr
+		},
+	})
+	return handler.WithAttrs([]slog.Attr{slog.String("ecs.version", ecsVersion)})
+}
@@ -0,0 +1,179 @@
+package corefx
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+var byteSizeType = reflect.TypeOf(ByteSize(0))
+
+// EnvVar an env variable that a config struct can consume.
+type EnvVar struct {
+	// Name of the env variable, for example DATABASE__URL.
+	Name string `json:"name"`
+	// Key config key set by the env variable, for example database.url.
+	Key string `json:"key"`
+	// Type of the value: string, bool, int, uint, float, duration, time, bytesize or list (comma separated).
+	Type string `json:"type"`
+	// Default value, empty for secrets.
+	Default string `json:"default,omitempty"`
+	// Description read from the `desc:"..."` tag.
+	Description string `json:"description,omitempty"`
+	Required    bool   `json:"required"`
+	Secret      bool   `json:"secret"`
+}
+
+// EnvVarsOf list the env variables that the config struct cfg can consume, in field declaration order, so deployment
+// manifests can be kept in sync with the config.
+// Names are derived from the json tags of fields, nested keys are separated by "__", and prefixed by
+// AppEnvPrefixValue if cfg implement CoreConfig. Map fields are not listed, as they cannot be set using env variables.
+// Use WriteEnvVarsTable to render the result as a table, or encoding/json for machine-readable output.
+func EnvVarsOf(cfg any) ([]EnvVar, error) {
+	value, err := defaultedConfigOf(cfg)
+	if err != nil {
+		return nil, err
+	}
+	envPrefix := ""
+	if c, ok := cfg.(CoreConfig); ok {
+		envPrefix = c.AppEnvPrefixValue()
+	}
+	var vars []EnvVar
+	collectEnvVars(value.Type(), value, "", envPrefix, &vars)
+	return vars, nil
+}
+
+// collectEnvVars append the env variables of fields of struct type t into vars, including fields of embedded and
+// nested structs. The v is the value used to read defaults and can be invalid.
+func collectEnvVars(t reflect.Type, v reflect.Value, configPath string, envPrefix string, vars *[]EnvVar) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		var value reflect.Value
+		if v.IsValid() {
+			value = v.Field(i)
+		}
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			collectEnvVars(field.Type, value, configPath, envPrefix, vars)
+			continue
+		}
+		name := configNameOf(field)
+		if name == "-" {
+			continue
+		}
+
+		key := joinConfigPath(configPath, name)
+		fieldType, fieldValue := field.Type, value
+		for fieldType.Kind() == reflect.Pointer {
+			fieldType = fieldType.Elem()
+			if fieldValue.IsValid() {
+				fieldValue = fieldValue.Elem()
+			}
+		}
+		if isNestedStruct(fieldType) {
+			collectEnvVars(fieldType, fieldValue, key, envPrefix, vars)
+			continue
+		}
+		envType := envTypeOf(fieldType)
+		if envType == "" {
+			continue
+		}
+
+		required, _ := requiredOf(field)
+		*vars = append(*vars, EnvVar{
+			Name:        envNameOf(key, envPrefix),
+			Key:         key,
+			Type:        envType,
+			Default:     envValueOf(fieldDefaultOf(field, name, value)),
+			Description: field.Tag.Get("desc"),
+			Required:    required,
+			Secret:      isSecretKey(name) || isSecretField(field),
+		})
+	}
+}
+
+// envTypeOf return the type name of env variables of type t, or empty if t cannot be set using an env variable.
+func envTypeOf(t reflect.Type) string {
+	switch {
+	case t == durationType:
+		return "duration"
+	case t == timeType:
+		return "time"
+	case t == byteSizeType:
+		return "bytesize"
+	case reflect.PointerTo(t).Implements(textUnmarshalerType):
+		return "string"
+	}
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "bool"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return "int"
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "uint"
+	case reflect.Float32, reflect.Float64:
+		return "float"
+	case reflect.Slice:
+		elem := t.Elem()
+		for elem.Kind() == reflect.Pointer {
+			elem = elem.Elem()
+		}
+		if isNestedStruct(elem) || envTypeOf(elem) == "" || envTypeOf(elem) == "list" {
+			return ""
+		}
+		return "list"
+	case reflect.Interface:
+		return "string"
+	default:
+		return ""
+	}
+}
+
+// envValueOf format the JSON representation of a default value the same as its env variable value.
+func envValueOf(value any) string {
+	switch value := value.(type) {
+	case nil:
+		return ""
+	case []any:
+		values := make([]string, 0, len(value))
+		for _, v := range value {
+			values = append(values, envValueOf(v))
+		}
+		return strings.Join(values, ",")
+	case float64:
+		// Avoid exponent format of large integers.
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	default:
+		return fmt.Sprint(value)
+	}
+}
+
+// WriteEnvVarsTable write env variables into w as a table aligned by tabs.
+func WriteEnvVarsTable(w io.Writer, vars []EnvVar) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	if _, err := fmt.Fprintln(tw, "NAME\tTYPE\tDEFAULT\tREQUIRED\tDESCRIPTION"); err != nil {
+		return err
+	}
+	for _, v := range vars {
+		def := v.Default
+		if v.Secret {
+			def = "(secret)"
+		}
+		required := ""
+		if v.Required {
+			required = "yes"
+		}
+		description := strings.ReplaceAll(v.Description, "\n", " ")
+		if _, err := fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", v.Name, v.Type, def, required, description); err != nil {
+			return err
+		}
+	}
+	return tw.Flush()
+}
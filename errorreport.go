@@ -0,0 +1,420 @@
+package corefx
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go.uber.org/fx"
+	"log/slog"
+	"net/http"
+	"os"
+	"runtime"
+	"sync"
+	"time"
+)
+
+const (
+	rollbarEndpoint = "https://api.rollbar.com/api/1/item/"
+	bugsnagEndpoint = "https://notify.bugsnag.com/"
+	// errorReportQueueSize max number of reports waiting to be sent, later reports are dropped.
+	errorReportQueueSize = 256
+)
+
+type ErrorReportConfig interface {
+	// ErrorReportLevelValue min level of records reported to the error reporters, default to error.
+	ErrorReportLevelValue() string
+	// RollbarTokenValue post_server_item access token of Rollbar, empty to disable Rollbar.
+	RollbarTokenValue() string
+	// BugsnagAPIKeyValue notifier api key of Bugsnag, empty to disable Bugsnag.
+	BugsnagAPIKeyValue() string
+}
+
+type ErrorReportEnv struct {
+	ErrorReportLevel string `json:"error_report_level" mapstructure:"error_report_level" default:"error"`
+	RollbarToken     string `json:"rollbar_token" mapstructure:"rollbar_token" secret:"true"`
+	BugsnagAPIKey    string `json:"bugsnag_api_key" mapstructure:"bugsnag_api_key" secret:"true"`
+}
+
+func (e ErrorReportEnv) ErrorReportLevelValue() string {
+	return e.ErrorReportLevel
+}
+
+func (e ErrorReportEnv) RollbarTokenValue() string {
+	return e.RollbarToken
+}
+
+func (e ErrorReportEnv) BugsnagAPIKeyValue() string {
+	return e.BugsnagAPIKey
+}
+
+var _ ErrorReportConfig = (*ErrorReportEnv)(nil)
+
+// ErrorReport a log record reported to an ErrorReporter.
+type ErrorReport struct {
+	Time    time.Time
+	Level   slog.Level
+	Message string
+	// Err first error attribute of the record, nil if none.
+	Err error
+	// Attrs attributes of the record, keys of groups are flattened using "." separator.
+	Attrs map[string]any
+	// Source location of the log statement, nil if unknown.
+	Source *slog.Source
+}
+
+// ErrorReporter report log records to an error tracking service.
+type ErrorReporter interface {
+	Report(ctx context.Context, report ErrorReport) error
+}
+
+// AsErrorReporter annotate an ErrorReporter constructor to register it into the error reporter group.
+// The constructor must not depend on *slog.Logger, as reporters are created together with the logger.
+// For example, fx.Provide(corefx.AsErrorReporter(newMyReporter)).
+func AsErrorReporter(f any) any {
+	return fx.Annotate(
+		f,
+		fx.ResultTags(`group:"error_reporters"`),
+	)
+}
+
+// ErrorReportDispatcher send log records to the error reporters in background.
+type ErrorReportDispatcher struct {
+	reporters []ErrorReporter
+	level     slog.Level
+
+	mu       sync.Mutex
+	reports  []ErrorReport
+	notifyCh chan struct{}
+	stopCh   chan struct{}
+	doneCh   chan struct{}
+}
+
+type ErrorReportDispatcherParams struct {
+	fx.In
+	Loaded    loadedConfig
+	Config    ErrorReportConfig `optional:"true"`
+	Reporters []ErrorReporter   `group:"error_reporters"`
+	Lifecycle fx.Lifecycle
+}
+
+// NewErrorReportDispatcher create a dispatcher of the registered error reporters, and the Rollbar and Bugsnag reporters
+// configured by the ErrorReportConfig, which is started and flushed with the app lifecycle.
+func NewErrorReportDispatcher(p ErrorReportDispatcherParams) (*ErrorReportDispatcher, error) {
+	config := p.Config
+	if config == nil {
+		env := ErrorReportEnv{}
+		if err := applyDefaults(&env); err != nil {
+			return nil, err
+		}
+		config = env
+	}
+	cfg := p.Loaded.config
+	environment := cfg.ProfileValue()
+	if environment == "" {
+		environment = ProfileDevelopment
+	}
+
+	d := &ErrorReportDispatcher{
+		level:    parseLogLevel(config.ErrorReportLevelValue()),
+		notifyCh: make(chan struct{}, 1),
+		stopCh:   make(chan struct{}),
+		doneCh:   make(chan struct{}),
+	}
+	for _, reporter := range p.Reporters {
+		if reporter != nil {
+			d.reporters = append(d.reporters, reporter)
+		}
+	}
+	if config.RollbarTokenValue() != "" {
+		d.reporters = append(d.reporters, NewRollbarReporter(config.RollbarTokenValue(), environment, cfg.AppVersionValue()))
+	}
+	if config.BugsnagAPIKeyValue() != "" {
+		d.reporters = append(d.reporters, NewBugsnagReporter(config.BugsnagAPIKeyValue(), environment, cfg.AppVersionValue()))
+	}
+	p.Lifecycle.Append(fx.Hook{
+		OnStart: func(_ context.Context) error {
+			go d.run()
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			close(d.stopCh)
+			select {
+			case <-d.doneCh:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			d.flush(ctx)
+			return nil
+		},
+	})
+	return d, nil
+}
+
+// Handler return a slog.Handler that report records of the configured level to the error reporters.
+func (d *ErrorReportDispatcher) Handler() slog.Handler {
+	return &levelHandler{Handler: newFlatHandler(d.handle), level: d.level}
+}
+
+func (d *ErrorReportDispatcher) run() {
+	defer close(d.doneCh)
+	for {
+		select {
+		case <-d.stopCh:
+			return
+		case <-d.notifyCh:
+		}
+		d.flush(context.Background())
+	}
+}
+
+// flush send all queued reports to every reporter, failed reports are dropped.
+func (d *ErrorReportDispatcher) flush(ctx context.Context) {
+	for {
+		d.mu.Lock()
+		reports := d.reports
+		d.reports = nil
+		d.mu.Unlock()
+		if len(reports) == 0 {
+			return
+		}
+		for _, report := range reports {
+			for _, reporter := range d.reporters {
+				if err := d.send(ctx, reporter, report); err != nil {
+					// Logging using slog would report the error again.
+					_, _ = fmt.Fprintf(os.Stderr, "error reporting to [%T]: %v\n", reporter, err)
+				}
+			}
+		}
+	}
+}
+
+func (d *ErrorReportDispatcher) send(ctx context.Context, reporter ErrorReporter, report ErrorReport) error {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	return reporter.Report(ctx, report)
+}
+
+// handle convert the record into an ErrorReport and queue it for reporting.
+func (d *ErrorReportDispatcher) handle(r slog.Record, attrs []slog.Attr) error {
+	if len(d.reporters) == 0 {
+		return nil
+	}
+	report := ErrorReport{
+		Time:    r.Time,
+		Level:   r.Level,
+		Message: r.Message,
+		Attrs:   make(map[string]any, len(attrs)),
+	}
+	for _, attr := range attrs {
+		value := attr.Value.Any()
+		if e, ok := value.(error); ok {
+			if report.Err == nil {
+				report.Err = e
+			}
+			value = e.Error()
+		}
+		report.Attrs[attr.Key] = value
+	}
+	if r.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+		report.Source = &slog.Source{Function: frame.Function, File: frame.File, Line: frame.Line}
+	}
+	d.mu.Lock()
+	if len(d.reports) < errorReportQueueSize {
+		d.reports = append(d.reports, report)
+	}
+	d.mu.Unlock()
+	select {
+	case d.notifyCh <- struct{}{}:
+	default:
+	}
+	return nil
+}
+
+// RollbarReporter report errors to Rollbar using its HTTP API.
+type RollbarReporter struct {
+	token       string
+	environment string
+	version     string
+	client      *http.Client
+}
+
+var _ ErrorReporter = (*RollbarReporter)(nil)
+
+// NewRollbarReporter create a reporter using the post_server_item access token of Rollbar.
+func NewRollbarReporter(token string, environment string, version string) *RollbarReporter {
+	return &RollbarReporter{
+		token:       token,
+		environment: environment,
+		version:     version,
+		client:      &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (r *RollbarReporter) Report(ctx context.Context, report ErrorReport) error {
+	var body map[string]any
+	if report.Err != nil {
+		frames := []map[string]any{}
+		if report.Source != nil {
+			frames = append(frames, map[string]any{
+				"filename": report.Source.File,
+				"lineno":   report.Source.Line,
+				"method":   report.Source.Function,
+			})
+		}
+		body = map[string]any{"trace": map[string]any{
+			"frames":    frames,
+			"exception": map[string]any{"class": errorClassOf(report.Err), "message": report.Err.Error()},
+		}}
+	} else {
+		body = map[string]any{"message": map[string]any{"body": report.Message}}
+	}
+	hostname, _ := os.Hostname()
+	data := map[string]any{
+		"environment": r.environment,
+		"level":       rollbarLevelOf(report.Level),
+		"timestamp":   report.Time.Unix(),
+		"platform":    runtime.GOOS,
+		"language":    "go",
+		"title":       report.Message,
+		"body":        body,
+		"custom":      report.Attrs,
+		"server":      map[string]any{"host": hostname},
+	}
+	if r.version != "" {
+		data["code_version"] = r.version
+	}
+	return postErrorReport(ctx, r.client, rollbarEndpoint, map[string]string{"X-Rollbar-Access-Token": r.token},
+		map[string]any{"data": data})
+}
+
+func rollbarLevelOf(level slog.Level) string {
+	switch {
+	case level >= slog.LevelError:
+		return "error"
+	case level >= slog.LevelWarn:
+		return "warning"
+	case level >= slog.LevelInfo:
+		return "info"
+	default:
+		return "debug"
+	}
+}
+
+// BugsnagReporter report errors to Bugsnag using its error reporting API.
+type BugsnagReporter struct {
+	apiKey       string
+	releaseStage string
+	version      string
+	client       *http.Client
+}
+
+var _ ErrorReporter = (*BugsnagReporter)(nil)
+
+// NewBugsnagReporter create a reporter using the notifier api key of a Bugsnag project.
+func NewBugsnagReporter(apiKey string, releaseStage string, version string) *BugsnagReporter {
+	return &BugsnagReporter{
+		apiKey:       apiKey,
+		releaseStage: releaseStage,
+		version:      version,
+		client:       &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (r *BugsnagReporter) Report(ctx context.Context, report ErrorReport) error {
+	errorClass, message := "log", report.Message
+	if report.Err != nil {
+		errorClass, message = errorClassOf(report.Err), report.Err.Error()
+	}
+	stacktrace := []map[string]any{}
+	if report.Source != nil {
+		stacktrace = append(stacktrace, map[string]any{
+			"file":       report.Source.File,
+			"lineNumber": report.Source.Line,
+			"method":     report.Source.Function,
+			"inProject":  true,
+		})
+	}
+	severity := "info"
+	if report.Level >= slog.LevelError {
+		severity = "error"
+	} else if report.Level >= slog.LevelWarn {
+		severity = "warning"
+	}
+	hostname, _ := os.Hostname()
+	app := map[string]any{"releaseStage": r.releaseStage}
+	if r.version != "" {
+		app["version"] = r.version
+	}
+	return postErrorReport(ctx, r.client, bugsnagEndpoint, map[string]string{
+		"Bugsnag-Api-Key":         r.apiKey,
+		"Bugsnag-Payload-Version": "5",
+		"Bugsnag-Sent-At":         time.Now().UTC().Format(time.RFC3339),
+	}, map[string]any{
+		"apiKey":   r.apiKey,
+		"notifier": map[string]any{"name": "go-corefx", "version": "1", "url": "https://github.com/mawngo/go-corefx"},
+		"events": []any{map[string]any{
+			"exceptions": []any{map[string]any{
+				"errorClass": errorClass,
+				"message":    message,
+				"stacktrace": stacktrace,
+			}},
+			"context":   report.Message,
+			"severity":  severity,
+			"unhandled": false,
+			"app":       app,
+			"device":    map[string]any{"hostname": hostname, "time": report.Time.UTC().Format(time.RFC3339)},
+			"metaData":  map[string]any{"log": report.Attrs},
+		}},
+	})
+}
+
+// errorClassOf return the type name of the innermost wrapped error of err.
+func errorClassOf(err error) string {
+	for {
+		next := errors.Unwrap(err)
+		if next == nil {
+			return fmt.Sprintf("%T", err)
+		}
+		err = next
+	}
+}
+
+// postErrorReport post payload as JSON to endpoint.
+func postErrorReport(ctx context.Context, client *http.Client, endpoint string, headers map[string]string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		return fmt.Errorf("error reporting error: status %d", res.StatusCode)
+	}
+	return nil
+}
+
+// WithErrorReporting fan the log records of the ErrorReportConfig level into the registered ErrorReporter, and the
+// Rollbar and Bugsnag reporters if configured, alongside the console/JSON handler and sentry.
+// Must be used together with NewModule.
+func WithErrorReporting() fx.Option {
+	return fx.Module("corefx.errorreport",
+		fx.Provide(NewErrorReportDispatcher),
+		fx.Provide(AsLogHandler(func(d *ErrorReportDispatcher) slog.Handler {
+			return d.Handler()
+		})),
+	)
+}
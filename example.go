@@ -0,0 +1,176 @@
+package corefx
+
+import (
+	"encoding"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"time"
+)
+
+// exampleEntry a key of an example config, either a value or a nested struct.
+type exampleEntry struct {
+	name     string
+	desc     string
+	required bool
+	value    any
+	// children entries of nested struct, nil if the entry is a value.
+	children []exampleEntry
+}
+
+// WriteExampleConfig write an example config file of the config struct cfg into w, in "json" or "yaml" format,
+// for example to bootstrap new deployments using a --write-config flag.
+// Keys follow the json tags of cfg fields in declaration order. Values are the defaults read from the `default` tag and
+// the current values of cfg, or empty values if not set, secret values are always empty.
+// In YAML format, keys are commented with their `desc:"..."` tag and whether they are required. JSON does not support
+// comments, use GenerateJSONSchema to document JSON config files.
+func WriteExampleConfig(cfg any, w io.Writer, format string) error {
+	value, err := defaultedConfigOf(cfg)
+	if err != nil {
+		return err
+	}
+	entries := exampleEntriesOf(value.Type(), value)
+
+	var b strings.Builder
+	switch strings.ToLower(format) {
+	case "json":
+		writeExampleJSON(&b, entries, "")
+		b.WriteString("\n")
+	case "yaml", "yml":
+		writeExampleYAML(&b, entries, "")
+	default:
+		return fmt.Errorf("error unsupported config format [%s]", format)
+	}
+	_, err = io.WriteString(w, b.String())
+	return err
+}
+
+// exampleEntriesOf return the example entries of fields of struct type t, including fields of embedded structs.
+// The v is the value used to read defaults and can be invalid.
+func exampleEntriesOf(t reflect.Type, v reflect.Value) []exampleEntry {
+	entries := make([]exampleEntry, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		var value reflect.Value
+		if v.IsValid() {
+			value = v.Field(i)
+		}
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			entries = append(entries, exampleEntriesOf(field.Type, value)...)
+			continue
+		}
+		name := configNameOf(field)
+		if name == "-" {
+			continue
+		}
+
+		required, _ := requiredOf(field)
+		entry := exampleEntry{name: name, desc: field.Tag.Get("desc"), required: required}
+		fieldType, fieldValue := field.Type, value
+		for fieldType.Kind() == reflect.Pointer {
+			fieldType = fieldType.Elem()
+			if fieldValue.IsValid() {
+				fieldValue = fieldValue.Elem()
+			}
+		}
+		if isNestedStruct(fieldType) {
+			entry.children = exampleEntriesOf(fieldType, fieldValue)
+		} else if entry.value = fieldDefaultOf(field, name, value); entry.value == nil {
+			entry.value = exampleZeroOf(fieldType)
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// isNestedStruct whether t is a struct of config fields, instead of a value decoded from string like time.Time.
+func isNestedStruct(t reflect.Type) bool {
+	return t.Kind() == reflect.Struct && t != timeType && !reflect.PointerTo(t).Implements(textUnmarshalerType)
+}
+
+// exampleZeroOf return the empty example value of type t, which can be decoded back into t.
+func exampleZeroOf(t reflect.Type) any {
+	if t == durationType {
+		return time.Duration(0).String()
+	}
+	if reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		if marshaler, ok := reflect.Zero(t).Interface().(encoding.TextMarshaler); ok {
+			if text, err := marshaler.MarshalText(); err == nil {
+				return string(text)
+			}
+		}
+		return nil
+	}
+	switch t.Kind() {
+	case reflect.Bool:
+		return false
+	case reflect.String:
+		return ""
+	case reflect.Slice, reflect.Array:
+		return []any{}
+	case reflect.Map:
+		return map[string]any{}
+	default:
+		if isNumberKind(t.Kind()) {
+			return 0
+		}
+		return nil
+	}
+}
+
+// exampleValueOf return the JSON representation of value, which is also a valid YAML value.
+func exampleValueOf(value any) string {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return "null"
+	}
+	return string(b)
+}
+
+func writeExampleJSON(b *strings.Builder, entries []exampleEntry, indent string) {
+	if len(entries) == 0 {
+		b.WriteString("{}")
+		return
+	}
+	b.WriteString("{\n")
+	for i, entry := range entries {
+		b.WriteString(indent + "  " + exampleValueOf(entry.name) + ": ")
+		if entry.children != nil {
+			writeExampleJSON(b, entry.children, indent+"  ")
+		} else {
+			b.WriteString(exampleValueOf(entry.value))
+		}
+		if i < len(entries)-1 {
+			b.WriteString(",")
+		}
+		b.WriteString("\n")
+	}
+	b.WriteString(indent + "}")
+}
+
+func writeExampleYAML(b *strings.Builder, entries []exampleEntry, indent string) {
+	for _, entry := range entries {
+		if entry.desc != "" {
+			for _, line := range strings.Split(entry.desc, "\n") {
+				b.WriteString(indent + "# " + line + "\n")
+			}
+		}
+		if entry.required {
+			b.WriteString(indent + "# Required.\n")
+		}
+		switch {
+		case entry.children == nil:
+			b.WriteString(indent + entry.name + ": " + exampleValueOf(entry.value) + "\n")
+		case len(entry.children) == 0:
+			b.WriteString(indent + entry.name + ": {}\n")
+		default:
+			b.WriteString(indent + entry.name + ":\n")
+			writeExampleYAML(b, entry.children, indent+"  ")
+		}
+	}
+}
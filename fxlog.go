@@ -0,0 +1,99 @@
+package corefx
+
+import (
+	"context"
+	"go.uber.org/fx"
+	"go.uber.org/fx/fxevent"
+	"log/slog"
+	"time"
+)
+
+// fxLoggerName name of the logger of fx events, see LoggerFor.
+const fxLoggerName = "fx"
+
+// FxLogConfig optional interface that a CoreConfig can implement to control the verbosity of fx events.
+type FxLogConfig interface {
+	// FxLogLevelValue min level of fx events, empty to use the log level.
+	// Provide, decorate, invoke and hook events are logged at debug level, so they are only shown in debug profile
+	// or if this level is debug. App start and stop events are logged at info level, errors at error level.
+	FxLogLevelValue() string
+}
+
+type FxLogEnv struct {
+	FxLogLevel string `json:"fx_log_level" mapstructure:"fx_log_level"`
+}
+
+func (e FxLogEnv) FxLogLevelValue() string {
+	return e.FxLogLevel
+}
+
+var _ FxLogConfig = (*FxLogEnv)(nil)
+
+// fxEventLogger fxevent.Logger that log the startup dump at debug level, and the app start and stop at info level.
+// Timings of events are recorded into the report, if not nil.
+type fxEventLogger struct {
+	logger *slog.Logger
+	debug  *fxevent.SlogLogger
+	info   *fxevent.SlogLogger
+	report *LifecycleReport
+	// summary whether to log the startup summary, see WithStartupReport.
+	summary bool
+}
+
+func newFxEventLogger(logger *slog.Logger, report *LifecycleReport, summary bool) fxevent.Logger {
+	l := &fxEventLogger{
+		logger:  logger,
+		debug:   &fxevent.SlogLogger{Logger: logger},
+		info:    &fxevent.SlogLogger{Logger: logger},
+		report:  report,
+		summary: summary,
+	}
+	l.debug.UseLogLevel(slog.LevelDebug)
+	l.info.UseLogLevel(slog.LevelInfo)
+	return l
+}
+
+func (l *fxEventLogger) LogEvent(event fxevent.Event) {
+	started := l.report != nil && l.report.record(event)
+	if started && l.summary {
+		l.logger.LogAttrs(context.Background(), slog.LevelInfo, "Startup report", l.report.summaryAttrs()...)
+	}
+	switch event.(type) {
+	case *fxevent.Started, *fxevent.Stopping, *fxevent.Stopped, *fxevent.RollingBack, *fxevent.RolledBack:
+		l.info.LogEvent(event)
+	default:
+		// Errors of these events are still logged at error level.
+		l.debug.LogEvent(event)
+	}
+	if started {
+		l.report.started()
+	}
+	if l.report != nil {
+		switch event.(type) {
+		case *fxevent.Stopped, *fxevent.RolledBack:
+			l.report.stop()
+		}
+	}
+}
+
+type fxEventLoggerParams struct {
+	fx.In
+	// Logger force the global logger to be created before the fx logger.
+	Logger  *slog.Logger
+	Levels  *LevelController
+	Report  *LifecycleReport
+	Summary startupReportFlag `optional:"true"`
+}
+
+// useFxEventLogger configure fx to log using the named fx logger, whose level can be set using FxLogConfig, and
+// provide the *LifecycleReport recording the timings of fx events.
+// The logger depends on the global logger, so fx events are buffered until the config is loaded.
+func useFxEventLogger() fx.Option {
+	report := &LifecycleReport{begin: time.Now()}
+	return fx.Options(
+		fx.Supply(report),
+		fx.WithLogger(func(p fxEventLoggerParams) fxevent.Logger {
+			return newFxEventLogger(p.Levels.Logger(fxLoggerName), p.Report, bool(p.Summary))
+		}),
+	)
+}
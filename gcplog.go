@@ -0,0 +1,105 @@
+package corefx
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	gcpTraceKey          = "logging.googleapis.com/trace"
+	gcpSpanIDKey         = "logging.googleapis.com/spanId"
+	gcpSourceLocationKey = "logging.googleapis.com/sourceLocation"
+	gcpMetadataProjectID = "http://metadata.google.internal/computeMetadata/v1/project/project-id"
+)
+
+// newGCPHandler create a JSON handler writing records in the structured format of Google Cloud Logging:
+// the level is written as severity, the source as sourceLocation, and the trace_id and span_id attributes of the
+// context as the trace fields, so records are correlated with Cloud Trace.
+// If projectID is empty, the trace is written without the project prefix.
+func newGCPHandler(w io.Writer, level slog.Leveler, projectID string) slog.Handler {
+	return slog.NewJSONHandler(w, &slog.HandlerOptions{
+		Level:     level,
+		AddSource: true,
+		ReplaceAttr: func(groups []string, attr slog.Attr) slog.Attr {
+			if len(groups) > 0 {
+				return attr
+			}
+			switch attr.Key {
+			case slog.LevelKey:
+				level, _ := attr.Value.Any().(slog.Level)
+				return slog.String("severity", gcpSeverityOf(level))
+			case slog.MessageKey:
+				attr.Key = "message"
+			case slog.SourceKey:
+				source, ok := attr.Value.Any().(*slog.Source)
+				if !ok || source == nil {
+					return attr
+				}
+				return slog.Group(gcpSourceLocationKey,
+					slog.String("file", source.File),
+					slog.Int("line", source.Line),
+					slog.String("function", source.Function),
+				)
+			case "trace_id":
+				attr.Key = gcpTraceKey
+				if projectID != "" {
+					attr.Value = slog.StringValue("projects/" + projectID + "/traces/" + attr.Value.String())
+				}
+			case "span_id":
+				attr.Key = gcpSpanIDKey
+			}
+			return attr
+		},
+	})
+}
+
+// gcpSeverityOf return the Cloud Logging severity of level.
+func gcpSeverityOf(level slog.Level) string {
+	switch {
+	case level < slog.LevelInfo:
+		return "DEBUG"
+	case level < slog.LevelWarn:
+		return "INFO"
+	case level < slog.LevelError:
+		return "WARNING"
+	case level < slog.LevelError+4:
+		return "ERROR"
+	default:
+		return "CRITICAL"
+	}
+}
+
+// gcpProjectID return the Google Cloud project id of the GOOGLE_CLOUD_PROJECT env, or of the metadata server when
+// running on Google Cloud. Return empty string if the project is unknown.
+func gcpProjectID() string {
+	for _, env := range []string{"GOOGLE_CLOUD_PROJECT", "GCP_PROJECT", "GCLOUD_PROJECT"} {
+		if projectID := os.Getenv(env); projectID != "" {
+			return projectID
+		}
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, gcpMetadataProjectID, nil)
+	if err != nil {
+		return ""
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return ""
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return ""
+	}
+	b, err := io.ReadAll(io.LimitReader(res.Body, 256))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(b))
+}
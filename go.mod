@@ -5,17 +5,20 @@ go 1.22.0
 toolchain go1.22.2
 
 require (
+	github.com/fsnotify/fsnotify v1.7.0
 	github.com/getsentry/sentry-go v0.29.0
 	github.com/mitchellh/mapstructure v1.5.0
 	github.com/phsym/console-slog v0.3.1
 	github.com/samber/slog-multi v1.2.2
 	github.com/samber/slog-sentry/v2 v2.8.0
+	github.com/spf13/pflag v1.0.5
 	github.com/spf13/viper v1.19.0
+	github.com/subosito/gotenv v1.6.0
 	go.uber.org/fx v1.22.2
+	gopkg.in/yaml.v3 v3.0.1
 )
 
 require (
-	github.com/fsnotify/fsnotify v1.7.0 // indirect
 	github.com/hashicorp/hcl v1.0.0 // indirect
 	github.com/magiconair/properties v1.8.7 // indirect
 	github.com/pelletier/go-toml/v2 v2.2.3 // indirect
@@ -26,8 +29,6 @@ require (
 	github.com/sourcegraph/conc v0.3.0 // indirect
 	github.com/spf13/afero v1.11.0 // indirect
 	github.com/spf13/cast v1.7.0 // indirect
-	github.com/spf13/pflag v1.0.5 // indirect
-	github.com/subosito/gotenv v1.6.0 // indirect
 	go.uber.org/dig v1.18.0 // indirect
 	go.uber.org/multierr v1.11.0 // indirect
 	go.uber.org/zap v1.27.0 // indirect
@@ -35,5 +36,4 @@ require (
 	golang.org/x/sys v0.25.0 // indirect
 	golang.org/x/text v0.18.0 // indirect
 	gopkg.in/ini.v1 v1.67.0 // indirect
-	gopkg.in/yaml.v3 v3.0.1 // indirect
 )
@@ -0,0 +1,328 @@
+package corefx
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"github.com/getsentry/sentry-go"
+	"go.uber.org/fx"
+	"io"
+	"log/slog"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"regexp"
+	"time"
+)
+
+const (
+	// httpClientLoggerName name of the logger of the http client, requests are logged at debug level.
+	httpClientLoggerName = "http_client"
+	// httpClientMaxBackoff max delay between retries of the http client.
+	httpClientMaxBackoff = 5 * time.Second
+)
+
+var (
+	traceIDPattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+	spanIDPattern  = regexp.MustCompile(`^[0-9a-f]{16}$`)
+)
+
+type HTTPClientConfig interface {
+	// HTTPClientTimeoutValue timeout of each request, including retries and reading the response body.
+	// Zero means no timeout.
+	HTTPClientTimeoutValue() time.Duration
+	// HTTPClientProxyValue url of the proxy, empty to use the HTTP_PROXY, HTTPS_PROXY and NO_PROXY env.
+	HTTPClientProxyValue() string
+	// HTTPClientMaxIdleConnsValue max number of idle connections across all hosts, zero means no limit.
+	HTTPClientMaxIdleConnsValue() int
+	// HTTPClientMaxIdleConnsPerHostValue max number of idle connections per host, zero use the net/http default.
+	HTTPClientMaxIdleConnsPerHostValue() int
+	// HTTPClientTLSCAFileValue PEM file, or inline PEM, of certificate authorities trusted in addition to the system
+	// ones.
+	HTTPClientTLSCAFileValue() string
+	// HTTPClientTLSCertFileValue client certificate file, or inline PEM, sent to servers requesting one (mTLS).
+	// The certificate is reloaded when the file is rotated, see NewTLS.
+	HTTPClientTLSCertFileValue() string
+	// HTTPClientTLSKeyFileValue private key file, or inline PEM, of the client certificate.
+	HTTPClientTLSKeyFileValue() string
+	// HTTPClientTLSInsecureValue skip verification of server certificates, must only be used for testing.
+	HTTPClientTLSInsecureValue() bool
+	// HTTPClientRetriesValue number of retries of idempotent requests on network errors, 429, 502, 503 and 504
+	// responses, with exponential backoff. Zero disables retrying.
+	HTTPClientRetriesValue() int
+}
+
+type HTTPClientEnv struct {
+	HTTPClientTimeout             time.Duration `json:"http_client_timeout" mapstructure:"http_client_timeout" default:"30s"`
+	HTTPClientProxy               string        `json:"http_client_proxy" mapstructure:"http_client_proxy"`
+	HTTPClientMaxIdleConns        int           `json:"http_client_max_idle_conns" mapstructure:"http_client_max_idle_conns" default:"100"`
+	HTTPClientMaxIdleConnsPerHost int           `json:"http_client_max_idle_conns_per_host" mapstructure:"http_client_max_idle_conns_per_host" default:"10"`
+	HTTPClientTLSCAFile           string        `json:"http_client_tls_ca_file" mapstructure:"http_client_tls_ca_file"`
+	HTTPClientTLSCertFile         string        `json:"http_client_tls_cert_file" mapstructure:"http_client_tls_cert_file"`
+	HTTPClientTLSKeyFile          string        `json:"http_client_tls_key_file" mapstructure:"http_client_tls_key_file" secret:"true"`
+	HTTPClientTLSInsecure         bool          `json:"http_client_tls_insecure" mapstructure:"http_client_tls_insecure"`
+	HTTPClientRetries             int           `json:"http_client_retries" mapstructure:"http_client_retries"`
+}
+
+func (e HTTPClientEnv) HTTPClientTimeoutValue() time.Duration {
+	return e.HTTPClientTimeout
+}
+
+func (e HTTPClientEnv) HTTPClientProxyValue() string {
+	return e.HTTPClientProxy
+}
+
+func (e HTTPClientEnv) HTTPClientMaxIdleConnsValue() int {
+	return e.HTTPClientMaxIdleConns
+}
+
+func (e HTTPClientEnv) HTTPClientMaxIdleConnsPerHostValue() int {
+	return e.HTTPClientMaxIdleConnsPerHost
+}
+
+func (e HTTPClientEnv) HTTPClientTLSCAFileValue() string {
+	return e.HTTPClientTLSCAFile
+}
+
+func (e HTTPClientEnv) HTTPClientTLSCertFileValue() string {
+	return e.HTTPClientTLSCertFile
+}
+
+func (e HTTPClientEnv) HTTPClientTLSKeyFileValue() string {
+	return e.HTTPClientTLSKeyFile
+}
+
+func (e HTTPClientEnv) HTTPClientTLSInsecureValue() bool {
+	return e.HTTPClientTLSInsecure
+}
+
+func (e HTTPClientEnv) HTTPClientRetriesValue() int {
+	return e.HTTPClientRetries
+}
+
+var _ HTTPClientConfig = (*HTTPClientEnv)(nil)
+
+type HTTPClientParams struct {
+	fx.In
+	Loaded loadedConfig
+	Config HTTPClientConfig `optional:"true"`
+	Levels *LevelController
+	Clock  Clock `optional:"true"`
+}
+
+// NewHTTPClient create an http client configured by the HTTPClientConfig, or the HTTPClientEnv defaults if not
+// registered. Requests are retried according to the config, and logged at debug level by the "http_client" logger,
+// see LogLevelsConfig. If the config implements BreakerConfig, each host is protected by a circuit breaker.
+// The request id, the trace of ContextWithTrace (as W3C traceparent) and the sentry span of the request context are
+// propagated using request headers.
+func NewHTTPClient(p HTTPClientParams) (*http.Client, error) {
+	config := p.Config
+	if config == nil {
+		env := HTTPClientEnv{}
+		if err := applyDefaults(&env); err != nil {
+			return nil, err
+		}
+		config = env
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if config.HTTPClientProxyValue() != "" {
+		proxy, err := url.Parse(config.HTTPClientProxyValue())
+		if err != nil {
+			return nil, fmt.Errorf("error invalid http client proxy: %w", err)
+		}
+		transport.Proxy = http.ProxyURL(proxy)
+	}
+	transport.MaxIdleConns = config.HTTPClientMaxIdleConnsValue()
+	if config.HTTPClientMaxIdleConnsPerHostValue() > 0 {
+		transport.MaxIdleConnsPerHost = config.HTTPClientMaxIdleConnsPerHostValue()
+	}
+	logger := p.Levels.Logger(httpClientLoggerName)
+	tlsConfig, err := httpClientTLSConfigOf(logger, config)
+	if err != nil {
+		return nil, err
+	}
+	transport.TLSClientConfig = tlsConfig
+
+	var next http.RoundTripper = &tracingTransport{next: transport}
+	next = &loggingTransport{next: next, logger: logger}
+	if config.HTTPClientRetriesValue() > 0 {
+		next = &retryTransport{next: next, retries: config.HTTPClientRetriesValue()}
+	}
+	if c, ok := config.(BreakerConfig); ok {
+		options := c.BreakerValue()
+		if options.Logger == nil {
+			options.Logger = logger
+		}
+		if options.Clock == nil {
+			options.Clock = p.Clock
+		}
+		breakers := &hostBreakers{options: options, breakers: map[string]*Breaker{}}
+		next = &breakerTransport{next: next, breaker: breakers.breakerOf}
+	}
+	return &http.Client{
+		Transport: next,
+		Timeout:   config.HTTPClientTimeoutValue(),
+	}, nil
+}
+
+// httpClientTLSConfigOf return the tls config of the http client config.
+func httpClientTLSConfigOf(logger *slog.Logger, config HTTPClientConfig) (*tls.Config, error) {
+	t, err := NewTLS(logger, TLSEnv{
+		TLSCert: config.HTTPClientTLSCertFileValue(),
+		TLSKey:  config.HTTPClientTLSKeyFileValue(),
+		TLSCA:   config.HTTPClientTLSCAFileValue(),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error loading http client tls: %w", err)
+	}
+	tlsConfig := t.ClientConfig()
+	// nolint:gosec
+	tlsConfig.InsecureSkipVerify = config.HTTPClientTLSInsecureValue()
+	return tlsConfig, nil
+}
+
+// tracingTransport propagate the request id and trace of the request context using request headers.
+type tracingTransport struct {
+	next http.RoundTripper
+}
+
+func (t *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	headers := map[string]string{}
+	if requestID := RequestIDFromContext(ctx); requestID != "" {
+		headers[RequestIDHeader] = requestID
+	}
+	traceID, _ := ctx.Value(traceIDContextKey).(string)
+	spanID, _ := ctx.Value(spanIDContextKey).(string)
+	if traceIDPattern.MatchString(traceID) && spanIDPattern.MatchString(spanID) {
+		headers["traceparent"] = "00-" + traceID + "-" + spanID + "-01"
+	}
+	if span := sentry.SpanFromContext(ctx); span != nil {
+		headers[sentry.SentryTraceHeader] = span.ToSentryTrace()
+		if baggage := span.ToBaggage(); baggage != "" {
+			headers[sentry.SentryBaggageHeader] = baggage
+		}
+	}
+
+	if len(headers) > 0 {
+		// RoundTrip must not modify the request.
+		req = req.Clone(ctx)
+		for key, value := range headers {
+			if req.Header.Get(key) == "" {
+				req.Header.Set(key, value)
+			}
+		}
+	}
+	return t.next.RoundTrip(req)
+}
+
+// loggingTransport log requests at debug level.
+type loggingTransport struct {
+	next   http.RoundTripper
+	logger *slog.Logger
+}
+
+func (t *loggingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ctx := req.Context()
+	if !t.logger.Enabled(ctx, slog.LevelDebug) {
+		return t.next.RoundTrip(req)
+	}
+	start := time.Now()
+	res, err := t.next.RoundTrip(req)
+	attrs := []slog.Attr{
+		slog.String("method", req.Method),
+		slog.String("url", loggedURLOf(req.URL)),
+		slog.Duration("duration", time.Since(start)),
+	}
+	if err != nil {
+		t.logger.LogAttrs(ctx, slog.LevelDebug, "HTTP request failed", append(attrs, slog.Any("err", err))...)
+		return res, err
+	}
+	t.logger.LogAttrs(ctx, slog.LevelDebug, "HTTP request", append(attrs, slog.Int("status", res.StatusCode))...)
+	return res, nil
+}
+
+// loggedURLOf return u without password and query, which may contain secrets.
+func loggedURLOf(u *url.URL) string {
+	logged := *u
+	logged.RawQuery = ""
+	return logged.Redacted()
+}
+
+// retryTransport retry idempotent requests on network errors and retryable responses with exponential backoff.
+type retryTransport struct {
+	next    http.RoundTripper
+	retries int
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !isRetryableRequest(req) {
+		return t.next.RoundTrip(req)
+	}
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			req = req.Clone(req.Context())
+			req.Body = body
+		}
+		res, err := t.next.RoundTrip(req)
+		if attempt >= t.retries || !isRetryableResult(req.Context(), res, err) {
+			return res, err
+		}
+		if res != nil {
+			// Drain the body so the connection can be reused.
+			_, _ = io.Copy(io.Discard, res.Body)
+			_ = res.Body.Close()
+		}
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(backoffOf(attempt)):
+		}
+	}
+}
+
+// isRetryableRequest whether the request is idempotent and its body can be sent again.
+func isRetryableRequest(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete, http.MethodTrace:
+		return req.Body == nil || req.Body == http.NoBody || req.GetBody != nil
+	default:
+		return false
+	}
+}
+
+// isRetryableResult whether the request should be retried, on network errors and 429, 502, 503 and 504 responses.
+func isRetryableResult(ctx context.Context, res *http.Response, err error) bool {
+	if err != nil {
+		return ctx.Err() == nil && !errors.Is(err, context.Canceled)
+	}
+	switch res.StatusCode {
+	case http.StatusTooManyRequests, http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	default:
+		return false
+	}
+}
+
+// backoffOf return the delay before the retry following attempt, doubling from 100ms with jitter.
+func backoffOf(attempt int) time.Duration {
+	backoff := min(100*time.Millisecond<<min(attempt, 6), httpClientMaxBackoff)
+	// nolint:gosec
+	return backoff/2 + rand.N(backoff/2+1)
+}
+
+// NewHTTPClientModule provide an *http.Client with sane defaults, retries, request logging and trace propagation.
+// Must be used together with NewModule.
+// The env config can register as HTTPClientConfig to configure the client, otherwise the HTTPClientEnv defaults are
+// used.
+func NewHTTPClientModule() fx.Option {
+	return fx.Module("corefx.httpclient",
+		fx.Provide(NewHTTPClient),
+	)
+}
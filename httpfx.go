@@ -0,0 +1,267 @@
+package corefx
+
+import (
+	"context"
+	"errors"
+	"go.uber.org/fx"
+	"log/slog"
+	"net"
+	"net/http"
+	"time"
+)
+
+type HTTPServerConfig interface {
+	// HTTPAddrValue address to listen on, for example ":8080".
+	HTTPAddrValue() string
+	// HTTPReadTimeoutValue max duration for reading the entire request, zero means no timeout.
+	HTTPReadTimeoutValue() time.Duration
+	// HTTPWriteTimeoutValue max duration before timing out writes of the response, zero means no timeout.
+	HTTPWriteTimeoutValue() time.Duration
+	// HTTPIdleTimeoutValue max amount of time to wait for the next request when keep-alives are enabled.
+	HTTPIdleTimeoutValue() time.Duration
+	// HTTPTLSCertFileValue certificate file, or inline PEM, to serve TLS, empty to serve plain HTTP.
+	// The certificate is reloaded when the file is rotated, see NewTLS.
+	HTTPTLSCertFileValue() string
+	// HTTPTLSKeyFileValue private key file, or inline PEM, of the TLS certificate.
+	HTTPTLSKeyFileValue() string
+}
+
+type HTTPServerEnv struct {
+	HTTPAddr         string        `json:"http_addr" mapstructure:"http_addr" default:":8080"`
+	HTTPReadTimeout  time.Duration `json:"http_read_timeout" mapstructure:"http_read_timeout" default:"30s"`
+	HTTPWriteTimeout time.Duration `json:"http_write_timeout" mapstructure:"http_write_timeout" default:"30s"`
+	HTTPIdleTimeout  time.Duration `json:"http_idle_timeout" mapstructure:"http_idle_timeout" default:"2m"`
+	HTTPTLSCertFile  string        `json:"http_tls_cert_file" mapstructure:"http_tls_cert_file"`
+	HTTPTLSKeyFile   string        `json:"http_tls_key_file" mapstructure:"http_tls_key_file" secret:"true"`
+}
+
+func (e HTTPServerEnv) HTTPAddrValue() string {
+	return e.HTTPAddr
+}
+
+func (e HTTPServerEnv) HTTPReadTimeoutValue() time.Duration {
+	return e.HTTPReadTimeout
+}
+
+func (e HTTPServerEnv) HTTPWriteTimeoutValue() time.Duration {
+	return e.HTTPWriteTimeout
+}
+
+func (e HTTPServerEnv) HTTPIdleTimeoutValue() time.Duration {
+	return e.HTTPIdleTimeout
+}
+
+func (e HTTPServerEnv) HTTPTLSCertFileValue() string {
+	return e.HTTPTLSCertFile
+}
+
+func (e HTTPServerEnv) HTTPTLSKeyFileValue() string {
+	return e.HTTPTLSKeyFile
+}
+
+var _ HTTPServerConfig = (*HTTPServerEnv)(nil)
+
+// Route an HTTP handler mounted on the built-in server.
+type Route struct {
+	// Pattern the http.ServeMux pattern, for example "GET /users/{id}".
+	Pattern string
+	Handler http.Handler
+}
+
+func (r Route) Routes() []Route {
+	return []Route{r}
+}
+
+var _ RouteProvider = (*Route)(nil)
+
+// RouteProvider provide HTTP routes of a feature module.
+type RouteProvider interface {
+	Routes() []Route
+}
+
+// RouteMux mux that routes can be mounted on, implemented by http.ServeMux and most third party routers.
+type RouteMux interface {
+	Handle(pattern string, handler http.Handler)
+}
+
+// AsRouteProvider annotate a RouteProvider constructor to register it into the http route group.
+// For example, fx.Provide(corefx.AsRouteProvider(newUserHandler)).
+func AsRouteProvider(f any) any {
+	return fx.Annotate(
+		f,
+		fx.As(new(RouteProvider)),
+		fx.ResultTags(`group:"http_routes"`),
+	)
+}
+
+// AsRoute annotate a Route constructor to register it into the http route group.
+// For example, fx.Provide(corefx.AsRoute(newUserRoute)).
+func AsRoute(f any) any {
+	return AsRouteProvider(f)
+}
+
+// RoutesParams inject all registered route providers, to mount them on a user provided mux using MountRoutes.
+type RoutesParams struct {
+	fx.In
+	Providers []RouteProvider `group:"http_routes"`
+}
+
+// MountRoutes mount routes of all providers on mux.
+// For example, fx.Invoke(func(p corefx.RoutesParams) error { return corefx.MountRoutes(mux, p.Providers...) }).
+func MountRoutes(mux RouteMux, providers ...RouteProvider) error {
+	for _, provider := range providers {
+		for _, route := range provider.Routes() {
+			if route.Pattern == "" || route.Handler == nil {
+				return errors.New("error http route must have pattern and handler")
+			}
+			mux.Handle(route.Pattern, route.Handler)
+		}
+	}
+	return nil
+}
+
+type HTTPServerParams struct {
+	fx.In
+	Loaded    loadedConfig
+	Config    HTTPServerConfig `optional:"true"`
+	Routes    []RouteProvider  `group:"http_routes"`
+	Panic     *PanicHandler    `optional:"true"`
+	Levels    *LevelController
+	Watcher   *ConfigWatcher       `optional:"true"`
+	Shutdown  *ShutdownCoordinator `optional:"true"`
+	Logger    *slog.Logger
+	Lifecycle fx.Lifecycle
+}
+
+// NewHTTPServer create an HTTP server serving the registered routes.
+// Panics of handlers are recovered using the PanicHandler.
+// Requests carry a request id and the trace of the request headers, see RequestIDMiddleware and TraceMiddleware,
+// and are logged by the "http_server" logger if the config implements HTTPLogConfig.
+// Requests are rate limited if the config implements RateLimitConfig, the limit is updated when the config is reloaded.
+// The server is started when the app starts and shut down gracefully when the app stops.
+// When run using Run, keep-alive connections are closed once the app start draining, so clients reconnect to other
+// instances, see ShutdownCoordinator.
+func NewHTTPServer(p HTTPServerParams) (*http.Server, error) {
+	config := p.Config
+	if config == nil {
+		env := HTTPServerEnv{}
+		if err := applyDefaults(&env); err != nil {
+			return nil, err
+		}
+		config = env
+	}
+	mux := http.NewServeMux()
+	if err := MountRoutes(mux, p.Routes...); err != nil {
+		return nil, err
+	}
+	var handler http.Handler = mux
+	if p.Panic != nil {
+		handler = p.Panic.Middleware(handler)
+	}
+	if c, ok := config.(RateLimitConfig); ok {
+		limiter, err := NewRateLimiter(c.RateLimitValue())
+		if err != nil {
+			return nil, err
+		}
+		if p.Watcher != nil {
+			p.Watcher.OnConfigChange(func(_ CoreConfig, cfg CoreConfig) {
+				reloaded, ok := cfg.(RateLimitConfig)
+				if !ok {
+					return
+				}
+				if err := limiter.Update(reloaded.RateLimitValue()); err != nil {
+					p.Logger.Error("Error updating rate limit", slog.Any("err", err))
+				}
+			})
+		}
+		handler = limiter.Middleware(handler)
+	}
+	if c, ok := config.(HTTPLogConfig); ok {
+		handler = HTTPLoggingMiddleware(p.Levels.Logger(httpServerLoggerName), c.HTTPLogValue())(handler)
+	}
+	handler = RequestIDMiddleware(TraceMiddleware(handler))
+	server := &http.Server{
+		Addr:         config.HTTPAddrValue(),
+		Handler:      handler,
+		ReadTimeout:  config.HTTPReadTimeoutValue(),
+		WriteTimeout: config.HTTPWriteTimeoutValue(),
+		IdleTimeout:  config.HTTPIdleTimeoutValue(),
+	}
+	if config.HTTPTLSCertFileValue() != "" {
+		t, err := NewTLS(p.Logger, TLSEnv{TLSCert: config.HTTPTLSCertFileValue(), TLSKey: config.HTTPTLSKeyFileValue()})
+		if err != nil {
+			return nil, err
+		}
+		server.TLSConfig = t.ServerConfig()
+	}
+	serveHTTP(p.Lifecycle, p.Logger, server)
+	if p.Shutdown != nil {
+		closeKeepAlivesOnDrain(p.Lifecycle, p.Shutdown, server)
+	}
+	return server, nil
+}
+
+// closeKeepAlivesOnDrain disable keep-alives of server when the app start draining, which close idle connections and
+// make the next response of active connections close them.
+func closeKeepAlivesOnDrain(lc fx.Lifecycle, coordinator *ShutdownCoordinator, server *http.Server) {
+	stopped := make(chan struct{})
+	lc.Append(fx.Hook{
+		OnStart: func(_ context.Context) error {
+			go func() {
+				select {
+				case <-coordinator.Draining():
+					server.SetKeepAlivesEnabled(false)
+				case <-stopped:
+				}
+			}()
+			return nil
+		},
+		OnStop: func(_ context.Context) error {
+			close(stopped)
+			return nil
+		},
+	})
+}
+
+// serveHTTP start server when the app starts and shut it down gracefully when the app stops.
+// The server serve TLS if its TLSConfig is set.
+func serveHTTP(lc fx.Lifecycle, logger *slog.Logger, server *http.Server) {
+	lc.Append(fx.Hook{
+		OnStart: func(_ context.Context) error {
+			// Listen synchronously, so address errors fail the app start.
+			listener, err := net.Listen("tcp", server.Addr)
+			if err != nil {
+				return err
+			}
+			go func() {
+				var err error
+				if server.TLSConfig != nil {
+					err = server.ServeTLS(listener, "", "")
+				} else {
+					err = server.Serve(listener)
+				}
+				if err != nil && !errors.Is(err, http.ErrServerClosed) {
+					logger.Error("Error serving http", slog.String("addr", server.Addr), slog.Any("err", err))
+				}
+			}()
+			logger.Info("Started http server", slog.String("addr", listener.Addr().String()))
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			return server.Shutdown(ctx)
+		},
+	})
+}
+
+// NewHTTPServerModule serve routes registered using AsRoute or AsRouteProvider on an HTTP server managed by the app lifecycle.
+// Must be used together with NewModule.
+// The env config can register as HTTPServerConfig to configure the server,
+// otherwise the HTTPServerEnv defaults are used.
+func NewHTTPServerModule() fx.Option {
+	return fx.Module("corefx.http",
+		fx.Provide(NewHTTPServer),
+		fx.Invoke(func(_ *http.Server) {
+			// force initialization of the server.
+		}),
+	)
+}
@@ -0,0 +1,139 @@
+package corefx
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// httpServerLoggerName name of the logger of the http server access logs.
+const httpServerLoggerName = "http_server"
+
+// HTTPLogConfig optional interface that a HTTPServerConfig can implement to log requests of the built-in server,
+// see HTTPLoggingMiddleware.
+type HTTPLogConfig interface {
+	HTTPLogValue() HTTPLogOptions
+}
+
+// HTTPLogOptions the http_log config section.
+type HTTPLogOptions struct {
+	// ExcludePaths paths of requests that are not logged, such as health endpoints.
+	// A path ending with "*" exclude all paths starting with it.
+	ExcludePaths []string `json:"exclude_paths" mapstructure:"exclude_paths" default:"/healthz,/readyz,/livez"`
+	// SlowThreshold requests taking longer are logged at warn level, zero disable slow request warnings.
+	SlowThreshold time.Duration `json:"slow_threshold" mapstructure:"slow_threshold" default:"1s"`
+}
+
+type HTTPLogEnv struct {
+	HTTPLog HTTPLogOptions `json:"http_log" mapstructure:"http_log"`
+}
+
+func (e HTTPLogEnv) HTTPLogValue() HTTPLogOptions {
+	return e.HTTPLog
+}
+
+var _ HTTPLogConfig = (*HTTPLogEnv)(nil)
+
+// HTTPLoggingMiddleware create a middleware that log every request after it is served, with its method, path, status,
+// latency, response bytes and remote address. Records are logged with the request context, so the corefx logger add
+// the request id and trace of RequestIDMiddleware and TraceMiddleware if they wrap the middleware.
+// Requests responding with 5xx status or slower than the threshold are logged at warn level, others at info level.
+func HTTPLoggingMiddleware(logger *slog.Logger, options HTTPLogOptions) func(http.Handler) http.Handler {
+	excluded := pathMatcher(options.ExcludePaths)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if excluded(r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			start := time.Now()
+			recorder := &responseRecorder{ResponseWriter: w}
+			defer func() {
+				recovered := recover()
+				latency := time.Since(start)
+				status := recorder.status
+				if status == 0 && recovered != nil {
+					status = http.StatusInternalServerError
+				} else if status == 0 {
+					status = http.StatusOK
+				}
+				level := slog.LevelInfo
+				msg := "HTTP request"
+				if options.SlowThreshold > 0 && latency > options.SlowThreshold {
+					level = slog.LevelWarn
+					msg = "Slow HTTP request"
+				} else if status >= http.StatusInternalServerError {
+					level = slog.LevelWarn
+				}
+				logger.LogAttrs(r.Context(), level, msg,
+					slog.String("method", r.Method),
+					slog.String("path", r.URL.Path),
+					slog.Int("status", status),
+					slog.Duration("latency", latency),
+					slog.Int64("bytes", recorder.bytes),
+					slog.String("remote_addr", r.RemoteAddr),
+				)
+				if recovered != nil {
+					panic(recovered)
+				}
+			}()
+			next.ServeHTTP(recorder, r)
+		})
+	}
+}
+
+// pathMatcher return a function reporting whether a path match any of patterns,
+// a pattern ending with "*" match all paths starting with it.
+func pathMatcher(patterns []string) func(path string) bool {
+	var exact []string
+	var prefixes []string
+	for _, pattern := range patterns {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			prefixes = append(prefixes, prefix)
+		} else if pattern != "" {
+			exact = append(exact, pattern)
+		}
+	}
+	return func(path string) bool {
+		for _, p := range exact {
+			if path == p {
+				return true
+			}
+		}
+		for _, p := range prefixes {
+			if strings.HasPrefix(path, p) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// responseRecorder record the status and the number of bytes of a response.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += int64(n)
+	return n, err
+}
+
+// Unwrap return the underlying ResponseWriter, for http.ResponseController.
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
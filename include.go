@@ -0,0 +1,166 @@
+package corefx
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/spf13/viper"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// configIncludeKey key of the include directive in config files.
+const configIncludeKey = "$include"
+
+// readConfigFile read the settings of a JSON or YAML config file, merged on top of the files it include using the
+// "$include" directive, for example {"$include": ["database.json", "features/*.json"]}.
+// Included paths are relative to the including file, glob patterns are expanded in lexical order.
+// Included files are merged in order, later files override earlier ones, and the including file override them all.
+func readConfigFile(path string) (map[string]any, error) {
+	return readConfigFileIn(path, nil)
+}
+
+// readConfigFileIn read the config file included by the files of stack, the last one being the direct parent.
+func readConfigFileIn(path string, stack []string) (map[string]any, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	if slices.Contains(stack, abs) {
+		return nil, fmt.Errorf("error config include cycle [%s]", strings.Join(append(stack, abs), " -> "))
+	}
+	settings, err := readConfigFileSettings(path)
+	if err != nil {
+		if len(stack) > 0 {
+			// Wrap without %w so missing included files are not ignored like missing config files.
+			return nil, fmt.Errorf("error reading config file [%s] included by [%s]: %v", path, stack[len(stack)-1], err)
+		}
+		return nil, err
+	}
+	includes, ok := settings[configIncludeKey]
+	if !ok {
+		return settings, nil
+	}
+	delete(settings, configIncludeKey)
+
+	paths, err := includedPathsOf(abs, includes)
+	if err != nil {
+		return nil, err
+	}
+	merged := viper.New()
+	for _, included := range paths {
+		includedSettings, err := readConfigFileIn(included, append(stack[:len(stack):len(stack)], abs))
+		if err != nil {
+			return nil, err
+		}
+		if err := merged.MergeConfigMap(includedSettings); err != nil {
+			return nil, err
+		}
+	}
+	if err := merged.MergeConfigMap(settings); err != nil {
+		return nil, err
+	}
+	return merged.AllSettings(), nil
+}
+
+// readConfigFileSettings read the settings of a single config file, decrypting SOPS encrypted files.
+func readConfigFileSettings(path string) (map[string]any, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	if isAgeEncrypted(data) {
+		return nil, fmt.Errorf("error config file [%s] is encrypted using age, which is not supported, "+
+			"encrypt it using sops instead", path)
+	}
+	file := viper.New()
+	file.SetConfigType(configTypeOf(path))
+	if err := file.ReadConfig(bytes.NewReader(data)); err != nil {
+		return nil, err
+	}
+	settings := file.AllSettings()
+	if _, ok := settings[sopsMetadataKey]; !ok {
+		return settings, nil
+	}
+	// Keys of viper are lowercased, but encrypted values are authenticated using the original keys.
+	decrypted, err := decryptSOPSConfig(path, configTypeOf(path), data)
+	if err != nil {
+		return nil, err
+	}
+	file = viper.New()
+	if err := file.MergeConfigMap(decrypted); err != nil {
+		return nil, err
+	}
+	return file.AllSettings(), nil
+}
+
+// includedPathsOf return the paths included by the config file path, in merge order.
+func includedPathsOf(path string, includes any) ([]string, error) {
+	var patterns []string
+	switch includes := includes.(type) {
+	case string:
+		patterns = []string{includes}
+	case []any:
+		for _, include := range includes {
+			pattern, ok := include.(string)
+			if !ok {
+				return nil, fmt.Errorf("error %s of config file [%s] must be a list of paths", configIncludeKey, path)
+			}
+			patterns = append(patterns, pattern)
+		}
+	default:
+		return nil, fmt.Errorf("error %s of config file [%s] must be a list of paths", configIncludeKey, path)
+	}
+
+	var paths []string
+	for _, pattern := range patterns {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(filepath.Dir(path), pattern)
+		}
+		if !strings.ContainsAny(pattern, "*?[") {
+			paths = append(paths, pattern)
+			continue
+		}
+		// Glob matches are sorted, patterns without match are ignored.
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("error invalid %s pattern [%s] of config file [%s]: %w", configIncludeKey, pattern, path, err)
+		}
+		paths = append(paths, matches...)
+	}
+	return paths, nil
+}
+
+// includedFilesOf return the files included by the config file path, recursively.
+// Errors are ignored, as they are reported when loading the config.
+func includedFilesOf(path string) []string {
+	var files []string
+	var walk func(path string, stack []string)
+	walk = func(path string, stack []string) {
+		abs, err := filepath.Abs(path)
+		if err != nil || slices.Contains(stack, abs) {
+			return
+		}
+		file := viper.New()
+		file.SetConfigType(configTypeOf(path))
+		file.SetConfigFile(path)
+		if err := file.ReadInConfig(); err != nil {
+			return
+		}
+		includes, ok := file.AllSettings()[configIncludeKey]
+		if !ok {
+			return
+		}
+		paths, err := includedPathsOf(abs, includes)
+		if err != nil {
+			return
+		}
+		for _, included := range paths {
+			files = append(files, included)
+			walk(included, append(stack[:len(stack):len(stack)], abs))
+		}
+	}
+	walk(path, nil)
+	return files
+}
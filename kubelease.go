@@ -0,0 +1,212 @@
+package corefx
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// kubernetesServiceAccountDir directory of the service account credentials mounted into pods.
+	kubernetesServiceAccountDir = "/var/run/secrets/kubernetes.io/serviceaccount"
+	// kubernetesMicroTimeFormat format of the MicroTime fields of Kubernetes objects.
+	kubernetesMicroTimeFormat = "2006-01-02T15:04:05.000000Z07:00"
+)
+
+// kubernetesLease a coordination.k8s.io/v1 Lease, metadata is kept as is so updates do not drop labels and
+// annotations.
+type kubernetesLease struct {
+	APIVersion string              `json:"apiVersion"`
+	Kind       string              `json:"kind"`
+	Metadata   map[string]any      `json:"metadata"`
+	Spec       kubernetesLeaseSpec `json:"spec"`
+}
+
+type kubernetesLeaseSpec struct {
+	HolderIdentity       string `json:"holderIdentity,omitempty"`
+	LeaseDurationSeconds int    `json:"leaseDurationSeconds,omitempty"`
+	AcquireTime          string `json:"acquireTime,omitempty"`
+	RenewTime            string `json:"renewTime,omitempty"`
+	LeaseTransitions     int    `json:"leaseTransitions,omitempty"`
+}
+
+// KubernetesLeaseLock a LeaderLock using a Kubernetes Lease, accessed using the service account of the pod, which must
+// be allowed to get, create and update leases of the namespace.
+type KubernetesLeaseLock struct {
+	namespace string
+	name      string
+	endpoint  string
+	client    *http.Client
+
+	mu sync.Mutex
+	// observed holder and renew time of the lease last read, changed at observedAt on the local clock.
+	observed   string
+	observedAt time.Time
+}
+
+var _ LeaderLock = (*KubernetesLeaseLock)(nil)
+
+// NewKubernetesLeaseLock create a lock using the Lease name of namespace, or of the namespace of the pod if empty.
+// Return error if not running in a Kubernetes pod.
+func NewKubernetesLeaseLock(namespace string, name string) (*KubernetesLeaseLock, error) {
+	host, port := os.Getenv("KUBERNETES_SERVICE_HOST"), os.Getenv("KUBERNETES_SERVICE_PORT")
+	if host == "" || port == "" {
+		return nil, errors.New("error kubernetes lease lock must run in a kubernetes pod, provide a LeaderLock to use another backend")
+	}
+	if namespace == "" {
+		b, err := os.ReadFile(kubernetesServiceAccountDir + "/namespace")
+		if err != nil {
+			return nil, fmt.Errorf("error reading kubernetes namespace: %w", err)
+		}
+		namespace = strings.TrimSpace(string(b))
+	}
+	pem, err := os.ReadFile(kubernetesServiceAccountDir + "/ca.crt")
+	if err != nil {
+		return nil, fmt.Errorf("error reading kubernetes ca: %w", err)
+	}
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, errors.New("error kubernetes ca contains no certificate")
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{MinVersion: tls.VersionTLS12, RootCAs: pool}
+	return &KubernetesLeaseLock{
+		namespace: namespace,
+		name:      name,
+		endpoint:  "https://" + net.JoinHostPort(host, port) + "/apis/coordination.k8s.io/v1/namespaces/" + url.PathEscape(namespace) + "/leases",
+		client:    &http.Client{Transport: transport, Timeout: 10 * time.Second},
+	}, nil
+}
+
+func (l *KubernetesLeaseLock) TryLock(ctx context.Context, identity string, ttl time.Duration) (bool, error) {
+	lease, err := l.get(ctx)
+	if err != nil {
+		return false, err
+	}
+	now := time.Now()
+	seconds := max(int((ttl+time.Second-1)/time.Second), 1)
+	if lease == nil {
+		return l.write(ctx, http.MethodPost, l.endpoint, &kubernetesLease{
+			APIVersion: "coordination.k8s.io/v1",
+			Kind:       "Lease",
+			Metadata:   map[string]any{"name": l.name, "namespace": l.namespace},
+			Spec: kubernetesLeaseSpec{
+				HolderIdentity:       identity,
+				LeaseDurationSeconds: seconds,
+				AcquireTime:          now.UTC().Format(kubernetesMicroTimeFormat),
+				RenewTime:            now.UTC().Format(kubernetesMicroTimeFormat),
+			},
+		})
+	}
+
+	spec := &lease.Spec
+	if spec.HolderIdentity != identity {
+		if spec.HolderIdentity != "" && !l.isExpired(spec, now) {
+			return false, nil
+		}
+		spec.AcquireTime = now.UTC().Format(kubernetesMicroTimeFormat)
+		spec.LeaseTransitions++
+	}
+	spec.HolderIdentity = identity
+	spec.LeaseDurationSeconds = seconds
+	spec.RenewTime = now.UTC().Format(kubernetesMicroTimeFormat)
+	// The resource version of metadata make the update fail if the lease was changed by another instance.
+	return l.write(ctx, http.MethodPut, l.endpoint+"/"+url.PathEscape(l.name), lease)
+}
+
+func (l *KubernetesLeaseLock) Unlock(ctx context.Context, identity string) error {
+	lease, err := l.get(ctx)
+	if err != nil || lease == nil || lease.Spec.HolderIdentity != identity {
+		return err
+	}
+	lease.Spec.HolderIdentity = ""
+	lease.Spec.LeaseDurationSeconds = 1
+	_, err = l.write(ctx, http.MethodPut, l.endpoint+"/"+url.PathEscape(l.name), lease)
+	return err
+}
+
+// get return the lease, or nil if it does not exist.
+func (l *KubernetesLeaseLock) get(ctx context.Context) (*kubernetesLease, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, l.endpoint+"/"+url.PathEscape(l.name), nil)
+	if err != nil {
+		return nil, err
+	}
+	res, err := l.do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error reading kubernetes lease [%s/%s]: status %d", l.namespace, l.name, res.StatusCode)
+	}
+	var lease kubernetesLease
+	if err := json.NewDecoder(res.Body).Decode(&lease); err != nil {
+		return nil, fmt.Errorf("error decoding kubernetes lease [%s/%s]: %w", l.namespace, l.name, err)
+	}
+	return &lease, nil
+}
+
+// write create or update the lease, return false if it was changed concurrently.
+func (l *KubernetesLeaseLock) write(ctx context.Context, method string, endpoint string, lease *kubernetesLease) (bool, error) {
+	body, err := json.Marshal(lease)
+	if err != nil {
+		return false, err
+	}
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	res, err := l.do(req)
+	if err != nil {
+		return false, err
+	}
+	defer res.Body.Close()
+	switch res.StatusCode {
+	case http.StatusOK, http.StatusCreated:
+		return true, nil
+	case http.StatusConflict:
+		return false, nil
+	default:
+		return false, fmt.Errorf("error writing kubernetes lease [%s/%s]: status %d", l.namespace, l.name, res.StatusCode)
+	}
+}
+
+// do send the request using the service account token, which is read for each request as it is rotated.
+func (l *KubernetesLeaseLock) do(req *http.Request) (*http.Response, error) {
+	token, err := os.ReadFile(kubernetesServiceAccountDir + "/token")
+	if err != nil {
+		return nil, fmt.Errorf("error reading kubernetes service account token: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+strings.TrimSpace(string(token)))
+	req.Header.Set("Accept", "application/json")
+	return l.client.Do(req)
+}
+
+// isExpired whether the lease was not renewed in its duration. The duration is measured on the local clock since the
+// current renewal was first observed, as the clock of the holder may be skewed, so the renew time is only compared.
+func (l *KubernetesLeaseLock) isExpired(spec *kubernetesLeaseSpec, now time.Time) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	observed := spec.HolderIdentity + "@" + spec.RenewTime
+	if observed != l.observed {
+		l.observed, l.observedAt = observed, now
+		return false
+	}
+	return now.Sub(l.observedAt) >= time.Duration(spec.LeaseDurationSeconds)*time.Second
+}
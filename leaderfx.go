@@ -0,0 +1,308 @@
+package corefx
+
+import (
+	"context"
+	"fmt"
+	"go.uber.org/fx"
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+type LeaderElectionConfig interface {
+	// LeaderElectionLeaseNameValue name of the lease shared by all instances, default to the app name if empty.
+	LeaderElectionLeaseNameValue() string
+	// LeaderElectionNamespaceValue Kubernetes namespace of the lease, empty to use the namespace of the pod.
+	LeaderElectionNamespaceValue() string
+	// LeaderElectionIdentityValue identity of this instance, empty to use the hostname, which is the pod name in
+	// Kubernetes.
+	LeaderElectionIdentityValue() string
+	// LeaderElectionLeaseDurationValue duration the leadership is kept without renewal, the lease is renewed every
+	// third of this duration.
+	LeaderElectionLeaseDurationValue() time.Duration
+}
+
+type LeaderElectionEnv struct {
+	LeaderElectionLeaseName     string        `json:"leader_election_lease_name" mapstructure:"leader_election_lease_name"`
+	LeaderElectionNamespace     string        `json:"leader_election_namespace" mapstructure:"leader_election_namespace"`
+	LeaderElectionIdentity      string        `json:"leader_election_identity" mapstructure:"leader_election_identity"`
+	LeaderElectionLeaseDuration time.Duration `json:"leader_election_lease_duration" mapstructure:"leader_election_lease_duration" default:"15s"`
+}
+
+func (e LeaderElectionEnv) LeaderElectionLeaseNameValue() string {
+	return e.LeaderElectionLeaseName
+}
+
+func (e LeaderElectionEnv) LeaderElectionNamespaceValue() string {
+	return e.LeaderElectionNamespace
+}
+
+func (e LeaderElectionEnv) LeaderElectionIdentityValue() string {
+	return e.LeaderElectionIdentity
+}
+
+func (e LeaderElectionEnv) LeaderElectionLeaseDurationValue() time.Duration {
+	return e.LeaderElectionLeaseDuration
+}
+
+var _ LeaderElectionConfig = (*LeaderElectionEnv)(nil)
+
+// LeaderElector elect a single leader among the instances of the app.
+type LeaderElector interface {
+	// IsLeader whether this instance currently hold the leadership.
+	IsLeader() bool
+	// AwaitLeadership block until this instance hold the leadership or ctx is cancelled.
+	// The returned context is cancelled when the leadership is lost or ctx is cancelled, the cancel function must be
+	// called to release its resources.
+	AwaitLeadership(ctx context.Context) (context.Context, context.CancelFunc, error)
+}
+
+// LeaderLock a lease that can be held by a single instance at a time, used by the LeaseElector.
+// Implement this interface to elect the leader using another backend, for example Redis SET NX PX.
+type LeaderLock interface {
+	// TryLock acquire or renew the lease for identity, valid for ttl.
+	// Return false if the lease is held by another identity.
+	TryLock(ctx context.Context, identity string, ttl time.Duration) (bool, error)
+	// Unlock release the lease if it is held by identity.
+	Unlock(ctx context.Context, identity string) error
+}
+
+// LeaderOnly optional interface that Worker and ScheduledTask can implement to only run on the leader instance.
+// Leader-only workers are started when this instance become the leader, and cancelled when the leadership is lost.
+// Leader-only scheduled tasks are skipped when this instance is not the leader.
+type LeaderOnly interface {
+	LeaderOnly() bool
+}
+
+// isLeaderOnly whether v implement LeaderOnly and return true.
+func isLeaderOnly(v any) bool {
+	leaderOnly, ok := v.(LeaderOnly)
+	return ok && leaderOnly.LeaderOnly()
+}
+
+// LeaseElector a LeaderElector that hold the leadership while it can acquire and renew a LeaderLock.
+type LeaseElector struct {
+	lock     LeaderLock
+	identity string
+	ttl      time.Duration
+	logger   *slog.Logger
+	clock    Clock
+
+	mu sync.Mutex
+	// term context of the current leadership, nil if not the leader.
+	term       context.Context
+	cancelTerm context.CancelFunc
+	// changed closed and replaced when the leadership changes.
+	changed chan struct{}
+}
+
+var _ LeaderElector = (*LeaseElector)(nil)
+
+// NewLeaseElector create a LeaseElector that campaign for lock as identity once Run is called.
+func NewLeaseElector(lock LeaderLock, identity string, ttl time.Duration) *LeaseElector {
+	return &LeaseElector{
+		lock:     lock,
+		identity: identity,
+		ttl:      ttl,
+		logger:   slog.Default(),
+		clock:    RealClock(),
+		changed:  make(chan struct{}),
+	}
+}
+
+func (e *LeaseElector) IsLeader() bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.term != nil
+}
+
+func (e *LeaseElector) AwaitLeadership(ctx context.Context) (context.Context, context.CancelFunc, error) {
+	for {
+		e.mu.Lock()
+		term, changed := e.term, e.changed
+		e.mu.Unlock()
+		if term != nil {
+			leaderCtx, cancel := context.WithCancel(ctx)
+			stop := context.AfterFunc(term, cancel)
+			return leaderCtx, func() {
+				stop()
+				cancel()
+			}, nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil, nil, ctx.Err()
+		case <-changed:
+		}
+	}
+}
+
+// Run campaign for the leadership until ctx is cancelled, renewing the lock every third of its ttl.
+// Each attempt is bounded by a third of the ttl. The leadership is given up when the lock is held by another identity,
+// or when it was not renewed for the ttl, so a hung backend cannot leave two leaders. The lock is released when ctx is
+// cancelled.
+func (e *LeaseElector) Run(ctx context.Context) {
+	ticker := e.clock.NewTicker(e.ttl / 3)
+	defer ticker.Stop()
+	// expiry when the lock acquired by the last successful attempt expire, measured from the start of the attempt.
+	var expiry time.Time
+	for ctx.Err() == nil {
+		start := e.clock.Now()
+		timeout := e.ttl / 3
+		if e.IsLeader() {
+			timeout = min(timeout, expiry.Sub(start))
+		}
+		attemptCtx, cancel := context.WithTimeout(ctx, timeout)
+		acquired, err := e.lock.TryLock(attemptCtx, e.identity, e.ttl)
+		cancel()
+		if ctx.Err() != nil {
+			break
+		}
+		if err != nil {
+			e.logger.Warn("Error acquiring leadership", slog.String("identity", e.identity), slog.Any("err", err))
+		}
+		switch {
+		case err == nil:
+			if acquired {
+				expiry = start.Add(e.ttl)
+			}
+			e.setLeader(acquired)
+		case !e.clock.Now().Before(expiry):
+			// Keep the leadership on renewal errors until the lock expire.
+			e.setLeader(false)
+		}
+		e.await(ctx, ticker, expiry)
+	}
+
+	if e.IsLeader() {
+		e.setLeader(false)
+		// The ctx is cancelled, release using a new one.
+		unlockCtx, cancel := context.WithTimeout(context.Background(), e.ttl/3)
+		defer cancel()
+		if err := e.lock.Unlock(unlockCtx, e.identity); err != nil {
+			e.logger.Warn("Error releasing leadership", slog.String("identity", e.identity), slog.Any("err", err))
+		}
+	}
+}
+
+// await the next tick of ticker, giving up the leadership if the lock expire before.
+func (e *LeaseElector) await(ctx context.Context, ticker Ticker, expiry time.Time) {
+	if !e.IsLeader() {
+		select {
+		case <-ctx.Done():
+		case <-ticker.C():
+		}
+		return
+	}
+	timer := e.clock.NewTimer(expiry.Sub(e.clock.Now()))
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+	case <-ticker.C():
+	case <-timer.C():
+		e.logger.Warn("Leadership expired without renewal", slog.String("identity", e.identity))
+		e.setLeader(false)
+	}
+}
+
+// setLeader update the leadership state, notifying waiters if it changed.
+func (e *LeaseElector) setLeader(leader bool) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	if leader == (e.term != nil) {
+		return
+	}
+	if leader {
+		e.term, e.cancelTerm = context.WithCancel(context.Background())
+		e.logger.Info("Acquired leadership", slog.String("identity", e.identity))
+	} else {
+		e.cancelTerm()
+		e.term, e.cancelTerm = nil, nil
+		e.logger.Info("Lost leadership", slog.String("identity", e.identity))
+	}
+	close(e.changed)
+	e.changed = make(chan struct{})
+}
+
+type LeaderElectorParams struct {
+	fx.In
+	Loaded loadedConfig
+	Config LeaderElectionConfig `optional:"true"`
+	// Lock replace the Kubernetes lease, if provided.
+	Lock      LeaderLock `optional:"true"`
+	Logger    *slog.Logger
+	Clock     Clock
+	Lifecycle fx.Lifecycle
+}
+
+// NewLeaderElector create a LeaseElector using the provided LeaderLock, or a Kubernetes Lease if not provided,
+// configured by the LeaderElectionConfig, or the LeaderElectionEnv defaults if not registered.
+// The election runs with the app lifecycle, and the leadership is released when the app stops.
+func NewLeaderElector(p LeaderElectorParams) (LeaderElector, error) {
+	config := p.Config
+	if config == nil {
+		env := LeaderElectionEnv{}
+		if err := applyDefaults(&env); err != nil {
+			return nil, err
+		}
+		config = env
+	}
+	if config.LeaderElectionLeaseDurationValue() <= 0 {
+		return nil, fmt.Errorf("error invalid leader election lease duration [%s]", config.LeaderElectionLeaseDurationValue())
+	}
+	identity := config.LeaderElectionIdentityValue()
+	if identity == "" {
+		hostname, err := os.Hostname()
+		if err != nil {
+			return nil, fmt.Errorf("error resolving leader election identity: %w", err)
+		}
+		identity = hostname
+	}
+	lock := p.Lock
+	if lock == nil {
+		name := config.LeaderElectionLeaseNameValue()
+		if name == "" {
+			name = p.Loaded.config.AppNameValue()
+		}
+		var err error
+		if lock, err = NewKubernetesLeaseLock(config.LeaderElectionNamespaceValue(), name); err != nil {
+			return nil, err
+		}
+	}
+
+	elector := NewLeaseElector(lock, identity, config.LeaderElectionLeaseDurationValue())
+	elector.logger = p.Logger
+	elector.clock = p.Clock
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	p.Lifecycle.Append(fx.Hook{
+		OnStart: func(_ context.Context) error {
+			go func() {
+				defer close(done)
+				elector.Run(ctx)
+			}()
+			return nil
+		},
+		OnStop: func(stopCtx context.Context) error {
+			cancel()
+			select {
+			case <-done:
+				return nil
+			case <-stopCtx.Done():
+				return fmt.Errorf("error waiting for leader election to stop: %w", stopCtx.Err())
+			}
+		},
+	})
+	return elector, nil
+}
+
+// NewLeaderElectionModule provide a LeaderElector, which is used by the worker and scheduler modules to run the
+// workers and tasks implementing LeaderOnly on a single instance.
+// Must be used together with NewModule. By default, the leader is elected using a Kubernetes Lease, provide a
+// LeaderLock to use another backend.
+func NewLeaderElectionModule() fx.Option {
+	return fx.Module("corefx.leader",
+		fx.Provide(NewLeaderElector),
+	)
+}
@@ -0,0 +1,210 @@
+package corefx
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"go.uber.org/fx"
+	"hash/fnv"
+	"sync"
+	"time"
+)
+
+const (
+	LockBackendMemory   = "memory"
+	LockBackendPostgres = "postgres"
+)
+
+// ErrLockHeld returned by Locker.Acquire when the lock is held by another owner.
+var ErrLockHeld = errors.New("error lock is held by another owner")
+
+type LockConfig interface {
+	// LockBackendValue backend of the Locker: "memory" (single instance only) or "postgres" (advisory locks of the
+	// *sql.DB provided by NewSQLModule).
+	LockBackendValue() string
+}
+
+type LockEnv struct {
+	LockBackend string `json:"lock_backend" mapstructure:"lock_backend" default:"memory"`
+}
+
+func (e LockEnv) LockBackendValue() string {
+	return e.LockBackend
+}
+
+var _ LockConfig = (*LockEnv)(nil)
+
+// Locker acquire locks shared by the instances of the app, for example to run one-shot jobs once.
+type Locker interface {
+	// Acquire try to acquire the lock of key, without waiting, for at most ttl.
+	// Return ErrLockHeld if the lock is held by another owner.
+	Acquire(ctx context.Context, key string, ttl time.Duration) (Lease, error)
+}
+
+// Lease a held lock, which is released by Release or when its ttl elapsed.
+type Lease interface {
+	Key() string
+	// Release release the lock, do nothing if it is already released or expired.
+	Release(ctx context.Context) error
+}
+
+// MemoryLocker a Locker that only coordinate goroutines of the current process.
+type MemoryLocker struct {
+	mu sync.Mutex
+	// leases of held keys, removed on release, or replaced if expired.
+	leases map[string]*memoryLease
+}
+
+var _ Locker = (*MemoryLocker)(nil)
+
+// NewMemoryLocker create a locker that only coordinate goroutines of the current process.
+func NewMemoryLocker() *MemoryLocker {
+	return &MemoryLocker{leases: make(map[string]*memoryLease)}
+}
+
+func (l *MemoryLocker) Acquire(_ context.Context, key string, ttl time.Duration) (Lease, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	if lease, ok := l.leases[key]; ok && now.Before(lease.expiresAt) {
+		return nil, ErrLockHeld
+	}
+	lease := &memoryLease{locker: l, key: key, expiresAt: now.Add(ttl)}
+	l.leases[key] = lease
+	return lease, nil
+}
+
+type memoryLease struct {
+	locker    *MemoryLocker
+	key       string
+	expiresAt time.Time
+}
+
+func (l *memoryLease) Key() string {
+	return l.key
+}
+
+func (l *memoryLease) Release(_ context.Context) error {
+	l.locker.mu.Lock()
+	defer l.locker.mu.Unlock()
+	// The lease may have expired and been acquired by another owner.
+	if l.locker.leases[l.key] == l {
+		delete(l.locker.leases, l.key)
+	}
+	return nil
+}
+
+// PostgresLocker a Locker using session-level advisory locks of PostgreSQL.
+// Each lease hold a connection of the pool until it is released or expired.
+type PostgresLocker struct {
+	db *sql.DB
+}
+
+var _ Locker = (*PostgresLocker)(nil)
+
+// NewPostgresLocker create a locker using advisory locks of the PostgreSQL database db.
+func NewPostgresLocker(db *sql.DB) *PostgresLocker {
+	return &PostgresLocker{db: db}
+}
+
+func (l *PostgresLocker) Acquire(ctx context.Context, key string, ttl time.Duration) (Lease, error) {
+	conn, err := l.db.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("error acquiring lock [%s]: %w", key, err)
+	}
+	id := advisoryLockIDOf(key)
+	var acquired bool
+	if err := conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", id).Scan(&acquired); err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("error acquiring lock [%s]: %w", key, err)
+	}
+	if !acquired {
+		_ = conn.Close()
+		return nil, ErrLockHeld
+	}
+
+	lease := &postgresLease{conn: conn, key: key, id: id}
+	lease.timer = time.AfterFunc(ttl, func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		_ = lease.Release(ctx)
+	})
+	return lease, nil
+}
+
+type postgresLease struct {
+	conn  *sql.Conn
+	key   string
+	id    int64
+	timer *time.Timer
+	once  sync.Once
+	err   error
+}
+
+func (l *postgresLease) Key() string {
+	return l.key
+}
+
+func (l *postgresLease) Release(ctx context.Context) error {
+	l.once.Do(func() {
+		l.timer.Stop()
+		if _, err := l.conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", l.id); err != nil {
+			l.err = fmt.Errorf("error releasing lock [%s]: %w", l.key, err)
+			// Discard the connection instead of returning it to the pool while holding the lock,
+			// the lock is released when the session ends.
+			_ = l.conn.Raw(func(_ any) error {
+				return driver.ErrBadConn
+			})
+		}
+		_ = l.conn.Close()
+	})
+	return l.err
+}
+
+// advisoryLockIDOf return the advisory lock id of key.
+func advisoryLockIDOf(key string) int64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(key))
+	// nolint:gosec
+	return int64(h.Sum64())
+}
+
+type LockerParams struct {
+	fx.In
+	Loaded loadedConfig
+	Config LockConfig `optional:"true"`
+	DB     *sql.DB    `optional:"true"`
+}
+
+// NewLocker create the Locker of the backend configured by the LockConfig, or the LockEnv defaults if not registered.
+func NewLocker(p LockerParams) (Locker, error) {
+	config := p.Config
+	if config == nil {
+		env := LockEnv{}
+		if err := applyDefaults(&env); err != nil {
+			return nil, err
+		}
+		config = env
+	}
+	switch config.LockBackendValue() {
+	case LockBackendMemory:
+		return NewMemoryLocker(), nil
+	case LockBackendPostgres:
+		if p.DB == nil {
+			return nil, errors.New("error postgres lock backend requires a *sql.DB, use NewSQLModule")
+		}
+		return NewPostgresLocker(p.DB), nil
+	default:
+		return nil, fmt.Errorf("error unsupported lock backend [%s]", config.LockBackendValue())
+	}
+}
+
+// NewLockModule provide a Locker, using the backend configured by LockConfig.
+// Must be used together with NewModule, and NewSQLModule for the postgres backend.
+func NewLockModule() fx.Option {
+	return fx.Module("corefx.lock",
+		fx.Provide(NewLocker),
+	)
+}
@@ -0,0 +1,216 @@
+package corefx
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"go.uber.org/fx"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RequestIDHeader header that carry the request id, used by RequestIDMiddleware.
+const RequestIDHeader = "X-Request-ID"
+
+// maxRequestIDLength max length of request ids accepted by RequestIDMiddleware.
+const maxRequestIDLength = 128
+
+// crockfordAlphabet the Crockford base32 alphabet used to encode ULIDs.
+const crockfordAlphabet = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// CloudTraceHeader header that carry the trace of requests forwarded by Google Cloud load balancers and Cloud Run,
+// in the TRACE_ID/SPAN_ID;o=OPTIONS format.
+const CloudTraceHeader = "X-Cloud-Trace-Context"
+
+type contextKey int
+
+const (
+	loggerContextKey contextKey = iota
+	traceIDContextKey
+	spanIDContextKey
+	requestIDContextKey
+)
+
+// ContextWithLogger return a copy of ctx carrying logger, which can be retrieved using FromContext.
+func ContextWithLogger(ctx context.Context, logger *slog.Logger) context.Context {
+	return context.WithValue(ctx, loggerContextKey, logger)
+}
+
+// FromContext return the logger of ctx, or slog.Default if ctx does not carry a logger.
+func FromContext(ctx context.Context) *slog.Logger {
+	if logger, ok := ctx.Value(loggerContextKey).(*slog.Logger); ok && logger != nil {
+		return logger
+	}
+	return slog.Default()
+}
+
+// ContextWithTrace return a copy of ctx carrying the trace and span id, which are added to log records of ctx.
+func ContextWithTrace(ctx context.Context, traceID string, spanID string) context.Context {
+	ctx = context.WithValue(ctx, traceIDContextKey, traceID)
+	return context.WithValue(ctx, spanIDContextKey, spanID)
+}
+
+// ContextWithRequestID return a copy of ctx carrying the request id, which is added to log records of ctx.
+func ContextWithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDContextKey, requestID)
+}
+
+// RequestIDFromContext return the request id of ctx, or empty string if ctx does not carry a request id.
+func RequestIDFromContext(ctx context.Context) string {
+	requestID, _ := ctx.Value(requestIDContextKey).(string)
+	return requestID
+}
+
+// RequestIDMiddleware store the request id of the X-Request-ID header into the request context,
+// generating a ULID if missing or invalid. The request id is also written to the response header, added to log
+// records of the request context, and forwarded by the http client of NewHTTPClient.
+func RequestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(RequestIDHeader)
+		if !isValidRequestID(requestID) {
+			requestID = newRequestID()
+		}
+		w.Header().Set(RequestIDHeader, requestID)
+		next.ServeHTTP(w, r.WithContext(ContextWithRequestID(r.Context(), requestID)))
+	})
+}
+
+// TraceMiddleware store the trace of the W3C traceparent header, or the X-Cloud-Trace-Context header, into the request
+// context, see ContextWithTrace. Requests without a valid trace header are passed unchanged.
+func TraceMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if traceID, spanID, ok := traceOfRequest(r); ok {
+			r = r.WithContext(ContextWithTrace(r.Context(), traceID, spanID))
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// traceOfRequest return the trace and span id of the request headers.
+func traceOfRequest(r *http.Request) (string, string, bool) {
+	if parts := strings.Split(r.Header.Get("traceparent"), "-"); len(parts) == 4 {
+		if traceIDPattern.MatchString(parts[1]) && spanIDPattern.MatchString(parts[2]) {
+			return parts[1], parts[2], true
+		}
+	}
+	traceID, rest, _ := strings.Cut(r.Header.Get(CloudTraceHeader), "/")
+	traceID = strings.ToLower(traceID)
+	if !traceIDPattern.MatchString(traceID) {
+		return "", "", false
+	}
+	span, _, _ := strings.Cut(rest, ";")
+	spanID, err := strconv.ParseUint(span, 10, 64)
+	if err != nil {
+		return "", "", false
+	}
+	return traceID, fmt.Sprintf("%016x", spanID), true
+}
+
+// newRequestID generate a ULID, which is sortable by generation time.
+func newRequestID() string {
+	var b [16]byte
+	ms := uint64(time.Now().UnixMilli())
+	for i := 0; i < 6; i++ {
+		b[i] = byte(ms >> (40 - 8*i))
+	}
+	_, _ = rand.Read(b[6:])
+	hi, lo := binary.BigEndian.Uint64(b[:8]), binary.BigEndian.Uint64(b[8:])
+	var id [26]byte
+	for i := len(id) - 1; i >= 0; i-- {
+		id[i] = crockfordAlphabet[lo&31]
+		lo = lo>>5 | hi<<59
+		hi >>= 5
+	}
+	return string(id[:])
+}
+
+// isValidRequestID whether requestID is non-empty, at most 128 characters, and contain only printable ASCII without
+// spaces, so client provided ids cannot forge log lines or headers.
+func isValidRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(requestID); i++ {
+		if requestID[i] <= ' ' || requestID[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
+// ContextAttrsFunc extract log attributes from the context of a log record,
+// for example the trace and span id of an OpenTelemetry span.
+type ContextAttrsFunc func(ctx context.Context) []slog.Attr
+
+// AsContextAttrs annotate a ContextAttrsFunc constructor to register it into the context attributes group.
+// For example, fx.Provide(corefx.AsContextAttrs(newSpanContextAttrs)).
+func AsContextAttrs(f any) any {
+	return fx.Annotate(
+		f,
+		fx.ResultTags(`group:"log_context_attrs"`),
+	)
+}
+
+// ContextKeyAttrs create a ContextAttrsFunc that add the value of the context key as the named attribute,
+// if ctx carry the key.
+func ContextKeyAttrs(name string, key any) ContextAttrsFunc {
+	return func(ctx context.Context) []slog.Attr {
+		value := ctx.Value(key)
+		if value == nil {
+			return nil
+		}
+		return []slog.Attr{slog.Any(name, value)}
+	}
+}
+
+// contextAttrsOf return the trace_id, span_id and request_id attributes carried by ctx.
+func contextAttrsOf(ctx context.Context) []slog.Attr {
+	var attrs []slog.Attr
+	if traceID, _ := ctx.Value(traceIDContextKey).(string); traceID != "" {
+		attrs = append(attrs, slog.String("trace_id", traceID))
+	}
+	if spanID, _ := ctx.Value(spanIDContextKey).(string); spanID != "" {
+		attrs = append(attrs, slog.String("span_id", spanID))
+	}
+	if requestID := RequestIDFromContext(ctx); requestID != "" {
+		attrs = append(attrs, slog.String("request_id", requestID))
+	}
+	return attrs
+}
+
+// contextHandler add attributes extracted from the context to every record.
+type contextHandler struct {
+	slog.Handler
+	extractors []ContextAttrsFunc
+}
+
+func newContextHandler(next slog.Handler, extractors []ContextAttrsFunc) slog.Handler {
+	return &contextHandler{Handler: next, extractors: append([]ContextAttrsFunc{contextAttrsOf}, extractors...)}
+}
+
+func (h *contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if ctx == nil {
+		return h.Handler.Handle(ctx, r)
+	}
+	var attrs []slog.Attr
+	for _, extract := range h.extractors {
+		attrs = append(attrs, extract(ctx)...)
+	}
+	if len(attrs) > 0 {
+		r = r.Clone()
+		r.AddAttrs(attrs...)
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithAttrs(attrs), extractors: h.extractors}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithGroup(name), extractors: h.extractors}
+}
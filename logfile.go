@@ -0,0 +1,247 @@
+package corefx
+
+import (
+	"compress/gzip"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// logFileTimeFormat format of the timestamp appended to rotated log files.
+const logFileTimeFormat = "2006-01-02T15-04-05.000"
+
+const (
+	LogOutputStdout  = "stdout"
+	LogOutputStderr  = "stderr"
+	LogOutputDiscard = "discard"
+)
+
+// LogOutputConfig optional interface that a CoreConfig can implement to choose the destination of the console/JSON
+// handler. By default, JSON logs are written to stdout and console logs to stderr.
+type LogOutputConfig interface {
+	// LogOutputValue "stdout", "stderr", "discard", or the path of a file to write logs into.
+	LogOutputValue() string
+}
+
+type LogOutputEnv struct {
+	LogOutput string `json:"log_output" mapstructure:"log_output"`
+}
+
+func (e LogOutputEnv) LogOutputValue() string {
+	return e.LogOutput
+}
+
+var _ LogOutputConfig = (*LogOutputEnv)(nil)
+
+// LogFileConfig optional interface that a CoreConfig can implement to write logs into a file with rotation,
+// alongside the console/JSON handler. Records are written in JSON format.
+// A "stdout", "stderr" or "discard" output disable file logging and set the destination of the console/JSON handler
+// instead, see LogOutputConfig.
+type LogFileConfig interface {
+	// LogOutputValue path of the log file, empty to disable file logging.
+	LogOutputConfig
+	// LogMaxSizeValue max size in megabytes of the log file before it get rotated, zero to disable rotation.
+	LogMaxSizeValue() int
+	// LogMaxBackupsValue max number of rotated files to keep, zero to keep all.
+	LogMaxBackupsValue() int
+	// LogMaxAgeValue max age of rotated files to keep, zero to keep all.
+	LogMaxAgeValue() time.Duration
+	// LogCompressValue whether to compress rotated files using gzip.
+	LogCompressValue() bool
+}
+
+type LogFileEnv struct {
+	LogOutput     string        `json:"log_output" mapstructure:"log_output"`
+	LogMaxSize    int           `json:"log_max_size" mapstructure:"log_max_size" default:"100"`
+	LogMaxBackups int           `json:"log_max_backups" mapstructure:"log_max_backups"`
+	LogMaxAge     time.Duration `json:"log_max_age" mapstructure:"log_max_age"`
+	LogCompress   bool          `json:"log_compress" mapstructure:"log_compress"`
+}
+
+func (e LogFileEnv) LogOutputValue() string {
+	return e.LogOutput
+}
+
+func (e LogFileEnv) LogMaxSizeValue() int {
+	return e.LogMaxSize
+}
+
+func (e LogFileEnv) LogMaxBackupsValue() int {
+	return e.LogMaxBackups
+}
+
+func (e LogFileEnv) LogMaxAgeValue() time.Duration {
+	return e.LogMaxAge
+}
+
+func (e LogFileEnv) LogCompressValue() bool {
+	return e.LogCompress
+}
+
+var _ LogFileConfig = (*LogFileEnv)(nil)
+
+// rotatingFile io.WriteCloser that write into a file, rotating it when it reach the max size.
+// Rotated files are renamed with a timestamp suffix, for example app-2006-01-02T15-04-05.000.log.
+type rotatingFile struct {
+	path       string
+	maxSize    int64
+	maxBackups int
+	maxAge     time.Duration
+	compress   bool
+
+	mu   sync.Mutex
+	file *os.File
+	size int64
+	// cleanupMu prevent concurrent cleanups of rotated files.
+	cleanupMu sync.Mutex
+}
+
+func newRotatingFile(c LogFileConfig) *rotatingFile {
+	return &rotatingFile{
+		path:       c.LogOutputValue(),
+		maxSize:    int64(c.LogMaxSizeValue()) * 1024 * 1024,
+		maxBackups: c.LogMaxBackupsValue(),
+		maxAge:     c.LogMaxAgeValue(),
+		compress:   c.LogCompressValue(),
+	}
+}
+
+func (f *rotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		if err := f.open(); err != nil {
+			return 0, err
+		}
+	}
+	if f.maxSize > 0 && f.size > 0 && f.size+int64(len(p)) > f.maxSize {
+		if err := f.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+func (f *rotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
+
+func (f *rotatingFile) open() error {
+	if err := os.MkdirAll(filepath.Dir(f.path), 0o755); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(f.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		_ = file.Close()
+		return err
+	}
+	f.file, f.size = file, info.Size()
+	return nil
+}
+
+func (f *rotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	f.file = nil
+	ext := filepath.Ext(f.path)
+	backup := strings.TrimSuffix(f.path, ext) + "-" + time.Now().Format(logFileTimeFormat) + ext
+	if err := os.Rename(f.path, backup); err != nil {
+		return err
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+	go f.cleanup()
+	return nil
+}
+
+// cleanup compress rotated files and remove the ones over max backups or max age.
+func (f *rotatingFile) cleanup() {
+	f.cleanupMu.Lock()
+	defer f.cleanupMu.Unlock()
+	ext := filepath.Ext(f.path)
+	prefix := strings.TrimSuffix(filepath.Base(f.path), ext) + "-"
+	entries, err := os.ReadDir(filepath.Dir(f.path))
+	if err != nil {
+		return
+	}
+
+	var backups []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) {
+			continue
+		}
+		if !strings.HasSuffix(name, ext) && !strings.HasSuffix(name, ext+".gz") {
+			continue
+		}
+		backups = append(backups, filepath.Join(filepath.Dir(f.path), name))
+	}
+	// Newest first, the timestamp suffix sort lexically.
+	sort.Sort(sort.Reverse(sort.StringSlice(backups)))
+
+	for i, backup := range backups {
+		expired := false
+		if f.maxAge > 0 {
+			if info, err := os.Stat(backup); err == nil && time.Since(info.ModTime()) > f.maxAge {
+				expired = true
+			}
+		}
+		if expired || (f.maxBackups > 0 && i >= f.maxBackups) {
+			_ = os.Remove(backup)
+			continue
+		}
+		if f.compress && !strings.HasSuffix(backup, ".gz") {
+			if err := gzipFile(backup); err != nil {
+				slog.Warn("Error compressing log file", slog.String("file", backup), slog.Any("err", err))
+			}
+		}
+	}
+}
+
+// gzipFile compress the file into file.gz, then remove the original.
+func gzipFile(path string) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+	dst, err := os.OpenFile(path+".gz", os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(dst)
+	if _, err := io.Copy(gz, src); err != nil {
+		_ = gz.Close()
+		_ = dst.Close()
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		_ = dst.Close()
+		return err
+	}
+	if err := dst.Close(); err != nil {
+		return err
+	}
+	_ = src.Close()
+	return os.Remove(path)
+}
@@ -0,0 +1,71 @@
+package corefx
+
+import (
+	"context"
+	"log/slog"
+)
+
+// flatHandler slog.Handler that pass records with their attributes flattened to a handle function.
+// Attributes of groups are flattened using "." separated keys, for example request.id.
+// Records are not filtered by level, use levelHandler to filter them.
+type flatHandler struct {
+	handle func(r slog.Record, attrs []slog.Attr) error
+	attrs  []slog.Attr
+	group  string
+}
+
+func newFlatHandler(handle func(r slog.Record, attrs []slog.Attr) error) slog.Handler {
+	return &flatHandler{handle: handle}
+}
+
+func (h *flatHandler) Enabled(_ context.Context, _ slog.Level) bool {
+	return true
+}
+
+func (h *flatHandler) Handle(_ context.Context, r slog.Record) error {
+	attrs := make([]slog.Attr, len(h.attrs), len(h.attrs)+r.NumAttrs())
+	copy(attrs, h.attrs)
+	r.Attrs(func(attr slog.Attr) bool {
+		attrs = appendFlatAttr(attrs, h.group, attr)
+		return true
+	})
+	return h.handle(r, attrs)
+}
+
+func (h *flatHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	next := *h
+	next.attrs = make([]slog.Attr, len(h.attrs), len(h.attrs)+len(attrs))
+	copy(next.attrs, h.attrs)
+	for _, attr := range attrs {
+		next.attrs = appendFlatAttr(next.attrs, h.group, attr)
+	}
+	return &next
+}
+
+func (h *flatHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	next := *h
+	next.group = joinConfigPath(h.group, name)
+	return &next
+}
+
+// appendFlatAttr append attr with key prefixed by group, nested groups are flattened.
+func appendFlatAttr(attrs []slog.Attr, group string, attr slog.Attr) []slog.Attr {
+	attr.Value = attr.Value.Resolve()
+	if attr.Equal(slog.Attr{}) {
+		return attrs
+	}
+	if attr.Value.Kind() == slog.KindGroup {
+		if attr.Key != "" {
+			group = joinConfigPath(group, attr.Key)
+		}
+		for _, child := range attr.Value.Group() {
+			attrs = appendFlatAttr(attrs, group, child)
+		}
+		return attrs
+	}
+	attr.Key = joinConfigPath(group, attr.Key)
+	return append(attrs, attr)
+}
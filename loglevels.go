@@ -0,0 +1,208 @@
+package corefx
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// LogLevelsConfig optional interface that a CoreConfig can implement to override the log level of named loggers
+// created using LoggerFor.
+type LogLevelsConfig interface {
+	// LogLevelsValue log level by logger name, for example {"github.com/x/db": "debug", "noisy-module": "error"}.
+	// An override also apply to loggers whose name is prefixed by it followed by "/" or ".".
+	LogLevelsValue() map[string]string
+}
+
+type LogLevelsEnv struct {
+	// LogLevels log level by logger name.
+	// Names containing "." are loaded as nested keys, so the value is flattened back into names.
+	LogLevels map[string]any `json:"log_levels" mapstructure:"log_levels"`
+}
+
+func (e LogLevelsEnv) LogLevelsValue() map[string]string {
+	levels := make(map[string]string, len(e.LogLevels))
+	flattenLogLevels(levels, "", e.LogLevels)
+	return levels
+}
+
+var _ LogLevelsConfig = (*LogLevelsEnv)(nil)
+
+func flattenLogLevels(levels map[string]string, prefix string, values map[string]any) {
+	for name, value := range values {
+		name = joinConfigPath(prefix, name)
+		if nested, ok := value.(map[string]any); ok {
+			flattenLogLevels(levels, name, nested)
+			continue
+		}
+		if level, ok := value.(string); ok {
+			levels[name] = level
+		}
+	}
+}
+
+// globalLogLevels levels of the global logger and its named loggers.
+var globalLogLevels atomic.Pointer[logLevels]
+
+// logLevels track the level of the global logger and the levels of named loggers.
+type logLevels struct {
+	mu sync.Mutex
+	// global level of the global logger, and of named loggers without override.
+	global *slog.LevelVar
+	// min lowest level of all loggers, used by the handlers.
+	min       *slog.LevelVar
+	overrides map[string]slog.Level
+	named     map[string]*slog.LevelVar
+	// base handler of the global logger, without level filtering.
+	base slog.Handler
+}
+
+func newLogLevels() *logLevels {
+	return &logLevels{
+		global: &slog.LevelVar{},
+		min:    &slog.LevelVar{},
+		named:  map[string]*slog.LevelVar{},
+	}
+}
+
+// logLevelsOf return the level overrides of config.
+// The level of fx events of FxLogConfig is an override of the fx logger.
+func logLevelsOf(cfg CoreConfig) map[string]slog.Level {
+	overrides := map[string]slog.Level{}
+	if c, ok := cfg.(LogLevelsConfig); ok {
+		for name, level := range c.LogLevelsValue() {
+			overrides[strings.ToLower(name)] = parseLogLevel(level)
+		}
+	}
+	if c, ok := cfg.(FxLogConfig); ok && c.FxLogLevelValue() != "" {
+		overrides[fxLoggerName] = parseLogLevel(c.FxLogLevelValue())
+	}
+	return overrides
+}
+
+// set update the global level and the overrides, then re-apply levels of named loggers.
+func (l *logLevels) set(global slog.Level, overrides map[string]slog.Level) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.global.Set(global)
+	l.overrides = overrides
+	minLevel := global
+	for _, level := range overrides {
+		minLevel = min(minLevel, level)
+	}
+	l.min.Set(minLevel)
+	for name, level := range l.named {
+		level.Set(l.levelOf(name))
+	}
+}
+
+// setGlobal update the global level, keeping the overrides.
+func (l *logLevels) setGlobal(global slog.Level) {
+	l.mu.Lock()
+	overrides := l.overrides
+	l.mu.Unlock()
+	l.set(global, overrides)
+}
+
+// levelOf return the level of the named logger, using the longest matching override.
+func (l *logLevels) levelOf(name string) slog.Level {
+	name = strings.ToLower(name)
+	names := make([]string, 0, len(l.overrides))
+	for override := range l.overrides {
+		names = append(names, override)
+	}
+	sort.Slice(names, func(i, j int) bool {
+		return len(names[i]) > len(names[j])
+	})
+	for _, override := range names {
+		if name == override || strings.HasPrefix(name, override+"/") || strings.HasPrefix(name, override+".") {
+			return l.overrides[override]
+		}
+	}
+	return l.global.Level()
+}
+
+// logger return a named logger using the base handler.
+func (l *logLevels) logger(name string) *slog.Logger {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	level, ok := l.named[name]
+	if !ok {
+		level = &slog.LevelVar{}
+		level.Set(l.levelOf(name))
+		l.named[name] = level
+	}
+	handler := l.base.WithAttrs([]slog.Attr{slog.String("logger", name)})
+	return slog.New(&levelHandler{Handler: handler, level: level})
+}
+
+// LoggerFor return a named child logger of the global logger, for example corefx.LoggerFor("github.com/x/db").
+// The logger level can be overridden using LogLevelsConfig, and is re-applied when the config is reloaded.
+// Must be called after the global logger is created, for example in a constructor that depend on *slog.Logger,
+// otherwise the returned logger is a child of slog.Default without level override.
+func LoggerFor(name string) *slog.Logger {
+	levels := globalLogLevels.Load()
+	if levels == nil {
+		return slog.Default().With(slog.String("logger", name))
+	}
+	return levels.logger(name)
+}
+
+// LevelController change the level of the logger of the app at runtime, provided with the logger of the app.
+// The level is reset to the configured level when the config is reloaded.
+type LevelController struct {
+	levels *logLevels
+	logger *slog.Logger
+}
+
+// Logger return a named child logger of the logger of the app, like LoggerFor, but also usable with WithLocalLogger.
+func (c *LevelController) Logger(name string) *slog.Logger {
+	return c.levels.logger(name)
+}
+
+// Level return the current level of the logger.
+func (c *LevelController) Level() slog.Level {
+	return c.levels.global.Level()
+}
+
+// SetLevel change the level of the logger, and of named loggers without override.
+func (c *LevelController) SetLevel(level slog.Level) {
+	c.levels.setGlobal(level)
+	c.logger.Info("Changed log level", slog.String("level", level.String()))
+}
+
+// ServeHTTP return the current level on GET, and change the level on PUT.
+// The level is read from the level query parameter or the JSON body, for example {"level": "debug"}.
+func (c *LevelController) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		level := r.URL.Query().Get("level")
+		if level == "" {
+			var body struct {
+				Level string `json:"level"`
+			}
+			if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+				http.Error(w, "invalid body: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+			level = body.Level
+		}
+		var l slog.Level
+		if err := l.UnmarshalText([]byte(level)); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		c.SetLevel(l)
+	default:
+		w.Header().Set("Allow", "GET, PUT")
+		w.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(map[string]string{"level": c.Level().String()})
+}
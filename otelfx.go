@@ -0,0 +1,306 @@
+package corefx
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go.uber.org/fx"
+	"log/slog"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// otelLogBatchSize max number of records sent in a single export request.
+	otelLogBatchSize = 512
+	// otelLogFlushInterval interval between exports of buffered records.
+	otelLogFlushInterval = time.Second
+	// otelLogMaxBuffer max number of buffered records, newer records are dropped when the collector is unreachable.
+	otelLogMaxBuffer = 8 * otelLogBatchSize
+)
+
+type OTelConfig interface {
+	// OTelEndpointValue base url of the OTLP/HTTP collector, for example http://otel-collector:4318.
+	// Logs are sent to the /v1/logs path. Default to OTEL_EXPORTER_OTLP_ENDPOINT env if empty.
+	OTelEndpointValue() string
+	// OTelHeadersValue headers sent with each export request, in the format key1=value1,key2=value2.
+	// Default to OTEL_EXPORTER_OTLP_HEADERS env if empty.
+	OTelHeadersValue() string
+}
+
+type OTelEnv struct {
+	OTelEndpoint string `json:"otel_endpoint" mapstructure:"otel_endpoint"`
+	OTelHeaders  string `json:"otel_headers" mapstructure:"otel_headers" secret:"true"`
+}
+
+func (e OTelEnv) OTelEndpointValue() string {
+	return e.OTelEndpoint
+}
+
+func (e OTelEnv) OTelHeadersValue() string {
+	return e.OTelHeaders
+}
+
+var _ OTelConfig = (*OTelEnv)(nil)
+
+// AsLogHandler annotate a slog.Handler constructor to fan the log output into it, alongside the console/JSON handler.
+// Records are filtered using the application log level.
+// For example, fx.Provide(corefx.AsLogHandler(newMyHandler)).
+func AsLogHandler(f any) any {
+	return fx.Annotate(
+		f,
+		fx.As(new(slog.Handler)),
+		fx.ResultTags(`group:"log_handlers"`),
+	)
+}
+
+// OTelLogExporter export log records to an OTLP/HTTP collector using the JSON encoding.
+// Records are buffered and exported in batches in background.
+type OTelLogExporter struct {
+	endpoint string
+	headers  map[string]string
+	resource []otelKeyValue
+	client   *http.Client
+
+	mu      sync.Mutex
+	records []otelLogRecord
+	flushCh chan struct{}
+	stopCh  chan struct{}
+	doneCh  chan struct{}
+}
+
+type OTelLogExporterParams struct {
+	fx.In
+	Loaded     loadedConfig
+	OTelConfig OTelConfig `optional:"true"`
+	Lifecycle  fx.Lifecycle
+}
+
+// NewOTelLogExporter create an OTLP log exporter, which is started and flushed with the app lifecycle.
+func NewOTelLogExporter(p OTelLogExporterParams) (*OTelLogExporter, error) {
+	endpoint, headers := os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT"), os.Getenv("OTEL_EXPORTER_OTLP_HEADERS")
+	if p.OTelConfig != nil {
+		if p.OTelConfig.OTelEndpointValue() != "" {
+			endpoint = p.OTelConfig.OTelEndpointValue()
+		}
+		if p.OTelConfig.OTelHeadersValue() != "" {
+			headers = p.OTelConfig.OTelHeadersValue()
+		}
+	}
+	if endpoint == "" {
+		return nil, errors.New("error otel endpoint is not configured")
+	}
+
+	cfg := p.Loaded.config
+	resource := []otelKeyValue{otelAttrOf(slog.String("service.name", cfg.AppNameValue()))}
+	if cfg.AppVersionValue() != "" {
+		resource = append(resource, otelAttrOf(slog.String("service.version", cfg.AppVersionValue())))
+	}
+	if cfg.ProfileValue() != "" {
+		resource = append(resource, otelAttrOf(slog.String("deployment.environment", cfg.ProfileValue())))
+	}
+
+	e := &OTelLogExporter{
+		endpoint: strings.TrimSuffix(endpoint, "/") + "/v1/logs",
+		headers:  parseOTelHeaders(headers),
+		resource: resource,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		flushCh:  make(chan struct{}, 1),
+		stopCh:   make(chan struct{}),
+		doneCh:   make(chan struct{}),
+	}
+	p.Lifecycle.Append(fx.Hook{
+		OnStart: func(_ context.Context) error {
+			go e.run()
+			return nil
+		},
+		OnStop: func(ctx context.Context) error {
+			close(e.stopCh)
+			select {
+			case <-e.doneCh:
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			return e.flush(ctx)
+		},
+	})
+	return e, nil
+}
+
+// Handler return a slog.Handler that export records using this exporter.
+func (e *OTelLogExporter) Handler() slog.Handler {
+	return newFlatHandler(e.handle)
+}
+
+func (e *OTelLogExporter) run() {
+	defer close(e.doneCh)
+	ticker := time.NewTicker(otelLogFlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-e.stopCh:
+			return
+		case <-ticker.C:
+		case <-e.flushCh:
+		}
+		if err := e.flush(context.Background()); err != nil {
+			// Logging using slog would export the error again.
+			_, _ = fmt.Fprintln(os.Stderr, "error exporting logs to otel collector:", err)
+		}
+	}
+}
+
+func (e *OTelLogExporter) export(record otelLogRecord) {
+	e.mu.Lock()
+	if len(e.records) < otelLogMaxBuffer {
+		e.records = append(e.records, record)
+	}
+	full := len(e.records) >= otelLogBatchSize
+	e.mu.Unlock()
+	if full {
+		select {
+		case e.flushCh <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// flush export all buffered records, records of failed batches are dropped.
+func (e *OTelLogExporter) flush(ctx context.Context) error {
+	for {
+		e.mu.Lock()
+		batch := e.records[:min(len(e.records), otelLogBatchSize)]
+		e.records = e.records[len(batch):]
+		e.mu.Unlock()
+		if len(batch) == 0 {
+			return nil
+		}
+		if err := e.send(ctx, batch); err != nil {
+			return err
+		}
+	}
+}
+
+func (e *OTelLogExporter) send(ctx context.Context, records []otelLogRecord) error {
+	body, err := json.Marshal(map[string]any{
+		"resourceLogs": []any{map[string]any{
+			"resource": map[string]any{"attributes": e.resource},
+			"scopeLogs": []any{map[string]any{
+				"scope":      map[string]any{"name": "github.com/mawngo/go-corefx"},
+				"logRecords": records,
+			}},
+		}},
+	})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.headers {
+		req.Header.Set(k, v)
+	}
+	res, err := e.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	if res.StatusCode/100 != 2 {
+		return fmt.Errorf("error exporting logs: status %d", res.StatusCode)
+	}
+	return nil
+}
+
+// parseOTelHeaders parse headers in the format key1=value1,key2=value2.
+func parseOTelHeaders(s string) map[string]string {
+	headers := map[string]string{}
+	for _, pair := range strings.Split(s, ",") {
+		k, v, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(k) == "" {
+			continue
+		}
+		headers[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	return headers
+}
+
+// handle convert the record into an OTLP log record and buffer it for exporting.
+func (e *OTelLogExporter) handle(r slog.Record, attrs []slog.Attr) error {
+	record := otelLogRecord{
+		TimeUnixNano: strconv.FormatInt(r.Time.UnixNano(), 10),
+		// Severity numbers of slog levels: debug 5, info 9, warn 13, error 17.
+		SeverityNumber: min(max(int(r.Level)+9, 1), 24),
+		SeverityText:   r.Level.String(),
+		Body:           otelValue{StringValue: &r.Message},
+		Attributes:     make([]otelKeyValue, 0, len(attrs)),
+	}
+	for _, attr := range attrs {
+		record.Attributes = append(record.Attributes, otelAttrOf(attr))
+	}
+	e.export(record)
+	return nil
+}
+
+type otelLogRecord struct {
+	TimeUnixNano   string         `json:"timeUnixNano"`
+	SeverityNumber int            `json:"severityNumber"`
+	SeverityText   string         `json:"severityText"`
+	Body           otelValue      `json:"body"`
+	Attributes     []otelKeyValue `json:"attributes,omitempty"`
+}
+
+type otelKeyValue struct {
+	Key   string    `json:"key"`
+	Value otelValue `json:"value"`
+}
+
+type otelValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	BoolValue   *bool   `json:"boolValue,omitempty"`
+	// IntValue int64 encoded as string, following the protobuf JSON mapping.
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+}
+
+func otelAttrOf(attr slog.Attr) otelKeyValue {
+	var value otelValue
+	switch attr.Value.Kind() {
+	case slog.KindBool:
+		b := attr.Value.Bool()
+		value.BoolValue = &b
+	case slog.KindInt64:
+		i := strconv.FormatInt(attr.Value.Int64(), 10)
+		value.IntValue = &i
+	case slog.KindUint64:
+		i := strconv.FormatUint(attr.Value.Uint64(), 10)
+		value.IntValue = &i
+	case slog.KindFloat64:
+		f := attr.Value.Float64()
+		value.DoubleValue = &f
+	default:
+		s := attr.Value.String()
+		value.StringValue = &s
+	}
+	return otelKeyValue{Key: attr.Key, Value: value}
+}
+
+// WithOTelLogs fan the log output into an OTLP/HTTP log exporter, alongside the console/JSON handler.
+// Must be used together with NewModule.
+// The env config can register as OTelConfig to configure the collector endpoint and headers,
+// otherwise OTEL_EXPORTER_OTLP_ENDPOINT and OTEL_EXPORTER_OTLP_HEADERS env are used.
+func WithOTelLogs() fx.Option {
+	return fx.Module("corefx.otel",
+		fx.Provide(NewOTelLogExporter),
+		fx.Provide(AsLogHandler(func(e *OTelLogExporter) slog.Handler {
+			return e.Handler()
+		})),
+	)
+}
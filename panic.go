@@ -0,0 +1,103 @@
+package corefx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+)
+
+// defaultPanicHandler panic handler used by the package level helpers, which log using slog.Default.
+var defaultPanicHandler = &PanicHandler{}
+
+// PanicHandler log recovered panics with their stack traces.
+// When sentry is enabled, the panic is also reported to sentry through the logger.
+type PanicHandler struct {
+	logger *slog.Logger
+}
+
+// NewPanicHandler create a panic handler that log using the specified logger.
+func NewPanicHandler(logger *slog.Logger) *PanicHandler {
+	return &PanicHandler{logger: logger}
+}
+
+// Handle log the recovered value of a panic.
+func (h *PanicHandler) Handle(ctx context.Context, recovered any) {
+	logger := h.logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	err, ok := recovered.(error)
+	if !ok {
+		err = fmt.Errorf("%v", recovered)
+	}
+	logger.ErrorContext(ctx, "Recovered from panic",
+		slog.Any("err", err),
+		slog.String("stack", string(debug.Stack())))
+}
+
+// Recover recover from panic and handle it, must be deferred directly.
+// For example, defer handler.Recover(ctx).
+func (h *PanicHandler) Recover(ctx context.Context) {
+	if r := recover(); r != nil {
+		h.Handle(ctx, r)
+	}
+}
+
+// Go run f in a new goroutine, recovering from its panic.
+func (h *PanicHandler) Go(f func()) {
+	go func() {
+		defer h.Recover(context.Background())
+		f()
+	}()
+}
+
+// Middleware recover from panics of the HTTP handler, responding with internal server error.
+func (h *PanicHandler) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+			// Let the server abort the response, see http.ErrAbortHandler.
+			if err, ok := recovered.(error); ok && errors.Is(err, http.ErrAbortHandler) {
+				panic(recovered)
+			}
+			h.Handle(r.Context(), recovered)
+			w.WriteHeader(http.StatusInternalServerError)
+		}()
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Recover recover from panic and log it using slog.Default, must be deferred directly.
+// For example, defer corefx.Recover(ctx).
+func Recover(ctx context.Context) {
+	if r := recover(); r != nil {
+		defaultPanicHandler.Handle(ctx, r)
+	}
+}
+
+// Go run f in a new goroutine, recovering from its panic and logging it using slog.Default.
+func Go(f func()) {
+	defaultPanicHandler.Go(f)
+}
+
+// runRecovered run f, recovering from its panic, which is logged using slog.Default and returned as an error.
+func runRecovered(ctx context.Context, f func(ctx context.Context) error) (err error) {
+	defer func() {
+		if recovered := recover(); recovered != nil {
+			defaultPanicHandler.Handle(ctx, recovered)
+			err = fmt.Errorf("error recovered from panic: %v", recovered)
+		}
+	}()
+	return f(ctx)
+}
+
+// RecoverMiddleware recover from panics of the HTTP handler and log them using slog.Default.
+func RecoverMiddleware(next http.Handler) http.Handler {
+	return defaultPanicHandler.Middleware(next)
+}
@@ -0,0 +1,67 @@
+package corefx
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"strings"
+)
+
+// expandPlaceholders replace ${ENV_VAR} and ${ENV_VAR:default} placeholders in every string value of cfg, including
+// values of free-form sections like map[string]any, with the value of the env variable, or the default value if the env
+// variable is not set.
+// Use $${ to write a literal ${.
+// Return an error joining every placeholder whose env variable is not set and has no default value.
+func expandPlaceholders(cfg any) error {
+	var errs []error
+	expand := func(fieldPath string, value string) (string, bool) {
+		if !strings.Contains(value, "${") {
+			return "", false
+		}
+		expanded, missing := expandPlaceholdersOf(value)
+		for _, name := range missing {
+			errs = append(errs, fmt.Errorf("[%s] env variable [%s] is not set, consider setting it or using ${%s:default}",
+				fieldPath, name, name))
+		}
+		return expanded, true
+	}
+	walkStrings(reflect.ValueOf(cfg), "", expand)
+	return errors.Join(errs...)
+}
+
+// expandPlaceholdersOf expand placeholders of s, return the expanded string and the names of missing env variables.
+func expandPlaceholdersOf(s string) (string, []string) {
+	var b strings.Builder
+	var missing []string
+	for {
+		i := strings.Index(s, "${")
+		if i < 0 {
+			b.WriteString(s)
+			return b.String(), missing
+		}
+		// Escaped placeholder.
+		if i > 0 && s[i-1] == '$' {
+			b.WriteString(s[:i-1])
+			b.WriteString("${")
+			s = s[i+2:]
+			continue
+		}
+		end := strings.IndexByte(s[i:], '}')
+		if end < 0 {
+			b.WriteString(s)
+			return b.String(), missing
+		}
+		b.WriteString(s[:i])
+		name, defaultValue, hasDefault := strings.Cut(s[i+2:i+end], ":")
+		switch value, ok := os.LookupEnv(name); {
+		case ok:
+			b.WriteString(value)
+		case hasDefault:
+			b.WriteString(defaultValue)
+		default:
+			missing = append(missing, name)
+		}
+		s = s[i+end+1:]
+	}
+}
@@ -0,0 +1,77 @@
+package corefx
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExpandPlaceholdersOf(t *testing.T) {
+	t.Setenv("COREFX_TEST_HOST", "db.local")
+	tests := []struct {
+		value   string
+		want    string
+		missing []string
+	}{
+		{value: "plain", want: "plain"},
+		{value: "postgres://${COREFX_TEST_HOST}/db", want: "postgres://db.local/db"},
+		{value: "${COREFX_TEST_UNSET:5432}", want: "5432"},
+		{value: "${COREFX_TEST_UNSET:}", want: ""},
+		{value: "$${COREFX_TEST_HOST}", want: "${COREFX_TEST_HOST}"},
+		{value: "${COREFX_TEST_UNSET}", want: "", missing: []string{"COREFX_TEST_UNSET"}},
+		{value: "${COREFX_TEST_HOST", want: "${COREFX_TEST_HOST"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.value, func(t *testing.T) {
+			got, missing := expandPlaceholdersOf(tt.value)
+			if got != tt.want || !reflect.DeepEqual(missing, tt.missing) {
+				t.Errorf("expandPlaceholdersOf(%q) = %q, %v, want %q, %v", tt.value, got, missing, tt.want, tt.missing)
+			}
+		})
+	}
+}
+
+func TestExpandPlaceholdersFreeFormSections(t *testing.T) {
+	t.Setenv("COREFX_TEST_HOST", "db.local")
+	host := "${COREFX_TEST_HOST}"
+	cfg := &struct {
+		URL     string
+		Options map[string]any
+		Hosts   []any
+		Any     any
+		Pointer any
+	}{
+		URL: "${COREFX_TEST_HOST}",
+		Options: map[string]any{
+			"host":   "${COREFX_TEST_HOST}",
+			"nested": map[string]any{"hosts": []any{"${COREFX_TEST_HOST}", 1}},
+		},
+		Hosts:   []any{"${COREFX_TEST_HOST}"},
+		Any:     "${COREFX_TEST_HOST}",
+		Pointer: &host,
+	}
+	if err := expandPlaceholders(cfg); err != nil {
+		t.Fatalf("expandPlaceholders() error = %v", err)
+	}
+
+	if cfg.URL != "db.local" || cfg.Options["host"] != "db.local" || cfg.Hosts[0] != "db.local" ||
+		cfg.Any != "db.local" || host != "db.local" {
+		t.Errorf("expandPlaceholders() = %+v, host %q, want every placeholder expanded", cfg, host)
+	}
+	nested := cfg.Options["nested"].(map[string]any)["hosts"].([]any)
+	if !reflect.DeepEqual(nested, []any{"db.local", 1}) {
+		t.Errorf("nested hosts = %v, want [db.local 1]", nested)
+	}
+}
+
+func TestExpandPlaceholdersMissing(t *testing.T) {
+	cfg := &struct {
+		Options map[string]any
+	}{
+		Options: map[string]any{"password": "${COREFX_TEST_UNSET}"},
+	}
+	err := expandPlaceholders(cfg)
+	if err == nil || !strings.Contains(err.Error(), "[Options[password]]") {
+		t.Errorf("expandPlaceholders() error = %v, want error of Options[password]", err)
+	}
+}
@@ -0,0 +1,201 @@
+package corefx
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// RateLimitModeGlobal limit all requests together.
+	RateLimitModeGlobal = "global"
+	// RateLimitModeIP limit requests of each client ip separately.
+	RateLimitModeIP = "ip"
+)
+
+// rateLimitSweepInterval min duration between removals of the idle client buckets.
+const rateLimitSweepInterval = time.Minute
+
+// RateLimitConfig optional interface that a HTTPServerConfig can implement to rate limit requests of the built-in
+// server, see RateLimiter.
+type RateLimitConfig interface {
+	RateLimitValue() RateLimitOptions
+}
+
+// RateLimitOptions the rate_limit config section.
+type RateLimitOptions struct {
+	// Rate requests per second allowed, zero disable rate limiting.
+	Rate float64 `json:"rate" mapstructure:"rate"`
+	// Burst max number of requests allowed at once, default to the rate rounded up.
+	Burst int `json:"burst" mapstructure:"burst"`
+	// Mode either "global" to limit all requests together, or "ip" to limit each client ip separately.
+	Mode string `json:"mode" mapstructure:"mode" default:"global"`
+	// ClientIPHeader header carrying the client ip set by a trusted proxy, for example "X-Forwarded-For", whose last
+	// value is used. Empty use the remote address of the connection.
+	ClientIPHeader string `json:"client_ip_header" mapstructure:"client_ip_header"`
+	// ExcludePaths paths of requests that are not limited, such as health endpoints.
+	// A path ending with "*" exclude all paths starting with it.
+	ExcludePaths []string `json:"exclude_paths" mapstructure:"exclude_paths"`
+}
+
+type RateLimitEnv struct {
+	RateLimit RateLimitOptions `json:"rate_limit" mapstructure:"rate_limit"`
+}
+
+func (e RateLimitEnv) RateLimitValue() RateLimitOptions {
+	return e.RateLimit
+}
+
+var _ RateLimitConfig = (*RateLimitEnv)(nil)
+
+// RateLimiter limit requests using token buckets, either globally or per client ip.
+// Requests exceeding the limit are responded with 429 Too Many Requests and a Retry-After header.
+type RateLimiter struct {
+	mu        sync.Mutex
+	options   RateLimitOptions
+	excluded  func(path string) bool
+	global    *tokenBucket
+	clients   map[string]*tokenBucket
+	sweptAt   time.Time
+	burst     float64
+	unlimited bool
+}
+
+// NewRateLimiter create a RateLimiter using options.
+func NewRateLimiter(options RateLimitOptions) (*RateLimiter, error) {
+	l := &RateLimiter{}
+	if err := l.Update(options); err != nil {
+		return nil, err
+	}
+	return l, nil
+}
+
+// Update replace the options of the limiter, resetting its buckets if they changed.
+func (l *RateLimiter) Update(options RateLimitOptions) error {
+	if options.Mode == "" {
+		options.Mode = RateLimitModeGlobal
+	}
+	if options.Mode != RateLimitModeGlobal && options.Mode != RateLimitModeIP {
+		return fmt.Errorf("error invalid rate limit mode [%s]", options.Mode)
+	}
+	if options.Rate < 0 || options.Burst < 0 {
+		return fmt.Errorf("error invalid rate limit [%v] burst [%d]", options.Rate, options.Burst)
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.excluded != nil && reflect.DeepEqual(l.options, options) {
+		return nil
+	}
+	l.options = options
+	l.excluded = pathMatcher(options.ExcludePaths)
+	l.unlimited = options.Rate == 0
+	l.burst = float64(options.Burst)
+	if options.Burst == 0 {
+		l.burst = math.Ceil(options.Rate)
+	}
+	l.global = newTokenBucket(l.burst, time.Now())
+	l.clients = map[string]*tokenBucket{}
+	return nil
+}
+
+// Allow take a token of the bucket of key, which is ignored in global mode.
+// Return whether the request is allowed, otherwise how long until a token is available.
+func (l *RateLimiter) Allow(key string) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.unlimited {
+		return true, 0
+	}
+	now := time.Now()
+	bucket := l.global
+	if l.options.Mode == RateLimitModeIP {
+		if now.Sub(l.sweptAt) >= rateLimitSweepInterval {
+			l.sweep(now)
+		}
+		bucket = l.clients[key]
+		if bucket == nil {
+			bucket = newTokenBucket(l.burst, now)
+			l.clients[key] = bucket
+		}
+	}
+	return bucket.take(now, l.options.Rate, l.burst)
+}
+
+// sweep remove the client buckets that are full, which behave the same as new buckets. Must be called with mu held.
+func (l *RateLimiter) sweep(now time.Time) {
+	l.sweptAt = now
+	for key, bucket := range l.clients {
+		bucket.refill(now, l.options.Rate, l.burst)
+		if bucket.tokens >= l.burst {
+			delete(l.clients, key)
+		}
+	}
+}
+
+// Middleware limit requests of next.
+func (l *RateLimiter) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		l.mu.Lock()
+		excluded, header := l.excluded(r.URL.Path), l.options.ClientIPHeader
+		l.mu.Unlock()
+		if excluded {
+			next.ServeHTTP(w, r)
+			return
+		}
+		allowed, wait := l.Allow(clientIPOf(r, header))
+		if !allowed {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+			http.Error(w, http.StatusText(http.StatusTooManyRequests), http.StatusTooManyRequests)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// clientIPOf return the last value of the header if set, otherwise the remote ip of r.
+func clientIPOf(r *http.Request, header string) string {
+	if header != "" {
+		values := strings.Split(r.Header.Get(header), ",")
+		if ip := strings.TrimSpace(values[len(values)-1]); ip != "" {
+			return ip
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// tokenBucket a bucket refilled at a fixed rate, up to its burst.
+type tokenBucket struct {
+	tokens float64
+	at     time.Time
+}
+
+func newTokenBucket(burst float64, now time.Time) *tokenBucket {
+	return &tokenBucket{tokens: burst, at: now}
+}
+
+func (b *tokenBucket) refill(now time.Time, rate float64, burst float64) {
+	if elapsed := now.Sub(b.at).Seconds(); elapsed > 0 {
+		b.tokens = math.Min(burst, b.tokens+elapsed*rate)
+		b.at = now
+	}
+}
+
+// take a token, or return how long until a token is available.
+func (b *tokenBucket) take(now time.Time, rate float64, burst float64) (bool, time.Duration) {
+	b.refill(now, rate, burst)
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) / rate * float64(time.Second))
+}
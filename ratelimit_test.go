@@ -0,0 +1,189 @@
+package corefx
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTokenBucketTake(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	b := newTokenBucket(2, start)
+	tests := []struct {
+		at      time.Duration
+		allowed bool
+		wait    time.Duration
+	}{
+		{at: 0, allowed: true},
+		{at: 0, allowed: true},
+		{at: 0, allowed: false, wait: 500 * time.Millisecond},
+		{at: 250 * time.Millisecond, allowed: false, wait: 250 * time.Millisecond},
+		{at: 500 * time.Millisecond, allowed: true},
+		{at: 500 * time.Millisecond, allowed: false, wait: 500 * time.Millisecond},
+		// Tokens are refilled up to the burst.
+		{at: time.Minute, allowed: true},
+		{at: time.Minute, allowed: true},
+		{at: time.Minute, allowed: false, wait: 500 * time.Millisecond},
+	}
+	for i, tt := range tests {
+		allowed, wait := b.take(start.Add(tt.at), 2, 2)
+		if allowed != tt.allowed || wait != tt.wait {
+			t.Errorf("take #%d at %s = %v, %s, want %v, %s", i, tt.at, allowed, wait, tt.allowed, tt.wait)
+		}
+	}
+}
+
+func TestRateLimiterUpdate(t *testing.T) {
+	tests := []struct {
+		name    string
+		options RateLimitOptions
+		burst   float64
+		wantErr bool
+	}{
+		{name: "default burst", options: RateLimitOptions{Rate: 2.5}, burst: 3},
+		{name: "burst", options: RateLimitOptions{Rate: 2.5, Burst: 10, Mode: RateLimitModeIP}, burst: 10},
+		{name: "unlimited", options: RateLimitOptions{}, burst: 0},
+		{name: "invalid mode", options: RateLimitOptions{Rate: 1, Mode: "user"}, wantErr: true},
+		{name: "negative rate", options: RateLimitOptions{Rate: -1}, wantErr: true},
+		{name: "negative burst", options: RateLimitOptions{Rate: 1, Burst: -1}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := NewRateLimiter(tt.options)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("NewRateLimiter() error = %v, want error %v", err, tt.wantErr)
+			}
+			if err == nil && l.burst != tt.burst {
+				t.Errorf("burst = %v, want %v", l.burst, tt.burst)
+			}
+		})
+	}
+}
+
+func TestRateLimiterUpdateKeepBuckets(t *testing.T) {
+	l, err := NewRateLimiter(RateLimitOptions{Rate: 1, ExcludePaths: []string{"/readyz"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Allow("")
+	// Reloading the same options must not refill the buckets.
+	if err := l.Update(RateLimitOptions{Rate: 1, ExcludePaths: []string{"/readyz"}}); err != nil {
+		t.Fatal(err)
+	}
+	if allowed, _ := l.Allow(""); allowed {
+		t.Errorf("Allow() = true after updating the same options, want the bucket kept")
+	}
+	if err := l.Update(RateLimitOptions{Rate: 2}); err != nil {
+		t.Fatal(err)
+	}
+	if allowed, _ := l.Allow(""); !allowed {
+		t.Errorf("Allow() = false after changing the options, want a new bucket")
+	}
+}
+
+func TestRateLimiterSweep(t *testing.T) {
+	l, err := NewRateLimiter(RateLimitOptions{Rate: 1, Burst: 2, Mode: RateLimitModeIP})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Allow("a")
+	l.Allow("b")
+	l.Allow("b")
+	if len(l.clients) != 2 {
+		t.Fatalf("clients = %d, want 2", len(l.clients))
+	}
+	// a is refilled after 1s, b after 2s.
+	l.sweep(time.Now().Add(1500 * time.Millisecond))
+	if _, ok := l.clients["a"]; ok || l.clients["b"] == nil {
+		t.Errorf("clients = %v, want only b kept", l.clients)
+	}
+	l.sweep(time.Now().Add(3 * time.Second))
+	if len(l.clients) != 0 {
+		t.Errorf("clients = %v, want all removed", l.clients)
+	}
+}
+
+func TestRateLimiterMiddleware(t *testing.T) {
+	tests := []struct {
+		name       string
+		options    RateLimitOptions
+		requests   []*http.Request
+		want       []int
+		retryAfter string
+	}{
+		{
+			name:    "global",
+			options: RateLimitOptions{Rate: 0.5, Burst: 1},
+			requests: []*http.Request{
+				httptest.NewRequest(http.MethodGet, "/a", nil),
+				httptest.NewRequest(http.MethodGet, "/b", nil),
+			},
+			want:       []int{http.StatusOK, http.StatusTooManyRequests},
+			retryAfter: "2",
+		},
+		{
+			name:    "retry after rounded up",
+			options: RateLimitOptions{Rate: 3, Burst: 1},
+			requests: []*http.Request{
+				httptest.NewRequest(http.MethodGet, "/a", nil),
+				httptest.NewRequest(http.MethodGet, "/a", nil),
+			},
+			want:       []int{http.StatusOK, http.StatusTooManyRequests},
+			retryAfter: "1",
+		},
+		{
+			name:    "excluded paths",
+			options: RateLimitOptions{Rate: 1, ExcludePaths: []string{"/readyz", "/internal/*"}},
+			requests: []*http.Request{
+				httptest.NewRequest(http.MethodGet, "/a", nil),
+				httptest.NewRequest(http.MethodGet, "/readyz", nil),
+				httptest.NewRequest(http.MethodGet, "/internal/metrics", nil),
+				httptest.NewRequest(http.MethodGet, "/readyz/x", nil),
+			},
+			want:       []int{http.StatusOK, http.StatusOK, http.StatusOK, http.StatusTooManyRequests},
+			retryAfter: "1",
+		},
+		{
+			name:    "ip",
+			options: RateLimitOptions{Rate: 1, Mode: RateLimitModeIP, ClientIPHeader: "X-Forwarded-For"},
+			requests: []*http.Request{
+				requestFrom("10.0.0.1:1000", "192.168.0.9, 192.168.0.1"),
+				requestFrom("10.0.0.1:1000", "192.168.0.9, 192.168.0.2"),
+				requestFrom("10.0.0.2:1000", ""),
+				requestFrom("10.0.0.2:2000", ""),
+			},
+			want:       []int{http.StatusOK, http.StatusOK, http.StatusOK, http.StatusTooManyRequests},
+			retryAfter: "1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l, err := NewRateLimiter(tt.options)
+			if err != nil {
+				t.Fatal(err)
+			}
+			handler := l.Middleware(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {}))
+			var last *httptest.ResponseRecorder
+			for i, r := range tt.requests {
+				last = httptest.NewRecorder()
+				handler.ServeHTTP(last, r)
+				if last.Code != tt.want[i] {
+					t.Errorf("request #%d %s status = %d, want %d", i, r.URL.Path, last.Code, tt.want[i])
+				}
+			}
+			if got := last.Header().Get("Retry-After"); got != tt.retryAfter {
+				t.Errorf("Retry-After = %q, want %q", got, tt.retryAfter)
+			}
+		})
+	}
+}
+
+func requestFrom(remoteAddr string, forwardedFor string) *http.Request {
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.RemoteAddr = remoteAddr
+	if forwardedFor != "" {
+		r.Header.Set("X-Forwarded-For", forwardedFor)
+	}
+	return r
+}
@@ -0,0 +1,185 @@
+package corefx
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+var (
+	emailPattern      = regexp.MustCompile(`[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}`)
+	creditCardPattern = regexp.MustCompile(`\b\d(?:[ -]?\d){12,18}\b`)
+)
+
+// LogRedactConfig optional interface that a CoreConfig can implement to redact sensitive values of log records before
+// they are written into stdout, files, sentry and other handlers.
+type LogRedactConfig interface {
+	LogRedactValue() LogRedactOptions
+}
+
+// LogRedactOptions the log_redact config section.
+type LogRedactOptions struct {
+	// Keys attribute keys whose values are redacted, matched case-insensitively against any part of the key,
+	// so "token" also redact "access_token".
+	Keys []string `json:"keys" mapstructure:"keys" default:"password,secret,token,authorization,cookie"`
+	// Emails redact email addresses in messages and string values.
+	Emails bool `json:"emails" mapstructure:"emails"`
+	// CreditCards redact credit card numbers in messages and string values.
+	CreditCards bool `json:"credit_cards" mapstructure:"credit_cards"`
+	// Patterns regular expressions of other values to redact in messages and string values.
+	Patterns []string `json:"patterns" mapstructure:"patterns"`
+}
+
+type LogRedactEnv struct {
+	LogRedact LogRedactOptions `json:"log_redact" mapstructure:"log_redact"`
+}
+
+func (e LogRedactEnv) LogRedactValue() LogRedactOptions {
+	return e.LogRedact
+}
+
+var _ LogRedactConfig = (*LogRedactEnv)(nil)
+
+// NewRedactMiddleware create a LogMiddleware that replace sensitive values of records by SecretMask.
+// Values of groups and LogValuer are redacted as well, errors and other values are redacted using their text if it
+// contains a sensitive value.
+func NewRedactMiddleware(options LogRedactOptions) (LogMiddleware, error) {
+	r := &logRedactor{}
+	for _, key := range options.Keys {
+		if key != "" {
+			r.keys = append(r.keys, strings.ToLower(key))
+		}
+	}
+	if options.Emails {
+		r.patterns = append(r.patterns, emailPattern)
+	}
+	for _, pattern := range options.Patterns {
+		compiled, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("error invalid log redact pattern [%s]: %w", pattern, err)
+		}
+		r.patterns = append(r.patterns, compiled)
+	}
+	r.creditCards = options.CreditCards
+	return func(next slog.Handler) slog.Handler {
+		return &redactHandler{next: next, redactor: r}
+	}, nil
+}
+
+type logRedactor struct {
+	keys        []string
+	patterns    []*regexp.Regexp
+	creditCards bool
+}
+
+// isSensitiveKey whether values of key must be redacted.
+func (r *logRedactor) isSensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, sensitive := range r.keys {
+		if strings.Contains(key, sensitive) {
+			return true
+		}
+	}
+	return false
+}
+
+// redactString replace sensitive values of s.
+func (r *logRedactor) redactString(s string) string {
+	for _, pattern := range r.patterns {
+		s = pattern.ReplaceAllString(s, SecretMask)
+	}
+	if r.creditCards {
+		s = creditCardPattern.ReplaceAllStringFunc(s, func(match string) string {
+			if isLuhnValid(match) {
+				return SecretMask
+			}
+			return match
+		})
+	}
+	return s
+}
+
+func (r *logRedactor) redactAttr(attr slog.Attr) slog.Attr {
+	if r.isSensitiveKey(attr.Key) {
+		return slog.String(attr.Key, SecretMask)
+	}
+	value := attr.Value.Resolve()
+	switch value.Kind() {
+	case slog.KindString:
+		return slog.String(attr.Key, r.redactString(value.String()))
+	case slog.KindGroup:
+		group := value.Group()
+		attrs := make([]slog.Attr, 0, len(group))
+		for _, a := range group {
+			attrs = append(attrs, r.redactAttr(a))
+		}
+		return slog.Attr{Key: attr.Key, Value: slog.GroupValue(attrs...)}
+	case slog.KindAny:
+		var text string
+		switch v := value.Any().(type) {
+		case error:
+			text = v.Error()
+		case fmt.Stringer:
+			text = v.String()
+		default:
+			return slog.Attr{Key: attr.Key, Value: value}
+		}
+		if redacted := r.redactString(text); redacted != text {
+			return slog.String(attr.Key, redacted)
+		}
+	}
+	return slog.Attr{Key: attr.Key, Value: value}
+}
+
+// redactHandler slog.Handler that redact records before passing them to the next handler.
+type redactHandler struct {
+	next     slog.Handler
+	redactor *logRedactor
+}
+
+func (h *redactHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *redactHandler) Handle(ctx context.Context, r slog.Record) error {
+	redacted := slog.NewRecord(r.Time, r.Level, h.redactor.redactString(r.Message), r.PC)
+	r.Attrs(func(attr slog.Attr) bool {
+		redacted.AddAttrs(h.redactor.redactAttr(attr))
+		return true
+	})
+	return h.next.Handle(ctx, redacted)
+}
+
+func (h *redactHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	redacted := make([]slog.Attr, 0, len(attrs))
+	for _, attr := range attrs {
+		redacted = append(redacted, h.redactor.redactAttr(attr))
+	}
+	return &redactHandler{next: h.next.WithAttrs(redacted), redactor: h.redactor}
+}
+
+func (h *redactHandler) WithGroup(name string) slog.Handler {
+	return &redactHandler{next: h.next.WithGroup(name), redactor: h.redactor}
+}
+
+// isLuhnValid whether the digits of s pass the Luhn checksum of card numbers.
+func isLuhnValid(s string) bool {
+	sum, double := 0, false
+	for i := len(s) - 1; i >= 0; i-- {
+		if s[i] < '0' || s[i] > '9' {
+			continue
+		}
+		digit := int(s[i] - '0')
+		if double {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+		double = !double
+	}
+	return sum%10 == 0
+}
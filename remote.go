@@ -0,0 +1,283 @@
+package corefx
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// remoteConfigPollInterval interval between polls when watching remote config that does not support blocking query.
+	remoteConfigPollInterval = 10 * time.Second
+	// remoteConfigWait max duration of a blocking query when watching remote config.
+	remoteConfigWait = 5 * time.Minute
+	// remoteConfigLoadTimeout max duration of fetching remote config when loading it, including the retries of http(s)
+	// locations, so an unreachable remote fail the startup instead of blocking it.
+	remoteConfigLoadTimeout = 30 * time.Second
+)
+
+// remoteConfigClient client used to fetch remote config, blocking queries are bounded by remoteConfigWait.
+var remoteConfigClient = &http.Client{Timeout: remoteConfigWait + 30*time.Second}
+
+const (
+	defaultHTTPConfigTimeout = 10 * time.Second
+	defaultHTTPConfigRetries = 3
+)
+
+// HTTPConfigLocationConfig optional interface that a CoreConfig can implement to configure how config is fetched from
+// http(s) locations.
+// These methods are called before the config is loaded, so they only see the default values of the config.
+type HTTPConfigLocationConfig interface {
+	// AppConfigHTTPTimeoutValue timeout of each request, default to 10s if zero.
+	AppConfigHTTPTimeoutValue() time.Duration
+	// AppConfigHTTPTokenValue bearer token sent with each request, empty to disable.
+	AppConfigHTTPTokenValue() string
+	// AppConfigHTTPRetriesValue number of retries on network and server errors, default to 3 if zero.
+	// Return negative value to disable retrying.
+	AppConfigHTTPRetriesValue() int
+}
+
+// isRemoteLocation whether the config location is a remote location.
+func isRemoteLocation(location string) bool {
+	for _, prefix := range []string{"consul://", "consul+https://", "etcd://", "etcd+https://", "http://", "https://"} {
+		if strings.HasPrefix(location, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// remoteConfigTypeOf return the viper config type of remote location, based on the key extension.
+func remoteConfigTypeOf(location string) string {
+	u, err := url.Parse(location)
+	if err != nil {
+		return configTypeOf(location)
+	}
+	return configTypeOf(u.Path)
+}
+
+// fetchRemoteConfig fetch config content from a remote location.
+// Supported locations:
+//   - consul://host:8500/path/to/key.json read key from Consul KV, using CONSUL_HTTP_TOKEN env as token.
+//     Use consul+https:// or set CONSUL_HTTP_SSL env to true to connect using https.
+//   - etcd://host:2379/path/to/key.json read key from etcd v3 using its JSON gateway.
+//     Use etcd+https:// to connect using https.
+//   - http(s)://host/path/to/app.json read config using GET request, configured by HTTPConfigLocationConfig of cfg.
+//
+// Return nil data if the key does not exist.
+// The returned version changes when the remote config changes. If version is specified and the remote support
+// blocking queries, this method block until the config changes or remoteConfigWait elapsed.
+func fetchRemoteConfig(ctx context.Context, cfg any, location string, version string) ([]byte, string, error) {
+	u, err := url.Parse(location)
+	if err != nil {
+		return nil, "", err
+	}
+	switch u.Scheme {
+	case "consul", "consul+https":
+		return fetchConsulConfig(ctx, u, version)
+	case "etcd", "etcd+https":
+		return fetchEtcdConfig(ctx, u)
+	case "http", "https":
+		return fetchHTTPConfig(ctx, cfg, u)
+	default:
+		return nil, "", fmt.Errorf("error unsupported remote config location [%s]", location)
+	}
+}
+
+// isBlockingRemoteLocation whether the remote location support blocking queries.
+func isBlockingRemoteLocation(location string) bool {
+	return strings.HasPrefix(location, "consul://") || strings.HasPrefix(location, "consul+https://")
+}
+
+// remoteBaseURLOf return the base url of the API of a consul or etcd location, using https for the +https schemes.
+func remoteBaseURLOf(u *url.URL, https bool) string {
+	if https || strings.HasSuffix(u.Scheme, "+https") {
+		return "https://" + u.Host
+	}
+	return "http://" + u.Host
+}
+
+func fetchConsulConfig(ctx context.Context, u *url.URL, version string) ([]byte, string, error) {
+	query := url.Values{"raw": {""}}
+	if version != "" {
+		query.Set("index", version)
+		query.Set("wait", remoteConfigWait.String())
+	}
+	ssl, _ := strconv.ParseBool(os.Getenv("CONSUL_HTTP_SSL"))
+	endpoint := remoteBaseURLOf(u, ssl) + "/v1/kv/" + strings.TrimPrefix(u.Path, "/") + "?" + query.Encode()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, "", err
+	}
+	if token := os.Getenv("CONSUL_HTTP_TOKEN"); token != "" {
+		req.Header.Set("X-Consul-Token", token)
+	}
+
+	res, err := remoteConfigClient.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer res.Body.Close()
+	index := res.Header.Get("X-Consul-Index")
+	if res.StatusCode == http.StatusNotFound {
+		return nil, index, nil
+	}
+	if res.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("error reading consul key [%s]: status %d", u.Path, res.StatusCode)
+	}
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, "", err
+	}
+	return data, index, nil
+}
+
+func fetchEtcdConfig(ctx context.Context, u *url.URL) ([]byte, string, error) {
+	body, err := json.Marshal(map[string]string{
+		"key": base64.StdEncoding.EncodeToString([]byte(u.Path)),
+	})
+	if err != nil {
+		return nil, "", err
+	}
+	endpoint := remoteBaseURLOf(u, false) + "/v3/kv/range"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(string(body)))
+	if err != nil {
+		return nil, "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	res, err := remoteConfigClient.Do(req)
+	if err != nil {
+		return nil, "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("error reading etcd key [%s]: status %d", u.Path, res.StatusCode)
+	}
+
+	var result struct {
+		Kvs []struct {
+			Value       string `json:"value"`
+			ModRevision string `json:"mod_revision"`
+		} `json:"kvs"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return nil, "", err
+	}
+	if len(result.Kvs) == 0 {
+		return nil, "", nil
+	}
+	data, err := base64.StdEncoding.DecodeString(result.Kvs[0].Value)
+	if err != nil {
+		return nil, "", err
+	}
+	return data, result.Kvs[0].ModRevision, nil
+}
+
+func fetchHTTPConfig(ctx context.Context, cfg any, u *url.URL) ([]byte, string, error) {
+	timeout, token, retries := defaultHTTPConfigTimeout, "", defaultHTTPConfigRetries
+	if c, ok := cfg.(HTTPConfigLocationConfig); ok {
+		if c.AppConfigHTTPTimeoutValue() > 0 {
+			timeout = c.AppConfigHTTPTimeoutValue()
+		}
+		if c.AppConfigHTTPRetriesValue() != 0 {
+			retries = max(c.AppConfigHTTPRetriesValue(), 0)
+		}
+		token = c.AppConfigHTTPTokenValue()
+	}
+
+	var lastErr error
+	for attempt := 0; attempt <= retries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, "", ctx.Err()
+			case <-time.After(time.Duration(attempt) * time.Second):
+			}
+		}
+		data, retryable, err := doFetchHTTPConfig(ctx, u, timeout, token)
+		if err == nil {
+			if data == nil {
+				return nil, "", nil
+			}
+			sum := sha256.Sum256(data)
+			return data, hex.EncodeToString(sum[:]), nil
+		}
+		if !retryable {
+			return nil, "", err
+		}
+		lastErr = err
+	}
+	return nil, "", lastErr
+}
+
+// doFetchHTTPConfig send a single request to fetch config, return whether the error is retryable.
+func doFetchHTTPConfig(ctx context.Context, u *url.URL, timeout time.Duration, token string) ([]byte, bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, false, err
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	res, err := remoteConfigClient.Do(req)
+	if err != nil {
+		return nil, true, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode == http.StatusNotFound {
+		return nil, false, nil
+	}
+	if res.StatusCode != http.StatusOK {
+		retryable := res.StatusCode >= http.StatusInternalServerError || res.StatusCode == http.StatusTooManyRequests
+		return nil, retryable, fmt.Errorf("error reading config [%s]: status %d", u.Redacted(), res.StatusCode)
+	}
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, true, err
+	}
+	return data, false, nil
+}
+
+// watchRemoteConfig watch a remote location and call onChange when the config changes, until ctx is done.
+func watchRemoteConfig(ctx context.Context, logger *slog.Logger, cfg any, location string, onChange func()) {
+	version, fetched := "", false
+	for {
+		_, next, err := fetchRemoteConfig(ctx, cfg, location, version)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			logger.Warn("Error watching remote config", slog.String("location", location), slog.Any("err", err))
+		} else {
+			if fetched && next != version {
+				onChange()
+			}
+			version, fetched = next, true
+		}
+
+		// Blocking queries wait for changes by themselves, unless the version is unknown.
+		if err == nil && isBlockingRemoteLocation(location) && version != "" && version != "0" {
+			continue
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(remoteConfigPollInterval):
+		}
+	}
+}
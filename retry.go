@@ -0,0 +1,160 @@
+package corefx
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"log/slog"
+	"math"
+	"math/rand/v2"
+	"time"
+)
+
+// retryStats expvar counters of Retry calls with a named policy, served on /debug/vars of the debug server.
+// Keys are <policy>.calls, <policy>.retries and <policy>.failures (calls that failed after all attempts).
+var retryStats = expvar.NewMap("corefx_retry")
+
+// RetryPolicy how Retry call a function. The zero value retry 3 attempts, with an exponential backoff doubling from
+// 100ms up to 30s, without jitter, see DefaultRetryPolicy.
+type RetryPolicy struct {
+	// Name identify the policy in logs and the corefx_retry expvar, empty to not publish stats.
+	Name string `json:"-" mapstructure:"-"`
+	// MaxAttempts max number of calls, including the first one.
+	// Zero means 3 attempts, or unlimited attempts if MaxElapsed is set.
+	MaxAttempts int `json:"max_attempts" mapstructure:"max_attempts" default:"3"`
+	// MaxElapsed max duration since the first call after which no retry is made, zero means no limit.
+	MaxElapsed time.Duration `json:"max_elapsed" mapstructure:"max_elapsed"`
+	// InitialBackoff delay before the first retry, zero means 100ms.
+	InitialBackoff time.Duration `json:"initial_backoff" mapstructure:"initial_backoff" default:"100ms"`
+	// MaxBackoff max delay between retries, zero means 30s.
+	MaxBackoff time.Duration `json:"max_backoff" mapstructure:"max_backoff" default:"30s"`
+	// Multiplier factor applied to the backoff after each retry, less than 1 means 2.
+	Multiplier float64 `json:"multiplier" mapstructure:"multiplier" default:"2"`
+	// Jitter fraction of the backoff that is randomized, between 0 and 1, so clients do not retry in lockstep.
+	// For example, 0.5 wait between half and the full backoff.
+	Jitter float64 `json:"jitter" mapstructure:"jitter" default:"0.5"`
+	// Retryable whether an error should be retried, nil retry all errors.
+	// Errors marked using Permanent and errors after ctx is done are never retried.
+	Retryable func(err error) bool `json:"-" mapstructure:"-"`
+	// Clock measure the elapsed time and wait for the backoff, nil means the real clock.
+	Clock Clock `json:"-" mapstructure:"-"`
+}
+
+// DefaultRetryPolicy return a policy of 3 attempts, with an exponential backoff doubling from 100ms up to 30s,
+// half of which is jittered.
+func DefaultRetryPolicy() RetryPolicy {
+	return RetryPolicy{MaxAttempts: 3, InitialBackoff: 100 * time.Millisecond, MaxBackoff: 30 * time.Second,
+		Multiplier: 2, Jitter: 0.5}
+}
+
+// permanentError an error that must not be retried.
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// Permanent mark err as not retryable. Retry return err immediately, without the mark, even if the error returned by
+// the function wrap the marked error, for example fmt.Errorf("error charging: %w", corefx.Permanent(err)).
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
+
+// Retry call f until it succeed, return a non retryable error, or the policy give up, returning the last error.
+// Retries wait for an exponential backoff with jitter, and stop early if ctx is done or its deadline would be
+// exceeded by the wait. Retries are logged at debug level and giving up at warn level, using the logger of ctx.
+func Retry(ctx context.Context, policy RetryPolicy, f func(ctx context.Context) error) error {
+	policy = policy.normalized()
+	if policy.Name != "" {
+		retryStats.Add(policy.Name+".calls", 1)
+	}
+	logger := FromContext(ctx)
+	start := policy.Clock.Now()
+	backoff := policy.InitialBackoff
+	for attempt := 1; ; attempt++ {
+		err := f(ctx)
+		if err == nil {
+			return nil
+		}
+		var permanent *permanentError
+		if errors.As(err, &permanent) {
+			return permanent.err
+		}
+		if ctx.Err() != nil || (policy.Retryable != nil && !policy.Retryable(err)) {
+			return err
+		}
+
+		wait := policy.jittered(backoff)
+		backoff = time.Duration(math.Min(float64(backoff)*policy.Multiplier, float64(policy.MaxBackoff)))
+		exhausted := policy.MaxAttempts > 0 && attempt >= policy.MaxAttempts
+		elapsed := policy.Clock.Now().Sub(start)
+		if policy.MaxElapsed > 0 && elapsed+wait > policy.MaxElapsed {
+			exhausted = true
+		}
+		// The deadline of ctx is measured on the real clock.
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(wait).After(deadline) {
+			exhausted = true
+		}
+		if exhausted {
+			if policy.Name != "" {
+				retryStats.Add(policy.Name+".failures", 1)
+			}
+			logger.WarnContext(ctx, "Giving up retrying", slog.String("policy", policy.Name),
+				slog.Int("attempts", attempt), slog.Duration("elapsed", elapsed), slog.Any("err", err))
+			return err
+		}
+
+		if policy.Name != "" {
+			retryStats.Add(policy.Name+".retries", 1)
+		}
+		logger.DebugContext(ctx, "Retrying after error", slog.String("policy", policy.Name),
+			slog.Int("attempt", attempt), slog.Duration("backoff", wait), slog.Any("err", err))
+		timer := policy.Clock.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return errors.Join(err, ctx.Err())
+		case <-timer.C():
+		}
+	}
+}
+
+// normalized return the policy with the zero values replaced by their defaults.
+func (p RetryPolicy) normalized() RetryPolicy {
+	if p.MaxAttempts <= 0 && p.MaxElapsed <= 0 {
+		p.MaxAttempts = 3
+	}
+	if p.InitialBackoff <= 0 {
+		p.InitialBackoff = 100 * time.Millisecond
+	}
+	if p.MaxBackoff <= 0 {
+		p.MaxBackoff = 30 * time.Second
+	}
+	if p.Multiplier < 1 {
+		p.Multiplier = 2
+	}
+	p.Jitter = math.Min(math.Max(p.Jitter, 0), 1)
+	if p.Clock == nil {
+		p.Clock = RealClock()
+	}
+	return p
+}
+
+// jittered return backoff with its jitter fraction randomized.
+func (p RetryPolicy) jittered(backoff time.Duration) time.Duration {
+	jitter := time.Duration(float64(backoff) * p.Jitter)
+	if jitter <= 0 {
+		return backoff
+	}
+	// nolint:gosec
+	return backoff - jitter + rand.N(jitter+1)
+}
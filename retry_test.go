@@ -0,0 +1,172 @@
+package corefx_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/mawngo/go-corefx"
+	"github.com/mawngo/go-corefx/corefxtest"
+	"testing"
+	"time"
+)
+
+var errRetryTest = errors.New("error test")
+
+// runRetry call Retry using a fake clock in the background, advancing the clock by each backoff once Retry wait for it.
+// Return the error of Retry and the elapsed time of each call.
+func runRetry(t *testing.T, ctx context.Context, policy corefx.RetryPolicy, backoffs []time.Duration,
+	f func(attempt int) error) (error, []time.Duration) {
+	t.Helper()
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	clock := corefxtest.NewFakeClock(start)
+	policy.Clock = clock
+	var calls []time.Duration
+	result := make(chan error, 1)
+	go func() {
+		result <- corefx.Retry(ctx, policy, func(context.Context) error {
+			calls = append(calls, clock.Now().Sub(start))
+			return f(len(calls))
+		})
+	}()
+	for _, backoff := range backoffs {
+		clock.BlockUntil(1)
+		clock.Add(backoff)
+	}
+	select {
+	case err := <-result:
+		return err, calls
+	case <-time.After(5 * time.Second):
+		t.Fatal("Retry did not return")
+		return nil, nil
+	}
+}
+
+func TestRetry(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   corefx.RetryPolicy
+		backoffs []time.Duration
+		failures int
+		wantErr  error
+		want     []time.Duration
+	}{
+		{
+			name:     "zero policy",
+			backoffs: []time.Duration{100 * time.Millisecond, 200 * time.Millisecond},
+			failures: 3,
+			wantErr:  errRetryTest,
+			want:     []time.Duration{0, 100 * time.Millisecond, 300 * time.Millisecond},
+		},
+		{
+			name:     "succeed",
+			policy:   corefx.RetryPolicy{MaxAttempts: 5, InitialBackoff: time.Second, Multiplier: 3},
+			backoffs: []time.Duration{time.Second, 3 * time.Second},
+			failures: 2,
+			want:     []time.Duration{0, time.Second, 4 * time.Second},
+		},
+		{
+			name:     "max backoff",
+			policy:   corefx.RetryPolicy{MaxAttempts: 4, InitialBackoff: time.Second, MaxBackoff: 1500 * time.Millisecond},
+			backoffs: []time.Duration{time.Second, 1500 * time.Millisecond, 1500 * time.Millisecond},
+			failures: 4,
+			wantErr:  errRetryTest,
+			want:     []time.Duration{0, time.Second, 2500 * time.Millisecond, 4 * time.Second},
+		},
+		{
+			name:     "max elapsed",
+			policy:   corefx.RetryPolicy{MaxElapsed: 5 * time.Second, InitialBackoff: time.Second},
+			backoffs: []time.Duration{time.Second, 2 * time.Second},
+			failures: 10,
+			wantErr:  errRetryTest,
+			// The next backoff of 4s would exceed the max elapsed.
+			want: []time.Duration{0, time.Second, 3 * time.Second},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err, calls := runRetry(t, context.Background(), tt.policy, tt.backoffs, func(attempt int) error {
+				if attempt <= tt.failures {
+					return errRetryTest
+				}
+				return nil
+			})
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Errorf("Retry() error = %v, want %v", err, tt.wantErr)
+			}
+			if fmt.Sprint(calls) != fmt.Sprint(tt.want) {
+				t.Errorf("Retry() calls at %v, want %v", calls, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryNotRetryable(t *testing.T) {
+	tests := []struct {
+		name   string
+		policy corefx.RetryPolicy
+		err    error
+		want   error
+	}{
+		{
+			name: "permanent",
+			err:  corefx.Permanent(errRetryTest),
+			want: errRetryTest,
+		},
+		{
+			name: "wrapped permanent",
+			err:  fmt.Errorf("error calling: %w", corefx.Permanent(errRetryTest)),
+			want: errRetryTest,
+		},
+		{
+			name:   "retryable",
+			policy: corefx.RetryPolicy{Retryable: func(err error) bool { return !errors.Is(err, errRetryTest) }},
+			err:    errRetryTest,
+			want:   errRetryTest,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err, calls := runRetry(t, context.Background(), tt.policy, nil, func(int) error {
+				return tt.err
+			})
+			// Both permanent errors are returned without the mark.
+			if err != tt.want {
+				t.Errorf("Retry() error = %#v, want %#v", err, tt.want)
+			}
+			if len(calls) != 1 {
+				t.Errorf("Retry() calls = %d, want 1", len(calls))
+			}
+		})
+	}
+}
+
+func TestRetryCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	clock := corefxtest.NewFakeClock(time.Now())
+	result := make(chan error, 1)
+	go func() {
+		result <- corefx.Retry(ctx, corefx.RetryPolicy{Clock: clock}, func(context.Context) error {
+			return errRetryTest
+		})
+	}()
+	clock.BlockUntil(1)
+	cancel()
+	err := <-result
+	if !errors.Is(err, errRetryTest) || !errors.Is(err, context.Canceled) {
+		t.Errorf("Retry() error = %v, want the last error and context.Canceled", err)
+	}
+}
+
+func TestRetryDeadline(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	calls := 0
+	err := corefx.Retry(ctx, corefx.RetryPolicy{MaxAttempts: 5, InitialBackoff: 2 * time.Minute},
+		func(context.Context) error {
+			calls++
+			return errRetryTest
+		})
+	if !errors.Is(err, errRetryTest) || calls != 1 {
+		t.Errorf("Retry() error = %v, calls = %d, want to give up before the backoff exceed the deadline", err, calls)
+	}
+}
@@ -0,0 +1,133 @@
+package corefx
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// LogSampleConfig optional interface that a CoreConfig can implement to rate-limit identical log records.
+// Records with the same level and message are limited to LogSampleLimitValue records per LogSampleIntervalValue,
+// records over the limit are dropped and summarized by a single record once the interval elapsed.
+type LogSampleConfig interface {
+	// LogSampleLimitValue max number of identical records per interval, zero to disable sampling.
+	LogSampleLimitValue() int
+	// LogSampleIntervalValue the sampling interval.
+	LogSampleIntervalValue() time.Duration
+}
+
+type LogSampleEnv struct {
+	LogSampleLimit    int           `json:"log_sample_limit" mapstructure:"log_sample_limit"`
+	LogSampleInterval time.Duration `json:"log_sample_interval" mapstructure:"log_sample_interval" default:"1s"`
+}
+
+func (e LogSampleEnv) LogSampleLimitValue() int {
+	return e.LogSampleLimit
+}
+
+func (e LogSampleEnv) LogSampleIntervalValue() time.Duration {
+	return e.LogSampleInterval
+}
+
+var _ LogSampleConfig = (*LogSampleEnv)(nil)
+
+// samplingHandler drop identical records over the limit of each interval.
+type samplingHandler struct {
+	slog.Handler
+	state *samplingState
+}
+
+type samplingKey struct {
+	level   slog.Level
+	message string
+}
+
+type samplingWindow struct {
+	start   time.Time
+	count   int
+	dropped int
+}
+
+type samplingState struct {
+	mu        sync.Mutex
+	limit     int
+	interval  time.Duration
+	lastSweep time.Time
+	windows   map[samplingKey]*samplingWindow
+}
+
+func newSamplingHandler(next slog.Handler, limit int, interval time.Duration) slog.Handler {
+	return &samplingHandler{
+		Handler: next,
+		state: &samplingState{
+			limit:    limit,
+			interval: interval,
+			windows:  map[samplingKey]*samplingWindow{},
+		},
+	}
+}
+
+func (h *samplingHandler) Handle(ctx context.Context, r slog.Record) error {
+	allowed, summaries := h.state.sample(samplingKey{level: r.Level, message: r.Message}, r.Time)
+	for _, summary := range summaries {
+		if h.Handler.Enabled(ctx, summary.Level) {
+			_ = h.Handler.Handle(ctx, summary)
+		}
+	}
+	if !allowed {
+		return nil
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *samplingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &samplingHandler{Handler: h.Handler.WithAttrs(attrs), state: h.state}
+}
+
+func (h *samplingHandler) WithGroup(name string) slog.Handler {
+	return &samplingHandler{Handler: h.Handler.WithGroup(name), state: h.state}
+}
+
+// sample count the record and return whether it is allowed,
+// with summaries of dropped records of the expired windows.
+func (s *samplingState) sample(key samplingKey, now time.Time) (bool, []slog.Record) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var summaries []slog.Record
+	// Sweep expired windows at most once per interval, so dropped records are reported even if not logged again.
+	if now.Sub(s.lastSweep) >= s.interval {
+		s.lastSweep = now
+		for k, w := range s.windows {
+			if now.Sub(w.start) < s.interval {
+				continue
+			}
+			if w.dropped > 0 {
+				summaries = append(summaries, samplingSummaryOf(k, w.dropped, now))
+			}
+			delete(s.windows, k)
+		}
+	}
+
+	w, ok := s.windows[key]
+	if !ok || now.Sub(w.start) >= s.interval {
+		if ok && w.dropped > 0 {
+			summaries = append(summaries, samplingSummaryOf(key, w.dropped, now))
+		}
+		w = &samplingWindow{start: now}
+		s.windows[key] = w
+	}
+	w.count++
+	if w.count > s.limit {
+		w.dropped++
+		return false, summaries
+	}
+	return true, summaries
+}
+
+func samplingSummaryOf(key samplingKey, dropped int, now time.Time) slog.Record {
+	r := slog.NewRecord(now, key.level, "Dropped similar log records", 0)
+	r.AddAttrs(slog.String("log_msg", key.message), slog.Int("dropped", dropped))
+	return r
+}
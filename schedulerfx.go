@@ -0,0 +1,226 @@
+package corefx
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"go.uber.org/fx"
+	"log/slog"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// schedulerStats expvar counters of scheduled tasks, served on /debug/vars of the debug server.
+// Keys are <task>.runs, <task>.failures, <task>.skipped and <task>.seconds (total run duration).
+var schedulerStats = expvar.NewMap("corefx_scheduler")
+
+type SchedulerConfig interface {
+	// SchedulerTimezoneValue IANA timezone of task schedules, for example "Asia/Ho_Chi_Minh".
+	// Empty to use the local timezone. Schedules can override it using the CRON_TZ=<zone> prefix.
+	SchedulerTimezoneValue() string
+}
+
+type SchedulerEnv struct {
+	SchedulerTimezone string `json:"scheduler_timezone" mapstructure:"scheduler_timezone"`
+}
+
+func (e SchedulerEnv) SchedulerTimezoneValue() string {
+	return e.SchedulerTimezone
+}
+
+var _ SchedulerConfig = (*SchedulerEnv)(nil)
+
+// ScheduledTask a task run periodically by the Scheduler.
+type ScheduledTask interface {
+	// Schedule cron expression of the task, in the standard 5 fields format, for example "*/5 * * * *".
+	// The @yearly, @monthly, @weekly, @daily, @hourly and @every <duration> descriptors are supported, and the
+	// timezone can be set using the CRON_TZ=<zone> prefix.
+	Schedule() string
+	// Run run the task, ctx is cancelled when the app stops.
+	Run(ctx context.Context) error
+}
+
+// NamedScheduledTask optional interface that ScheduledTask can implement to name itself in logs and metrics.
+// Default to the type name of the task.
+type NamedScheduledTask interface {
+	TaskName() string
+}
+
+// AsScheduledTask annotate a ScheduledTask constructor to register it into the scheduled task group.
+// For example, fx.Provide(corefx.AsScheduledTask(newCleanupTask)).
+func AsScheduledTask(f any) any {
+	return fx.Annotate(
+		f,
+		fx.As(new(ScheduledTask)),
+		fx.ResultTags(`group:"scheduled_tasks"`),
+	)
+}
+
+// scheduledTask a registered task and its parsed schedule.
+type scheduledTask struct {
+	task     ScheduledTask
+	name     string
+	schedule *cronSchedule
+	running  atomic.Bool
+}
+
+// Scheduler run the registered scheduled tasks according to their schedule.
+type Scheduler struct {
+	elector LeaderElector
+	tasks   []*scheduledTask
+	logger  *slog.Logger
+	clock   Clock
+	cancel  context.CancelFunc
+	wg      sync.WaitGroup
+}
+
+type SchedulerParams struct {
+	fx.In
+	Loaded    loadedConfig
+	Config    SchedulerConfig `optional:"true"`
+	Tasks     []ScheduledTask `group:"scheduled_tasks"`
+	Elector   LeaderElector   `optional:"true"`
+	Logger    *slog.Logger
+	Clock     Clock
+	Lifecycle fx.Lifecycle
+}
+
+// NewScheduler create a scheduler, which start scheduling tasks when the app starts, and cancel running tasks when the
+// app stops, waiting for them to return.
+// A run is skipped if the previous run of the same task is still running, or if the task implement LeaderOnly and this
+// instance is not the leader elected by the LeaderElector. Panics of tasks are recovered and logged.
+func NewScheduler(p SchedulerParams) (*Scheduler, error) {
+	loc := time.Local
+	if p.Config != nil && p.Config.SchedulerTimezoneValue() != "" {
+		var err error
+		if loc, err = time.LoadLocation(p.Config.SchedulerTimezoneValue()); err != nil {
+			return nil, fmt.Errorf("error invalid scheduler timezone [%s]: %w", p.Config.SchedulerTimezoneValue(), err)
+		}
+	}
+
+	s := &Scheduler{elector: p.Elector, logger: p.Logger, clock: p.Clock, tasks: make([]*scheduledTask, 0, len(p.Tasks))}
+	for _, task := range p.Tasks {
+		name := scheduledTaskNameOf(task)
+		schedule, err := parseCron(task.Schedule(), loc)
+		if err != nil {
+			return nil, fmt.Errorf("error invalid schedule [%s] of task [%s]: %w", task.Schedule(), name, err)
+		}
+		if isLeaderOnly(task) && p.Elector == nil {
+			return nil, fmt.Errorf("error task [%s] is leader-only, but no LeaderElector is provided", name)
+		}
+		s.tasks = append(s.tasks, &scheduledTask{task: task, name: name, schedule: schedule})
+	}
+	p.Lifecycle.Append(fx.Hook{
+		OnStart: s.start,
+		OnStop:  s.stop,
+	})
+	return s, nil
+}
+
+func (s *Scheduler) start(_ context.Context) error {
+	// Tasks outlive the start context.
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	for _, task := range s.tasks {
+		s.wg.Add(1)
+		go s.schedule(ctx, task)
+	}
+	if len(s.tasks) > 0 {
+		s.logger.Info("Started scheduler", slog.Int("tasks", len(s.tasks)))
+	}
+	return nil
+}
+
+func (s *Scheduler) stop(ctx context.Context) error {
+	s.cancel()
+	done := make(chan struct{})
+	go func() {
+		s.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("error waiting for scheduled tasks to stop: %w", ctx.Err())
+	}
+}
+
+// schedule run the task at each activation time of its schedule, until ctx is cancelled.
+func (s *Scheduler) schedule(ctx context.Context, task *scheduledTask) {
+	defer s.wg.Done()
+	logger := s.logger.With(slog.String("task", task.name))
+	var last time.Time
+	for {
+		// Timers may fire slightly before the activation time of the wall clock.
+		now := s.clock.Now()
+		if now.Before(last) {
+			now = last
+		}
+		next := task.schedule.next(now)
+		if next.IsZero() {
+			logger.Warn("Scheduled task will never run", slog.String("schedule", task.task.Schedule()))
+			return
+		}
+		last = next
+
+		timer := s.clock.NewTimer(next.Sub(s.clock.Now()))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C():
+		}
+		if isLeaderOnly(task.task) && !s.elector.IsLeader() {
+			logger.Debug("Skipped scheduled task, not the leader")
+			continue
+		}
+		if !task.running.CompareAndSwap(false, true) {
+			logger.Warn("Skipped scheduled task, previous run is still running")
+			schedulerStats.Add(task.name+".skipped", 1)
+			continue
+		}
+		s.wg.Add(1)
+		go s.run(ctx, task, logger)
+	}
+}
+
+// run run the task once and record its stats.
+func (s *Scheduler) run(ctx context.Context, task *scheduledTask, logger *slog.Logger) {
+	defer s.wg.Done()
+	defer task.running.Store(false)
+	logger.Debug("Running scheduled task")
+	start := s.clock.Now()
+	err := runRecovered(ctx, task.task.Run)
+	duration := s.clock.Now().Sub(start)
+	schedulerStats.Add(task.name+".runs", 1)
+	schedulerStats.AddFloat(task.name+".seconds", duration.Seconds())
+	if err != nil {
+		schedulerStats.Add(task.name+".failures", 1)
+		logger.Error("Scheduled task failed", slog.Any("err", err), slog.Duration("duration", duration))
+		return
+	}
+	logger.Debug("Scheduled task completed", slog.Duration("duration", duration))
+}
+
+// scheduledTaskNameOf return the name of the task.
+func scheduledTaskNameOf(task ScheduledTask) string {
+	if named, ok := task.(NamedScheduledTask); ok {
+		return named.TaskName()
+	}
+	return fmt.Sprintf("%T", task)
+}
+
+// NewSchedulerModule run the tasks registered using AsScheduledTask with the app lifecycle.
+// Must be used together with NewModule.
+// The env config can register as SchedulerConfig to configure the timezone of schedules, otherwise the local timezone
+// is used. Run stats are published as the corefx_scheduler expvar.
+func NewSchedulerModule() fx.Option {
+	return fx.Module("corefx.scheduler",
+		fx.Provide(NewScheduler),
+		fx.Invoke(func(_ *Scheduler) {
+			// force initialization of the scheduler.
+		}),
+	)
+}
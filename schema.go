@@ -0,0 +1,212 @@
+package corefx
+
+import (
+	"encoding"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"time"
+)
+
+// JSONSchemaDialect the JSON Schema dialect of schemas generated by GenerateJSONSchema.
+const JSONSchemaDialect = "https://json-schema.org/draft/2020-12/schema"
+
+var (
+	durationType        = reflect.TypeOf(time.Duration(0))
+	timeType            = reflect.TypeOf(time.Time{})
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// GenerateJSONSchema generate the JSON Schema of config files for the config struct cfg, which can be used to
+// validate config files in CI or to enable autocompletion in editors.
+// Properties follow the json tags of cfg fields, fields tagged with `required:"true"` are required, descriptions are
+// read from the `desc:"..."` tag, and defaults are read from the `default` tag and the current values of cfg.
+// Default values of secret fields are omitted. Unknown properties are allowed, as config files can contain sections
+// loaded using Section.
+func GenerateJSONSchema(cfg any) ([]byte, error) {
+	value, err := defaultedConfigOf(cfg)
+	if err != nil {
+		return nil, err
+	}
+	schema := schemaOf(value.Type(), value)
+	schema["$schema"] = JSONSchemaDialect
+	return json.MarshalIndent(schema, "", "  ")
+}
+
+// defaultedConfigOf return a copy of the config struct cfg with defaults applied, so cfg is not modified.
+// The cfg can be a nil pointer to the config struct.
+func defaultedConfigOf(cfg any) (reflect.Value, error) {
+	t := reflect.TypeOf(cfg)
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+	if t == nil || t.Kind() != reflect.Struct {
+		return reflect.Value{}, errors.New("error config must be a struct or a pointer to struct")
+	}
+	value := reflect.New(t)
+	if v := reflect.Indirect(reflect.ValueOf(cfg)); v.IsValid() {
+		value.Elem().Set(v)
+	}
+	if err := applyDefaults(value.Interface()); err != nil {
+		return reflect.Value{}, err
+	}
+	return value.Elem(), nil
+}
+
+// schemaOf return the schema of type t, v is the value used to read defaults and can be invalid.
+func schemaOf(t reflect.Type, v reflect.Value) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+		if v.IsValid() {
+			v = v.Elem()
+		}
+	}
+
+	switch {
+	case t == durationType:
+		// Durations accept both 30s and a number of nanoseconds.
+		return map[string]any{"type": []string{"string", "integer"}}
+	case t == timeType:
+		return map[string]any{"type": "string", "format": "date-time"}
+	case reflect.PointerTo(t).Implements(textUnmarshalerType):
+		if isNumberKind(t.Kind()) {
+			// For example ByteSize, which accept both 512MB and a number of bytes.
+			return map[string]any{"type": []string{"string", "integer"}}
+		}
+		return map[string]any{"type": "string"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	case reflect.String:
+		return map[string]any{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": schemaOf(t.Elem(), reflect.Value{})}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": schemaOf(t.Elem(), reflect.Value{})}
+	case reflect.Struct:
+		properties := map[string]any{}
+		var required []string
+		collectSchemaProperties(t, v, properties, &required)
+		schema := map[string]any{"type": "object", "properties": properties}
+		if len(required) > 0 {
+			schema["required"] = required
+		}
+		return schema
+	default:
+		// Interfaces and other kinds accept any value.
+		return map[string]any{}
+	}
+}
+
+// collectSchemaProperties collect the schema of fields of struct type t into properties, including fields of
+// embedded structs. The names of required fields are appended to required.
+func collectSchemaProperties(t reflect.Type, v reflect.Value, properties map[string]any, required *[]string) {
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		var value reflect.Value
+		if v.IsValid() {
+			value = v.Field(i)
+		}
+		if field.Anonymous && field.Type.Kind() == reflect.Struct {
+			collectSchemaProperties(field.Type, value, properties, required)
+			continue
+		}
+		name := configNameOf(field)
+		if name == "-" {
+			continue
+		}
+
+		property := schemaOf(field.Type, value)
+		if desc := field.Tag.Get("desc"); desc != "" {
+			property["description"] = desc
+		}
+		if def := fieldDefaultOf(field, name, value); def != nil {
+			property["default"] = def
+		}
+		properties[name] = property
+		if isRequired, _ := requiredOf(field); isRequired {
+			*required = append(*required, name)
+		}
+	}
+}
+
+// fieldDefaultOf return the JSON representation of the default value of field, read from its value or its default tag.
+// Return nil if field has no default or is a secret.
+func fieldDefaultOf(field reflect.StructField, name string, value reflect.Value) any {
+	if isSecretKey(name) || isSecretField(field) {
+		return nil
+	}
+	if def := defaultOf(value); def != nil {
+		return def
+	}
+	return defaultTagOf(field)
+}
+
+// defaultOf return the JSON representation of the default value v, or nil if v is invalid, zero or a struct.
+func defaultOf(v reflect.Value) any {
+	for v.IsValid() && (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) {
+		v = v.Elem()
+	}
+	if !v.IsValid() || isUnset(v) {
+		return nil
+	}
+
+	switch value := v.Interface().(type) {
+	case time.Duration:
+		return value.String()
+	case encoding.TextMarshaler:
+		text, err := value.MarshalText()
+		if err != nil {
+			return nil
+		}
+		return string(text)
+	}
+	if v.Kind() == reflect.Struct {
+		// Fields of nested structs have their own defaults.
+		return nil
+	}
+	b, err := json.Marshal(v.Interface())
+	if err != nil {
+		return nil
+	}
+	var def any
+	if err := json.Unmarshal(b, &def); err != nil {
+		return nil
+	}
+	return def
+}
+
+// defaultTagOf return the JSON representation of the default tag of field, or nil if not declared.
+// Used for fields of nil nested structs, which have no value to read defaults from.
+func defaultTagOf(field reflect.StructField) any {
+	tag, ok := field.Tag.Lookup("default")
+	if !ok {
+		return nil
+	}
+	value := reflect.New(field.Type).Elem()
+	if err := decodeDefault(tag, value); err != nil {
+		return nil
+	}
+	return defaultOf(value)
+}
+
+func isNumberKind(kind reflect.Kind) bool {
+	switch kind {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	default:
+		return false
+	}
+}
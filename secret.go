@@ -0,0 +1,122 @@
+package corefx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"go.uber.org/fx"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// SecretResolver resolve secret references in config values, for example "vault:secret/data/myapp#db_password".
+// Register resolvers using AsSecretResolver, string config values with a matching scheme are resolved at load time.
+type SecretResolver interface {
+	// SecretScheme the scheme of references handled by this resolver, for example "vault".
+	SecretScheme() string
+	// ResolveSecret resolve the reference, without the scheme prefix, into the secret value.
+	ResolveSecret(ctx context.Context, ref string) (string, error)
+}
+
+// AsSecretResolver annotate a SecretResolver constructor to register it into the secret resolver group.
+// For example, fx.Provide(corefx.AsSecretResolver(newMyResolver)).
+func AsSecretResolver(f any) any {
+	return fx.Annotate(
+		f,
+		fx.As(new(SecretResolver)),
+		fx.ResultTags(`group:"secret_resolvers"`),
+	)
+}
+
+// resolveSecrets replace every string value of cfg that reference a secret with the resolved secret, including values
+// of free-form sections like map[string]any.
+// Return an error joining every resolution error.
+func resolveSecrets(ctx context.Context, cfg any, resolvers []SecretResolver) error {
+	if len(resolvers) == 0 {
+		return nil
+	}
+	schemes := make(map[string]SecretResolver, len(resolvers))
+	for _, resolver := range resolvers {
+		if resolver != nil {
+			schemes[resolver.SecretScheme()] = resolver
+		}
+	}
+
+	var errs []error
+	resolve := func(fieldPath string, value string) (string, bool) {
+		scheme, ref, ok := strings.Cut(value, ":")
+		if !ok {
+			return "", false
+		}
+		resolver, ok := schemes[scheme]
+		if !ok {
+			return "", false
+		}
+		secret, err := resolver.ResolveSecret(ctx, ref)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("[%s] cannot resolve secret %s: %w", fieldPath, scheme, err))
+			return "", false
+		}
+		return secret, true
+	}
+	walkStrings(reflect.ValueOf(cfg), "", resolve)
+	return errors.Join(errs...)
+}
+
+// walkStrings walk every string value of v, including values held by interfaces, replacing it with the resolved value.
+func walkStrings(v reflect.Value, fieldPath string, resolve func(fieldPath string, value string) (string, bool)) {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if !v.IsNil() {
+			walkStrings(v.Elem(), fieldPath, resolve)
+		}
+	case reflect.Interface:
+		if v.IsNil() {
+			return
+		}
+		if !v.CanSet() || v.Elem().Kind() == reflect.Pointer {
+			walkStrings(v.Elem(), fieldPath, resolve)
+			return
+		}
+		// Values held by interfaces, for example of map[string]any, are not settable, resolve a copy then put it back.
+		elem := reflect.New(v.Elem().Type()).Elem()
+		elem.Set(v.Elem())
+		walkStrings(elem, fieldPath, resolve)
+		v.Set(elem)
+	case reflect.String:
+		if !v.CanSet() {
+			return
+		}
+		if secret, ok := resolve(fieldPath, v.String()); ok {
+			v.SetString(secret)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			walkStrings(v.Index(i), fieldPath+"["+strconv.Itoa(i)+"]", resolve)
+		}
+	case reflect.Map:
+		for _, k := range sortedMapKeys(v) {
+			elemPath := fieldPath + "[" + fmt.Sprint(k.Interface()) + "]"
+			// Map values are not addressable, resolve a copy then put it back.
+			elem := reflect.New(v.Type().Elem()).Elem()
+			elem.Set(v.MapIndex(k))
+			walkStrings(elem, elemPath, resolve)
+			v.SetMapIndex(k, elem)
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			if field.Anonymous && field.Type.Kind() == reflect.Struct {
+				walkStrings(v.Field(i), fieldPath, resolve)
+				continue
+			}
+			walkStrings(v.Field(i), joinConfigPath(fieldPath, field.Name), resolve)
+		}
+	default:
+	}
+}
@@ -0,0 +1,100 @@
+package corefx
+
+import (
+	"errors"
+	"github.com/spf13/viper"
+	"os"
+	"strings"
+)
+
+// loadedConfig the loaded CoreConfig and the watcher holding its viper instance.
+// Depending on it guarantees that the config is loaded.
+type loadedConfig struct {
+	config  CoreConfig
+	watcher *ConfigWatcher
+}
+
+func newLoadedConfig(cfg CoreConfig, w *ConfigWatcher) loadedConfig {
+	return loadedConfig{config: cfg, watcher: w}
+}
+
+// Section create a constructor that unmarshal a named subsection of the loaded config into T.
+// Nested sections are separated by ".", for example "database.primary".
+// When automatic env is enabled, env variables of the section are read as well, for example DATABASE__URL.
+// Missing section result in the zero value of T, fields tagged with `required:"true"` and ConfigValidator are
+// checked the same as the main config.
+// Must be used together with NewModule.
+// For example, fx.Provide(corefx.Section[DatabaseConfig]("database")).
+func Section[T any](key string) any {
+	return func(c loadedConfig) (T, error) {
+		var section T
+		v := c.watcher.Viper()
+		if v == nil {
+			return section, errors.New("error config is not loaded")
+		}
+
+		settings := v.AllSettings()
+		for _, part := range strings.Split(strings.ToLower(key), ".") {
+			nested, ok := settings[part].(map[string]any)
+			if !ok {
+				nested = map[string]any{}
+			}
+			settings = nested
+		}
+
+		if err := applyDefaults(&section); err != nil {
+			return section, err
+		}
+		// Register keys of the section struct first, so env variables can override keys missing in config files.
+		defaults, err := configMapOf(&section)
+		if err != nil {
+			return section, err
+		}
+		sub := viper.New()
+		if err := sub.MergeConfigMap(defaults); err != nil {
+			return section, err
+		}
+		if err := sub.MergeConfigMap(settings); err != nil {
+			return section, err
+		}
+		envPrefix := c.config.AppEnvPrefixValue()
+		if c.config.AppAutomaticEnvValue() {
+			// Nested keys are separated by "__", the same as the main config.
+			sub.SetEnvPrefix(envNameOf(key, envPrefix) + "_")
+			sub.SetEnvKeyReplacer(strings.NewReplacer(`.`, `__`))
+			sub.AutomaticEnv()
+		}
+
+		if err := sub.Unmarshal(&section, jsonDecoderConfig); err != nil {
+			return section, err
+		}
+		if err := expandPlaceholders(&section); err != nil {
+			return section, err
+		}
+		isSet := func(configPath string) bool {
+			return isSectionKeySet(settings, strings.TrimPrefix(configPath, key+"."), configPath, envPrefix,
+				c.config.AppAutomaticEnvValue())
+		}
+		if err := checkRequiredTagsAt(&section, key, envPrefix, isSet); err != nil {
+			return section, err
+		}
+		return section, validateConfig(&section)
+	}
+}
+
+// isSectionKeySet whether the key of a section is set in settings of the section, or in env.
+func isSectionKeySet(settings map[string]any, key string, configPath string, envPrefix string, automaticEnv bool) bool {
+	if automaticEnv {
+		if value, ok := os.LookupEnv(envNameOf(configPath, envPrefix)); ok && value != "" {
+			return true
+		}
+	}
+	values := map[string]any{}
+	flattenSettings(values, "", settings)
+	for path := range values {
+		if path == key || strings.HasPrefix(path, key+".") || strings.HasPrefix(key, path+".") {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,92 @@
+package corefx
+
+import (
+	"github.com/getsentry/sentry-go"
+	slogsentry "github.com/samber/slog-sentry/v2"
+	"log/slog"
+	"strings"
+)
+
+// sentryFingerprintKey attribute overriding the fingerprint of the sentry event of a record.
+const sentryFingerprintKey = "fingerprint"
+
+// sentryMaxErrorDepth default depth of wrapped errors reported as exceptions.
+const sentryMaxErrorDepth = 10
+
+// SentryTagsConfig optional interface that a SentryConfig can implement to report record attributes as sentry tags.
+type SentryTagsConfig interface {
+	// SentryTagsValue tag name by attribute key, for example {"tenant_id": "tenant", "http.route": "route"}.
+	// Attributes of groups are matched using "." separated keys.
+	SentryTagsValue() map[string]string
+}
+
+type SentryTagsEnv struct {
+	// SentryTags tag name by attribute key.
+	// Keys containing "." are loaded as nested keys, so the value is flattened back into keys.
+	SentryTags map[string]any `json:"sentry_tags" mapstructure:"sentry_tags"`
+}
+
+func (e SentryTagsEnv) SentryTagsValue() map[string]string {
+	tags := make(map[string]string, len(e.SentryTags))
+	flattenLogLevels(tags, "", e.SentryTags)
+	return tags
+}
+
+var _ SentryTagsConfig = (*SentryTagsEnv)(nil)
+
+// sentryConverterOf create a slogsentry.Converter that enrich the events of slogsentry.DefaultConverter:
+//   - attributes mapped by tags are reported as tags.
+//   - error attributes are reported as exceptions, so events are grouped by error type and stacktrace.
+//   - a fingerprint attribute, string or []string, override the fingerprint of the event.
+func sentryConverterOf(tags map[string]string) slogsentry.Converter {
+	lowerTags := make(map[string]string, len(tags))
+	for key, tag := range tags {
+		lowerTags[strings.ToLower(key)] = tag
+	}
+	return func(addSource bool, replaceAttr func(groups []string, a slog.Attr) slog.Attr, loggerAttr []slog.Attr,
+		groups []string, record *slog.Record, hub *sentry.Hub) *sentry.Event {
+		event := slogsentry.DefaultConverter(addSource, replaceAttr, loggerAttr, groups, record, hub)
+		attrs := make([]slog.Attr, 0, len(loggerAttr)+record.NumAttrs())
+		for _, attr := range loggerAttr {
+			attrs = appendFlatAttr(attrs, "", attr)
+		}
+		group := strings.Join(groups, ".")
+		record.Attrs(func(attr slog.Attr) bool {
+			attrs = appendFlatAttr(attrs, group, attr)
+			return true
+		})
+
+		maxErrorDepth := sentryMaxErrorDepth
+		if client := hub.Client(); client != nil && client.Options().MaxErrorDepth > 0 {
+			maxErrorDepth = client.Options().MaxErrorDepth
+		}
+		for _, attr := range attrs {
+			if tag, ok := lowerTags[strings.ToLower(attr.Key)]; ok {
+				if event.Tags == nil {
+					event.Tags = make(map[string]string)
+				}
+				event.Tags[tag] = attr.Value.String()
+			}
+			switch value := attr.Value.Any().(type) {
+			case error:
+				if len(event.Exception) == 0 && value != nil {
+					event.SetException(value, maxErrorDepth)
+				}
+			case string:
+				if isSentryFingerprintKey(attr.Key) && value != "" {
+					event.Fingerprint = []string{value}
+				}
+			case []string:
+				if isSentryFingerprintKey(attr.Key) && len(value) > 0 {
+					event.Fingerprint = value
+				}
+			}
+		}
+		return event
+	}
+}
+
+// isSentryFingerprintKey whether the flattened key is the fingerprint attribute, at top level or in a group.
+func isSentryFingerprintKey(key string) bool {
+	return key == sentryFingerprintKey || strings.HasSuffix(key, "."+sentryFingerprintKey)
+}
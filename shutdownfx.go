@@ -0,0 +1,355 @@
+package corefx
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"github.com/getsentry/sentry-go"
+	"go.uber.org/fx"
+	"log/slog"
+	"net/http"
+	"os"
+	"slices"
+	"sync"
+	"time"
+)
+
+type ShutdownConfig interface {
+	// ShutdownDrainDelayValue delay between marking the app as draining and stopping it,
+	// to let load balancers stop sending traffic. Return zero to stop immediately.
+	ShutdownDrainDelayValue() time.Duration
+	// ShutdownTimeoutValue deadline of the fx OnStop hooks. Default to the fx stop timeout if zero.
+	ShutdownTimeoutValue() time.Duration
+}
+
+type ShutdownEnv struct {
+	ShutdownDrainDelay time.Duration `json:"shutdown_drain_delay" mapstructure:"shutdown_drain_delay" default:"5s"`
+	ShutdownTimeout    time.Duration `json:"shutdown_timeout" mapstructure:"shutdown_timeout" default:"30s"`
+}
+
+func (e ShutdownEnv) ShutdownDrainDelayValue() time.Duration {
+	return e.ShutdownDrainDelay
+}
+
+func (e ShutdownEnv) ShutdownTimeoutValue() time.Duration {
+	return e.ShutdownTimeout
+}
+
+var _ ShutdownConfig = (*ShutdownEnv)(nil)
+
+// ShutdownCoordinator track whether the app is draining before shutdown, provided by Run.
+// Readiness checks should fail once the app is draining, so load balancers stop sending traffic: the coordinator is an
+// http.Handler responding 503 while draining, which can be mounted as the readiness endpoint, for example
+// corefx.Route{Pattern: "GET /readyz", Handler: coordinator}. The HTTP server of NewHTTPServerModule also close its
+// keep-alive connections once the app is draining.
+type ShutdownCoordinator struct {
+	config   ShutdownConfig
+	logger   *slog.Logger
+	clock    Clock
+	once     sync.Once
+	draining chan struct{}
+}
+
+type ShutdownCoordinatorParams struct {
+	fx.In
+	Config ShutdownConfig `optional:"true"`
+	Logger *slog.Logger   `optional:"true"`
+	Clock  Clock          `optional:"true"`
+}
+
+// NewShutdownCoordinator create a shutdown coordinator.
+// The shutdown config is read lazily, so it can be loaded from the same config file as other values.
+func NewShutdownCoordinator(p ShutdownCoordinatorParams) *ShutdownCoordinator {
+	clock := p.Clock
+	if clock == nil {
+		clock = RealClock()
+	}
+	return &ShutdownCoordinator{
+		config:   p.Config,
+		logger:   p.Logger,
+		clock:    clock,
+		draining: make(chan struct{}),
+	}
+}
+
+// Draining return a channel that is closed when the app start draining.
+func (c *ShutdownCoordinator) Draining() <-chan struct{} {
+	return c.draining
+}
+
+// IsDraining whether the app is draining before shutdown.
+func (c *ShutdownCoordinator) IsDraining() bool {
+	select {
+	case <-c.draining:
+		return true
+	default:
+		return false
+	}
+}
+
+// ServeHTTP respond 503 Service Unavailable while the app is draining, 200 OK otherwise.
+func (c *ShutdownCoordinator) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	if c.IsDraining() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = w.Write([]byte("draining\n"))
+		return
+	}
+	_, _ = w.Write([]byte("ok\n"))
+}
+
+// drain mark the app as draining and wait for the drain delay, or until ctx is done.
+func (c *ShutdownCoordinator) drain(ctx context.Context) {
+	c.once.Do(func() {
+		close(c.draining)
+	})
+	if c.config == nil || c.config.ShutdownDrainDelayValue() <= 0 {
+		return
+	}
+	logger := c.logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	logger.Info("Draining before shutdown", slog.Duration("delay", c.config.ShutdownDrainDelayValue()))
+	select {
+	case <-ctx.Done():
+	case <-c.clock.After(c.config.ShutdownDrainDelayValue()):
+	}
+}
+
+// stopTimeout return the deadline of the fx OnStop hooks.
+func (c *ShutdownCoordinator) stopTimeout(app *fx.App) time.Duration {
+	if c.config == nil || c.config.ShutdownTimeoutValue() <= 0 {
+		return app.StopTimeout()
+	}
+	return c.config.ShutdownTimeoutValue()
+}
+
+const (
+	// ExitCodeFailure the app failed to stop, or failed for other reasons.
+	ExitCodeFailure = 1
+	// ExitCodeStartFailure the app failed to start, for example a start hook failed to connect to a dependency.
+	ExitCodeStartFailure = 69
+	// ExitCodeInvalidApp the app failed to build, for example a missing dependency or a failed constructor.
+	ExitCodeInvalidApp = 70
+	// ExitCodeInvalidConfig the config failed to load or is invalid, see ConfigError.
+	ExitCodeInvalidConfig = 78
+)
+
+// runState state of the app run by Run, filled by the app components.
+type runState struct {
+	// hub sentry hub of the app, flushed when the app failed before being stopped.
+	hub *sentry.Hub
+}
+
+// ConfigCheckFlag command line flag enabling the validate only mode of Run, see ValidateOnly.
+const ConfigCheckFlag = "--config-check"
+
+// validateOnlyOption option marking the app to be validated only, see ValidateOnly.
+type validateOnlyOption struct {
+	fx.Option
+}
+
+// ValidateOnly make Run validate the app instead of running it, for CI pipelines and pre-deploy checks:
+// the dependency graph is checked using fx.ValidateApp, then the config is loaded and validated, and the effective
+// config report is printed into stdout, without starting the app. Constructors of invoked components are run,
+// but start hooks are not.
+// The option must be passed directly to Run or Main. The mode is also enabled when the process is started with the
+// --config-check argument, see ConfigCheckFlag.
+func ValidateOnly() fx.Option {
+	return validateOnlyOption{Option: fx.Options()}
+}
+
+// isValidateOnly whether opts contain ValidateOnly, or the process is started with ConfigCheckFlag.
+func isValidateOnly(opts []fx.Option) bool {
+	for _, opt := range opts {
+		if _, ok := opt.(validateOnlyOption); ok {
+			return true
+		}
+	}
+	return slices.Contains(os.Args[1:], ConfigCheckFlag)
+}
+
+// writeValidatedConfig print the config report of the validated app into stdout and return the exit code.
+func writeValidatedConfig(watcher *ConfigWatcher) int {
+	report, err := watcher.ConfigReport()
+	if err == nil {
+		err = WriteConfigReport(os.Stdout, report)
+	}
+	if err != nil {
+		_, _ = fmt.Fprintf(os.Stderr, "Error writing config report: %v\n", err)
+		return ExitCodeFailure
+	}
+	return 0
+}
+
+// Main run the app using Run, then exit the process with its exit code.
+func Main(opts ...fx.Option) {
+	os.Exit(Run(opts...))
+}
+
+// Run create and run the app like fx.New(opts...).Run(), with graceful shutdown, and return the exit code of the process.
+// On SIGINT, SIGTERM or fx.Shutdowner.Shutdown, the app is marked as draining, see ShutdownCoordinator,
+// then after the drain delay the fx OnStop hooks are run with the shutdown timeout.
+// The env config can register as ShutdownConfig to configure the drain delay and the timeout,
+// otherwise the app is stopped immediately. Shutting down the app again with a non-zero exit code, for example by a
+// second signal when using WithSignalHandling, skip the drain delay, or cancel the context of the OnStop hooks if the
+// app is already stopping, then Run return that exit code.
+// If the app failed, the error is printed into stderr and a non-zero exit code is returned: ExitCodeInvalidConfig,
+// ExitCodeInvalidApp, ExitCodeStartFailure or ExitCodeFailure. Config errors are printed without the fx dependency
+// graph, and sentry is flushed before returning.
+func Run(opts ...fx.Option) int {
+	validateOnly := isValidateOnly(opts)
+	if validateOnly {
+		// Check the dependency graph without running constructors.
+		if err := fx.ValidateApp(fx.Options(opts...), fx.Provide(NewShutdownCoordinator)); err != nil {
+			_, _ = fmt.Fprintf(os.Stderr, "Invalid app:\n%s\n", err)
+			return ExitCodeInvalidApp
+		}
+	}
+
+	state := &runState{}
+	// Output of fx when the custom fx logger cannot be created, for example when the config is invalid.
+	fallback := &fallbackPrinter{}
+	var coordinator *ShutdownCoordinator
+	var watcher *ConfigWatcher
+	populate := []any{&coordinator}
+	if validateOnly {
+		populate = append(populate, &watcher)
+	}
+	app := fx.New(
+		fx.Logger(fallback),
+		fx.Options(opts...),
+		fx.Module("corefx.shutdown",
+			fx.Supply(state),
+			fx.Provide(NewShutdownCoordinator),
+		),
+		fx.Populate(populate...),
+	)
+	defer state.flush()
+	if err := app.Err(); err != nil {
+		// The logger may not be created, write the records logged while the config was loaded.
+		discardDeferredLogs()
+		var configErr *ConfigError
+		if errors.As(err, &configErr) {
+			_, _ = fmt.Fprintf(os.Stderr, "Invalid config:\n%s\n", configErr.Error())
+			return ExitCodeInvalidConfig
+		}
+		fallback.release()
+		return ExitCodeInvalidApp
+	}
+	fallback.release()
+	if validateOnly {
+		return writeValidatedConfig(watcher)
+	}
+
+	startCtx, cancel := context.WithTimeout(context.Background(), app.StartTimeout())
+	defer cancel()
+	if err := app.Start(startCtx); err != nil {
+		return ExitCodeStartFailure
+	}
+
+	wait := app.Wait()
+	sig := <-wait
+	forced := newForcedShutdown()
+	go forced.await(wait)
+	coordinator.drain(forced.drainCtx)
+	forced.skipDrain()
+
+	stopCtx, cancel := context.WithTimeout(forced.stopCtx, coordinator.stopTimeout(app))
+	defer cancel()
+	err := app.Stop(stopCtx)
+	if code := forced.close(); code != 0 {
+		return code
+	}
+	if err != nil {
+		return ExitCodeFailure
+	}
+	return sig.ExitCode
+}
+
+// forcedShutdown skip the drain delay, or cancel the OnStop hooks if already stopping, when the app is shut down again
+// with a non-zero exit code, for example by the second signal of WithSignalHandling.
+// Shutdowns with a zero exit code are ignored, as fx and WithSignalHandling both shut down the app on the first signal.
+type forcedShutdown struct {
+	drainCtx   context.Context
+	skipDrain  context.CancelFunc
+	stopCtx    context.Context
+	cancelStop context.CancelFunc
+	closing    chan struct{}
+	done       chan struct{}
+	// code exit code of the last forced shutdown, read once done is closed.
+	code int
+}
+
+func newForcedShutdown() *forcedShutdown {
+	f := &forcedShutdown{closing: make(chan struct{}), done: make(chan struct{})}
+	f.drainCtx, f.skipDrain = context.WithCancel(context.Background())
+	f.stopCtx, f.cancelStop = context.WithCancel(context.Background())
+	return f
+}
+
+// await the shutdowns sent on wait until close is called.
+func (f *forcedShutdown) await(wait <-chan fx.ShutdownSignal) {
+	defer close(f.done)
+	for {
+		select {
+		case <-f.closing:
+			return
+		case sig := <-wait:
+			if sig.ExitCode == 0 {
+				continue
+			}
+			f.code = sig.ExitCode
+			if f.drainCtx.Err() == nil {
+				f.skipDrain()
+			} else {
+				f.cancelStop()
+			}
+		}
+	}
+}
+
+// close stop waiting and return the exit code of the last forced shutdown, zero if the shutdown was not forced.
+func (f *forcedShutdown) close() int {
+	close(f.closing)
+	<-f.done
+	f.skipDrain()
+	f.cancelStop()
+	return f.code
+}
+
+// flush the events of the sentry hub of the app, if any.
+func (s *runState) flush() {
+	if s.hub != nil && s.hub.Client() != nil {
+		s.hub.Flush(2 * time.Second)
+	}
+}
+
+// fallbackPrinter fx.Printer buffering the output until released, then writing into stderr.
+type fallbackPrinter struct {
+	mu       sync.Mutex
+	buf      bytes.Buffer
+	released bool
+}
+
+func (p *fallbackPrinter) Printf(format string, args ...any) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.released {
+		_, _ = fmt.Fprintf(os.Stderr, format+"\n", args...)
+		return
+	}
+	_, _ = fmt.Fprintf(&p.buf, format+"\n", args...)
+}
+
+// release write the buffered output into stderr, and write the next output directly.
+func (p *fallbackPrinter) release() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, _ = os.Stderr.Write(p.buf.Bytes())
+	p.buf.Reset()
+	p.released = true
+}
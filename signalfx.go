@@ -0,0 +1,113 @@
+package corefx
+
+import (
+	"context"
+	"go.uber.org/fx"
+	"log/slog"
+	"os"
+	"os/signal"
+	"runtime/pprof"
+	"syscall"
+)
+
+type signalHandlerParams struct {
+	fx.In
+	Shutdowner fx.Shutdowner
+	Watcher    *ConfigWatcher
+	Levels     *LevelController
+	Logger     *slog.Logger
+	Report     *LifecycleReport
+	Lifecycle  fx.Lifecycle
+}
+
+// registerSignalHandler handle the OS signals of WithSignalHandling while the app is running.
+func registerSignalHandler(p signalHandlerParams) {
+	signals := []os.Signal{os.Interrupt, syscall.SIGTERM}
+	if reloadSignal != nil {
+		signals = append(signals, reloadSignal)
+	}
+	if debugSignal != nil {
+		signals = append(signals, debugSignal)
+	}
+	ch := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	p.Lifecycle.Append(fx.Hook{
+		OnStart: func(_ context.Context) error {
+			signal.Notify(ch, signals...)
+			go handleSignals(p, ch, done)
+			return nil
+		},
+		OnStop: func(_ context.Context) error {
+			// Keep handling signals until the other OnStop hooks are done.
+			p.Report.onStopped(func() {
+				signal.Stop(ch)
+				close(done)
+			})
+			return nil
+		},
+	})
+}
+
+func handleSignals(p signalHandlerParams, ch <-chan os.Signal, done <-chan struct{}) {
+	shuttingDown := false
+	// previous level restored when debug logging is toggled off.
+	previous := p.Levels.Level()
+	for {
+		var sig os.Signal
+		select {
+		case <-done:
+			return
+		case sig = <-ch:
+		}
+		switch {
+		case sig == reloadSignal:
+			if err := p.Watcher.Reload(); err != nil {
+				p.Logger.Error("Error reloading config", slog.String("signal", sig.String()), slog.Any("err", err))
+				continue
+			}
+			p.Logger.Info("Config reloaded", slog.String("signal", sig.String()))
+		case sig == debugSignal:
+			_ = pprof.Lookup("goroutine").WriteTo(os.Stderr, 2)
+			if p.Levels.Level() > slog.LevelDebug {
+				previous = p.Levels.Level()
+				p.Levels.SetLevel(slog.LevelDebug)
+			} else {
+				p.Levels.SetLevel(previous)
+			}
+		case shuttingDown:
+			// The app is still draining or stopping, Run skip the drain delay, or cancel the OnStop hooks, when shut
+			// down again with a non-zero exit code. The graceful shutdown exit with code 0, like fx.
+			p.Logger.Warn("Received signal during shutdown, forcing shutdown", slog.String("signal", sig.String()))
+			// Other receivers of the first shutdown are not drained, the error only report them.
+			_ = p.Shutdowner.Shutdown(fx.ExitCode(exitCodeOf(sig)))
+		default:
+			shuttingDown = true
+			p.Logger.Info("Received signal, shutting down", slog.String("signal", sig.String()))
+			if err := p.Shutdowner.Shutdown(); err != nil {
+				p.Logger.Error("Error shutting down", slog.Any("err", err))
+			}
+		}
+	}
+}
+
+// exitCodeOf return the conventional exit code of a process terminated by sig.
+func exitCodeOf(sig os.Signal) int {
+	if s, ok := sig.(syscall.Signal); ok {
+		return 128 + int(s)
+	}
+	return 1
+}
+
+// WithSignalHandling handle OS signals while the app is running:
+//   - SIGINT and SIGTERM shutdown the app using fx.Shutdowner. A second signal during the shutdown shut down the app
+//     again with the exit code of the signal, which make Run skip the drain delay, or cancel the OnStop hooks.
+//     Signals are handled until the app stopped, so the process is never killed before the OnStop hooks are done.
+//   - SIGHUP reload the config, see ConfigWatcher.Reload.
+//   - SIGUSR1 dump the stack of goroutines into stderr, and toggle the debug log level.
+//
+// Must be used together with NewModule.
+func WithSignalHandling() fx.Option {
+	return fx.Module("corefx.signal",
+		fx.Invoke(registerSignalHandler),
+	)
+}
@@ -0,0 +1,14 @@
+//go:build !unix
+
+package corefx
+
+import (
+	"os"
+)
+
+var (
+	// reloadSignal not supported on this platform.
+	reloadSignal os.Signal
+	// debugSignal not supported on this platform.
+	debugSignal os.Signal
+)
@@ -0,0 +1,15 @@
+//go:build unix
+
+package corefx
+
+import (
+	"os"
+	"syscall"
+)
+
+var (
+	// reloadSignal signal reloading the config, see WithSignalHandling.
+	reloadSignal os.Signal = syscall.SIGHUP
+	// debugSignal signal toggling the debug log level, see WithSignalHandling.
+	debugSignal os.Signal = syscall.SIGUSR1
+)
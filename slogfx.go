@@ -8,6 +8,7 @@
 	slogsentry "github.com/samber/slog-sentry/v2"
 	"go.uber.org/fx"
 	"go.uber.org/fx/fxevent"
+	"io"
 	"log/slog"
 	"os"
 	"strings"
@@ -22,31 +23,117 @@ func init() {
 }
 
 // UseSlogLogger configure fx to use slog.Default logger.
+// Provide, decorate, invoke and hook events are logged at debug level, app start and stop events at info level.
+// When used with NewModule, events logged before the config is loaded are buffered and written using the configured
+// logger, so they are written into testing.T when using the corefxtest module.
 func UseSlogLogger() fx.Option {
-	return fx.WithLogger(func() fxevent.Logger {
-		return &fxevent.SlogLogger{Logger: slog.Default()}
+	return fx.WithLogger(func(p slogEventLoggerParams) fxevent.Logger {
+		return newFxEventLogger(slog.Default(), p.Report, bool(p.Summary))
 	})
 }
 
-// newSlogLogger create a logger instance.
-func newSlogLogger(p SlogLoggerParams) (*slog.Logger, error) {
-	level := parseLogLevel(p.Config.LogLevelValue())
-	if p.Config.ProfileValue() == ProfileDebug {
-		level = slog.LevelDebug
+type slogEventLoggerParams struct {
+	fx.In
+	// Report provided by NewModule.
+	Report  *LifecycleReport  `optional:"true"`
+	Summary startupReportFlag `optional:"true"`
+}
+
+// LogSourceConfig optional interface that a CoreConfig can implement to add the source (file:line) of the log
+// statement to every record.
+type LogSourceConfig interface {
+	// LogSourceValue whether to add source attribute to log records.
+	LogSourceValue() bool
+}
+
+// newSlogLogger create a logger instance, whose handlers filter records using level.
+// Every record carries the app, version and profile attributes of the config, if set.
+// If sentry is enabled, records are reported using hub, whose client is initialized.
+func newSlogLogger(p SlogLoggerParams, level slog.Leveler, hub *sentry.Hub) (*slog.Logger, error) {
+	addSource := false
+	if c, ok := p.Config.(LogSourceConfig); ok {
+		addSource = c.LogSourceValue()
+	}
+	var redact LogMiddleware
+	if c, ok := p.Config.(LogRedactConfig); ok {
+		var err error
+		if redact, err = NewRedactMiddleware(c.LogRedactValue()); err != nil {
+			return nil, err
+		}
 	}
 
 	logFormat := p.Config.LogFormatValue()
 	if logFormat == "" && p.Config.ProfileValue() == ProfileProduction {
 		logFormat = "json"
 	}
+	output, isFile := "", false
+	if c, ok := p.Config.(LogOutputConfig); ok {
+		output = c.LogOutputValue()
+		isFile = output != "" && output != LogOutputStdout && output != LogOutputStderr && output != LogOutputDiscard
+	}
+	var w io.Writer = os.Stdout
+	switch {
+	case output == LogOutputStdout:
+	case output == LogOutputStderr || output == "" && !isStructuredLogFormat(logFormat):
+		w = os.Stderr
+	case output == LogOutputDiscard:
+		w = io.Discard
+	case isFile:
+		if _, ok := p.Config.(LogFileConfig); !ok {
+			file := &rotatingFile{path: output}
+			p.Lifecycle.Append(fx.Hook{
+				OnStop: func(_ context.Context) error {
+					return file.Close()
+				},
+			})
+			w = file
+		}
+	}
 	var handler slog.Handler
-	if logFormat == "json" {
-		handler = slog.NewJSONHandler(os.Stdout, &slog.HandlerOptions{Level: level})
-	} else {
-		handler = console.NewHandler(os.Stderr, &console.HandlerOptions{Level: level})
+	switch {
+	case p.Output.Handler != nil:
+		handler = &levelHandler{Handler: p.Output.Handler, level: level}
+	case logFormat == "json":
+		handler = slog.NewJSONHandler(w, &slog.HandlerOptions{Level: level, AddSource: addSource})
+	case logFormat == "gcp":
+		handler = newGCPHandler(w, level, gcpProjectID())
+	case logFormat == "ecs":
+		handler = newECSHandler(w, level, addSource)
+	case logFormat == "logfmt":
+		handler = slog.NewTextHandler(w, &slog.HandlerOptions{Level: level, AddSource: addSource})
+	default:
+		handler = console.NewHandler(w, &console.HandlerOptions{Level: level, AddSource: addSource, NoColor: w != os.Stderr && w != os.Stdout})
+	}
+	handlers := []slog.Handler{handler}
+	if output == LogOutputDiscard && p.Output.Handler == nil {
+		handlers = nil
+	}
+	if c, ok := p.Config.(LogFileConfig); ok && isFile {
+		file := newRotatingFile(c)
+		p.Lifecycle.Append(fx.Hook{
+			OnStop: func(_ context.Context) error {
+				return file.Close()
+			},
+		})
+		handlers = append(handlers, slog.NewJSONHandler(file, &slog.HandlerOptions{Level: level, AddSource: addSource}))
 	}
-	if p.LogConfig == nil || p.LogConfig.SentryDsnValue() == "" {
-		return slog.New(handler), nil
+	if c, ok := p.Config.(SystemLogConfig); ok {
+		systemHandlers, err := systemLogHandlersOf(c, p.Config.AppNameValue(), p.Lifecycle)
+		if err != nil {
+			return nil, err
+		}
+		for _, h := range systemHandlers {
+			handlers = append(handlers, &levelHandler{Handler: h, level: level})
+		}
+	}
+	for _, h := range p.Handlers {
+		handlers = append(handlers, &levelHandler{Handler: h, level: level})
+	}
+	if len(handlers) != 1 {
+		handler = slogmulti.Fanout(handlers...)
+	}
+	if !p.Sentry || p.LogConfig == nil || p.LogConfig.SentryDsnValue() == "" {
+		return loggerOf(p, redact, handler), nil
 	}
 	// Setup sentry.
 	environment := ProfileDevelopment
@@ -60,18 +147,31 @@ func newSlogLogger(p SlogLoggerParams) (*slog.Logger, error) {
 	if p.Config.AppVersionValue() != "" {
 		release += "@" + p.Config.AppVersionValue()
 	}
-	err := sentry.Init(sentry.ClientOptions{
+	options := sentry.ClientOptions{
 		Dsn:           p.LogConfig.SentryDsnValue(),
 		EnableTracing: false,
 		Environment:   environment,
 		Release:       release,
-	})
+	}
+	if advanced, ok := p.LogConfig.(SentryAdvancedConfig); ok {
+		options.SampleRate = advanced.SentrySampleRateValue()
+		options.TracesSampleRate = advanced.SentryTracesSampleRateValue()
+		options.EnableTracing = options.TracesSampleRate > 0
+		options.ProfilesSampleRate = advanced.SentryProfilesSampleRateValue()
+		options.AttachStacktrace = advanced.SentryAttachStacktraceValue()
+		options.ServerName = advanced.SentryServerNameValue()
+	}
+	for _, customize := range p.SentryCustomizers {
+		customize(&options)
+	}
+	client, err := sentry.NewClient(options)
 	if err != nil {
 		return nil, err
 	}
+	hub.BindClient(client)
 	p.Lifecycle.Append(fx.Hook{
 		OnStop: func(_ context.Context) error {
-			sentry.Flush(5 * time.Second)
+			hub.Flush(5 * time.Second)
 			return nil
 		},
 	})
@@ -80,12 +180,67 @@ func newSlogLogger(p SlogLoggerParams) (*slog.Logger, error) {
 	if p.LogConfig.SentryLogLevelValue() != "" {
 		sentryLogLevel = parseLogLevel(p.LogConfig.SentryLogLevelValue())
 	}
-	return slog.New(
-		slogmulti.Fanout(
-			handler,
-			slogsentry.Option{Level: sentryLogLevel}.NewSentryHandler(),
-		),
-	), nil
+	var tags map[string]string
+	if c, ok := p.LogConfig.(SentryTagsConfig); ok {
+		tags = c.SentryTagsValue()
+	}
+	return loggerOf(p, redact, slogmulti.Fanout(
+		handler,
+		slogsentry.Option{
+			Level:     sentryLogLevel,
+			Hub:       hub,
+			Converter: sentryConverterOf(tags),
+			AddSource: addSource,
+		}.NewSentryHandler(),
+	)), nil
+}
+
+// loggerOf create a logger using handler, with log sampling and app attributes of config.
+// Attributes extracted from the context of records are added, see ContextAttrsFunc, then records pass through the
+// registered LogMiddleware, and are redacted last if redact is not nil.
+func loggerOf(p SlogLoggerParams, redact LogMiddleware, handler slog.Handler) *slog.Logger {
+	cfg := p.Config
+	if redact != nil {
+		handler = redact(handler)
+	}
+	for i := len(p.Middlewares) - 1; i >= 0; i-- {
+		if p.Middlewares[i] != nil {
+			handler = p.Middlewares[i](handler)
+		}
+	}
+	handler = newContextHandler(handler, p.ContextAttrs)
+	if c, ok := cfg.(LogSampleConfig); ok && c.LogSampleLimitValue() > 0 && c.LogSampleIntervalValue() > 0 {
+		handler = newSamplingHandler(handler, c.LogSampleLimitValue(), c.LogSampleIntervalValue())
+	}
+	return slog.New(handler).With(appAttrsOf(cfg)...)
+}
+
+// isStructuredLogFormat whether logs of format are written to stdout by default, instead of the stderr console.
+func isStructuredLogFormat(format string) bool {
+	return format != "" && format != "text"
+}
+
+// appAttrsOf return the app, version and profile attributes of config, empty values are omitted.
+func appAttrsOf(cfg CoreConfig) []any {
+	attrs := make([]any, 0, 3)
+	if cfg.AppNameValue() != "" {
+		attrs = append(attrs, slog.String("app", cfg.AppNameValue()))
+	}
+	if cfg.AppVersionValue() != "" {
+		attrs = append(attrs, slog.String("version", cfg.AppVersionValue()))
+	}
+	if cfg.ProfileValue() != "" {
+		attrs = append(attrs, slog.String("profile", cfg.ProfileValue()))
+	}
+	return attrs
+}
+
+// logLevelOf return the log level of config, debug profile always use debug level.
+func logLevelOf(cfg CoreConfig) slog.Level {
+	if cfg.ProfileValue() == ProfileDebug {
+		return slog.LevelDebug
+	}
+	return parseLogLevel(cfg.LogLevelValue())
 }
 
 func parseLogLevel(level string) slog.Level {
@@ -103,19 +258,101 @@ func parseLogLevel(level string) slog.Level {
 
 type SlogLoggerParams struct {
 	fx.In
-	Config    CoreConfig
-	LogConfig SentryConfig `optional:"true"`
-	Lifecycle fx.Lifecycle
+	Config            CoreConfig
+	LogConfig         SentryConfig `optional:"true"`
+	Lifecycle         fx.Lifecycle
+	Watcher           *ConfigWatcher            `optional:"true"`
+	Handlers          []slog.Handler            `group:"log_handlers"`
+	SentryCustomizers []SentryOptionsCustomizer `group:"sentry_options_customizers"`
+	ContextAttrs      []ContextAttrsFunc        `group:"log_context_attrs"`
+	Middlewares       []LogMiddleware           `group:"slog_middleware"`
+	Output            logOutput                 `optional:"true"`
+	Sentry            sentryFlag                `optional:"true"`
+	Local             localLoggerFlag           `optional:"true"`
+	Run               *runState                 `optional:"true"`
+}
+
+// logOutput handler registered using WithLogOutput.
+type logOutput struct {
+	slog.Handler
+}
+
+// WithLogOutput write logs into handler instead of stdout or stderr, the log format of the config is ignored.
+// Must be used together with NewModule.
+// Records are still filtered by the log levels of the config, and written into files, system logger and sentry if
+// configured.
+func WithLogOutput(handler slog.Handler) fx.Option {
+	return fx.Supply(logOutput{Handler: handler})
+}
+
+// levelHandler filter records of a handler using a log level.
+type levelHandler struct {
+	slog.Handler
+	level slog.Leveler
+}
+
+func (h *levelHandler) Enabled(ctx context.Context, l slog.Level) bool {
+	return l >= h.level.Level() && h.Handler.Enabled(ctx, l)
 }
 
-// NewGlobalSlogLogger create a logger instance and register it globally.
+func (h *levelHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &levelHandler{Handler: h.Handler.WithAttrs(attrs), level: h.level}
+}
+
+func (h *levelHandler) WithGroup(name string) slog.Handler {
+	return &levelHandler{Handler: h.Handler.WithGroup(name), level: h.level}
+}
+
+// NewGlobalSlogLogger create a logger instance and register it globally, unless WithLocalLogger is used.
+// Named loggers can be created using LoggerFor.
+// When the config is reloaded, the log levels are re-applied automatically.
 func NewGlobalSlogLogger(p SlogLoggerParams) (*slog.Logger, error) {
-	logger, err := newSlogLogger(p)
+	loggers, err := newSlogLoggers(p)
+	return loggers.Logger, err
+}
+
+type slogLoggers struct {
+	fx.Out
+	Logger *slog.Logger
+	Levels *LevelController
+	// Sentry hub of the app, without client if sentry is not enabled.
+	Sentry *sentry.Hub
+}
+
+// newSlogLoggers create the logger, the controller of its levels and the sentry hub.
+// Unless WithLocalLogger is used, the logger is registered as slog.Default, its levels are used by LoggerFor and
+// sentry is initialized using the global hub.
+func newSlogLoggers(p SlogLoggerParams) (slogLoggers, error) {
+	hub := sentry.CurrentHub()
+	if p.Local {
+		hub = sentry.NewHub(nil, sentry.NewScope())
+	}
+	if p.Run != nil {
+		p.Run.hub = hub
+	}
+	levels := newLogLevels()
+	levels.set(logLevelOf(p.Config), logLevelsOf(p.Config))
+	// Handlers accept the lowest level of all loggers, the global logger filter its own level.
+	base, err := newSlogLogger(p, levels.min, hub)
 	if err != nil {
-		return nil, err
+		discardDeferredLogs()
+		return slogLoggers{}, err
 	}
+	levels.base = base.Handler()
+	logger := slog.New(&levelHandler{Handler: levels.base, level: levels.global})
+	if p.Watcher != nil {
+		p.Watcher.OnConfigChange(func(_ CoreConfig, cfg CoreConfig) {
+			levels.set(logLevelOf(cfg), logLevelsOf(cfg))
+		})
+	}
+	loggers := slogLoggers{Logger: logger, Levels: &LevelController{levels: levels, logger: logger}, Sentry: hub}
+	if p.Local {
+		return loggers, nil
+	}
+	globalLogLevels.Store(levels)
 	slog.SetDefault(logger)
-	return logger, nil
+	replayDeferredLogs(logger.Handler())
+	return loggers, nil
 }
 
 type SentryConfig interface {
@@ -124,7 +361,7 @@ type SentryConfig interface {
 }
 
 type SentryEnv struct {
-	SentryDsn      string `json:"sentry_dsn" mapstructure:"sentry_dsn"`
+	SentryDsn      string `json:"sentry_dsn" mapstructure:"sentry_dsn" secret:"true"`
 	SentryLogLevel string `json:"sentry_log_level" mapstructure:"sentry_log_level"`
 }
 
@@ -137,3 +374,102 @@ func (e SentryEnv) SentryLogLevelValue() string {
 }
 
 var _ SentryConfig = (*SentryEnv)(nil)
+
+// LogMiddleware wrap the handler of the logger, for example to add attributes, redact values or route records.
+// Middlewares receive every record before it is written into stdout, files, sentry and other handlers, in the order of
+// the group, so the first middleware receive records first.
+type LogMiddleware func(next slog.Handler) slog.Handler
+
+// AsLogMiddleware annotate a LogMiddleware constructor to register it into the slog middleware group.
+// For example, fx.Provide(corefx.AsLogMiddleware(newTenantMiddleware)).
+func AsLogMiddleware(f any) any {
+	return fx.Annotate(
+		f,
+		fx.ResultTags(`group:"slog_middleware"`),
+	)
+}
+
+// SentryOptionsCustomizer customize the sentry client options before sentry is initialized,
+// for example to set BeforeSend filters, transport, debug mode or integrations.
+type SentryOptionsCustomizer func(options *sentry.ClientOptions)
+
+// AsSentryOptionsCustomizer annotate a SentryOptionsCustomizer constructor to register it into the customizer group.
+// For example, fx.Provide(corefx.AsSentryOptionsCustomizer(newBeforeSendFilter)).
+func AsSentryOptionsCustomizer(f any) any {
+	return fx.Annotate(
+		f,
+		fx.ResultTags(`group:"sentry_options_customizers"`),
+	)
+}
+
+// SentryAdvancedConfig optional interface that a SentryConfig can implement to configure sampling,
+// performance monitoring and other sentry client options.
+type SentryAdvancedConfig interface {
+	// SentrySampleRateValue sample rate of error events, in range [0.0, 1.0]. Zero means 1.0.
+	SentrySampleRateValue() float64
+	// SentryTracesSampleRateValue sample rate of transactions, in range [0.0, 1.0].
+	// Tracing is enabled if greater than zero.
+	SentryTracesSampleRateValue() float64
+	// SentryProfilesSampleRateValue sample rate of profiles, relative to the traces sample rate.
+	SentryProfilesSampleRateValue() float64
+	// SentryAttachStacktraceValue attach stacktrace to events that are not errors, such as messages.
+	SentryAttachStacktraceValue() bool
+	// SentryServerNameValue server name reported to sentry, default to the hostname if empty.
+	SentryServerNameValue() string
+}
+
+type SentryAdvancedEnv struct {
+	SentrySampleRate         float64 `json:"sentry_sample_rate" mapstructure:"sentry_sample_rate"`
+	SentryTracesSampleRate   float64 `json:"sentry_traces_sample_rate" mapstructure:"sentry_traces_sample_rate"`
+	SentryProfilesSampleRate float64 `json:"sentry_profiles_sample_rate" mapstructure:"sentry_profiles_sample_rate"`
+	SentryAttachStacktrace   bool    `json:"sentry_attach_stacktrace" mapstructure:"sentry_attach_stacktrace"`
+	SentryServerName         string  `json:"sentry_server_name" mapstructure:"sentry_server_name"`
+}
+
+func (e SentryAdvancedEnv) SentrySampleRateValue() float64 {
+	return e.SentrySampleRate
+}
+
+func (e SentryAdvancedEnv) SentryTracesSampleRateValue() float64 {
+	return e.SentryTracesSampleRate
+}
+
+func (e SentryAdvancedEnv) SentryProfilesSampleRateValue() float64 {
+	return e.SentryProfilesSampleRate
+}
+
+func (e SentryAdvancedEnv) SentryAttachStacktraceValue() bool {
+	return e.SentryAttachStacktrace
+}
+
+func (e SentryAdvancedEnv) SentryServerNameValue() string {
+	return e.SentryServerName
+}
+
+var _ SentryAdvancedConfig = (*SentryAdvancedEnv)(nil)
+
+// sentryFlag supplied by WithSentry.
+type sentryFlag bool
+
+// WithSentry initialize sentry and send log records to it, if the env config register as SentryConfig with a dsn.
+// Must be used together with New, NewModule already enable sentry.
+func WithSentry() fx.Option {
+	return fx.Supply(sentryFlag(true))
+}
+
+// localLoggerFlag supplied by WithLocalLogger.
+type localLoggerFlag bool
+
+// WithLocalLogger provide the logger using fx only, slog.Default, the levels of LoggerFor and the global sentry hub are
+// left untouched, so multiple apps, or tests, can each have their own logger.
+// Named loggers of the app can be created using LevelController.Logger, and the sentry hub of the app is injectable.
+// Records logged using slog.Default are written by it, even while the config is loaded.
+// Must be used together with New or NewModule.
+func WithLocalLogger() fx.Option {
+	return fx.Supply(localLoggerFlag(true))
+}
+
+// WithoutGlobalLogger alias of WithLocalLogger.
+func WithoutGlobalLogger() fx.Option {
+	return WithLocalLogger()
+}
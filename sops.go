@@ -0,0 +1,378 @@
+package corefx
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gopkg.in/yaml.v3"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sopsMetadataKey key of the metadata of SOPS encrypted config files.
+const sopsMetadataKey = "sops"
+
+// sopsValuePattern value encrypted by SOPS.
+var sopsValuePattern = regexp.MustCompile(`^ENC\[AES256_GCM,data:([^,]*),iv:([^,]*),tag:([^,]*),type:([^,\]]*)]$`)
+
+// isAgeEncrypted whether data is an age encrypted file, in binary or armored format.
+func isAgeEncrypted(data []byte) bool {
+	return bytes.HasPrefix(data, []byte("age-encryption.org/")) ||
+		bytes.HasPrefix(bytes.TrimSpace(data), []byte("-----BEGIN AGE ENCRYPTED FILE-----"))
+}
+
+// decryptSOPSConfig decrypt the content of a SOPS encrypted JSON or YAML config file into its settings.
+// The data key is decrypted using the HashiCorp Vault transit engine recorded in the metadata of the file,
+// authenticated by the VAULT_TOKEN env. The MAC of the file is verified, so the file fail to load if values were
+// added, removed or changed without sops. Age, KMS and PGP keys are not supported.
+func decryptSOPSConfig(path string, configType string, data []byte) (map[string]any, error) {
+	var settings map[string]any
+	var err error
+	if configType == "yaml" {
+		err = yaml.Unmarshal(data, &settings)
+	} else {
+		err = json.Unmarshal(data, &settings)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error reading sops config file [%s]: %w", path, err)
+	}
+	metadata, ok := settings[sopsMetadataKey].(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("error reading sops config file [%s]: missing sops metadata", path)
+	}
+	delete(settings, sopsMetadataKey)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	key, err := sopsDataKeyOf(ctx, metadata)
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting sops config file [%s]: %w", path, err)
+	}
+	leaves, err := sopsLeavesOf(configType, data)
+	if err != nil {
+		return nil, fmt.Errorf("error reading sops config file [%s]: %w", path, err)
+	}
+	if err := verifySOPSMAC(key, metadata, leaves); err != nil {
+		return nil, fmt.Errorf("error verifying sops config file [%s]: %w", path, err)
+	}
+	decrypted, err := decryptSOPSValue(key, settings, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error decrypting sops config file [%s]: %w", path, err)
+	}
+	return decrypted.(map[string]any), nil
+}
+
+// sopsDataKeyOf decrypt the data key of a SOPS file using one of its hc_vault key.
+func sopsDataKeyOf(ctx context.Context, metadata map[string]any) ([]byte, error) {
+	vaultKeys, _ := metadata["hc_vault"].([]any)
+	if len(vaultKeys) == 0 {
+		var types []string
+		for name, value := range metadata {
+			if keys, ok := value.([]any); ok && len(keys) > 0 {
+				types = append(types, name)
+			}
+		}
+		sort.Strings(types)
+		return nil, fmt.Errorf("error unsupported sops key types [%s], only hc_vault is supported, "+
+			"age keys of SOPS_AGE_KEY or SOPS_AGE_KEY_FILE, KMS and PGP keys cannot be used", strings.Join(types, ", "))
+	}
+	token := os.Getenv("VAULT_TOKEN")
+	if token == "" {
+		return nil, errors.New("error VAULT_TOKEN env is not set")
+	}
+	var errs []error
+	for _, vaultKey := range vaultKeys {
+		k, _ := vaultKey.(map[string]any)
+		addr, _ := k["vault_address"].(string)
+		if env := os.Getenv("VAULT_ADDR"); env != "" {
+			addr = env
+		}
+		enginePath, _ := k["engine_path"].(string)
+		keyName, _ := k["key_name"].(string)
+		enc, _ := k["enc"].(string)
+		key, err := decryptVaultTransit(ctx, addr, token, enginePath, keyName, enc)
+		if err == nil {
+			return key, nil
+		}
+		errs = append(errs, err)
+	}
+	return nil, errors.Join(errs...)
+}
+
+// decryptVaultTransit decrypt ciphertext using the transit engine of vault.
+func decryptVaultTransit(ctx context.Context, addr string, token string, enginePath string, keyName string,
+	ciphertext string) ([]byte, error) {
+	body, err := json.Marshal(map[string]string{"ciphertext": ciphertext})
+	if err != nil {
+		return nil, err
+	}
+	url := strings.TrimSuffix(addr, "/") + "/v1/" + strings.Trim(enginePath, "/") + "/decrypt/" + keyName
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Vault-Token", token)
+	req.Header.Set("Content-Type", "application/json")
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("error decrypting sops data key using vault [%s]: status %d", url, res.StatusCode)
+	}
+	var payload struct {
+		Data struct {
+			Plaintext string `json:"plaintext"`
+		} `json:"data"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&payload); err != nil {
+		return nil, err
+	}
+	return base64.StdEncoding.DecodeString(payload.Data.Plaintext)
+}
+
+// sopsLeaf a value of a SOPS file, whose keys from the root are path.
+type sopsLeaf struct {
+	path  []string
+	value any
+}
+
+// sopsLeavesOf return the values of a SOPS file in the order of the file, which is the order the MAC is computed in,
+// without the sops metadata.
+func sopsLeavesOf(configType string, data []byte) ([]sopsLeaf, error) {
+	var leaves []sopsLeaf
+	if configType == "yaml" {
+		var doc yaml.Node
+		if err := yaml.Unmarshal(data, &doc); err != nil {
+			return nil, err
+		}
+		if len(doc.Content) == 0 {
+			return nil, nil
+		}
+		return leaves, yamlSOPSLeaves(doc.Content[0], nil, true, &leaves)
+	}
+	return leaves, jsonSOPSLeaves(json.NewDecoder(bytes.NewReader(data)), nil, true, &leaves)
+}
+
+func yamlSOPSLeaves(node *yaml.Node, path []string, root bool, leaves *[]sopsLeaf) error {
+	switch node.Kind {
+	case yaml.AliasNode:
+		return yamlSOPSLeaves(node.Alias, path, false, leaves)
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key := node.Content[i].Value
+			if root && key == sopsMetadataKey {
+				continue
+			}
+			if err := yamlSOPSLeaves(node.Content[i+1], append(path[:len(path):len(path)], key), false, leaves); err != nil {
+				return err
+			}
+		}
+	case yaml.SequenceNode:
+		// Items of lists are authenticated using the path of the list.
+		for _, child := range node.Content {
+			if err := yamlSOPSLeaves(child, path, false, leaves); err != nil {
+				return err
+			}
+		}
+	case yaml.ScalarNode:
+		var value any
+		if err := node.Decode(&value); err != nil {
+			return err
+		}
+		*leaves = append(*leaves, sopsLeaf{path: path, value: value})
+	}
+	return nil
+}
+
+func jsonSOPSLeaves(dec *json.Decoder, path []string, root bool, leaves *[]sopsLeaf) error {
+	token, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	switch token {
+	case json.Delim('{'):
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return err
+			}
+			name, _ := key.(string)
+			if root && name == sopsMetadataKey {
+				var metadata json.RawMessage
+				if err := dec.Decode(&metadata); err != nil {
+					return err
+				}
+				continue
+			}
+			if err := jsonSOPSLeaves(dec, append(path[:len(path):len(path)], name), false, leaves); err != nil {
+				return err
+			}
+		}
+	case json.Delim('['):
+		for dec.More() {
+			if err := jsonSOPSLeaves(dec, path, false, leaves); err != nil {
+				return err
+			}
+		}
+	default:
+		*leaves = append(*leaves, sopsLeaf{path: path, value: token})
+		return nil
+	}
+	// Consume the closing delimiter.
+	_, err = dec.Token()
+	return err
+}
+
+// verifySOPSMAC verify the MAC recorded in the metadata of a SOPS file, which is the SHA-512 of its values in order,
+// encrypted using the data key.
+func verifySOPSMAC(key []byte, metadata map[string]any, leaves []sopsLeaf) error {
+	encryptedMAC, _ := metadata["mac"].(string)
+	match := sopsValuePattern.FindStringSubmatch(encryptedMAC)
+	if match == nil {
+		return errors.New("error missing sops mac")
+	}
+	var lastModified time.Time
+	switch v := metadata["lastmodified"].(type) {
+	case time.Time:
+		lastModified = v
+	case string:
+		var err error
+		if lastModified, err = time.Parse(time.RFC3339, v); err != nil {
+			return fmt.Errorf("error invalid sops lastmodified [%s]: %w", v, err)
+		}
+	default:
+		return errors.New("error missing sops lastmodified")
+	}
+	mac, err := decryptSOPSString(key, match[1], match[2], match[3], lastModified.Format(time.RFC3339))
+	if err != nil {
+		return fmt.Errorf("error decrypting sops mac: %w", err)
+	}
+
+	onlyEncrypted, _ := metadata["mac_only_encrypted"].(bool)
+	hash := sha512.New()
+	for _, leaf := range leaves {
+		value, _ := leaf.value.(string)
+		if match := sopsValuePattern.FindStringSubmatch(value); match != nil {
+			plaintext, err := decryptSOPSString(key, match[1], match[2], match[3], strings.Join(leaf.path, ":")+":")
+			if err != nil {
+				return fmt.Errorf("error decrypting value of [%s]: %w", strings.Join(leaf.path, "."), err)
+			}
+			hash.Write([]byte(plaintext))
+		} else if !onlyEncrypted {
+			hash.Write(sopsBytesOf(leaf.value))
+		}
+	}
+	if fmt.Sprintf("%X", hash.Sum(nil)) != mac {
+		return errors.New("error sops mac mismatch, the file was modified without sops")
+	}
+	return nil
+}
+
+// sopsBytesOf return the bytes of an unencrypted value hashed into the MAC, formatted like sops.
+func sopsBytesOf(value any) []byte {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case string:
+		return []byte(v)
+	case int:
+		return []byte(strconv.Itoa(v))
+	case float64:
+		return []byte(strconv.FormatFloat(v, 'f', -1, 64))
+	case bool:
+		if v {
+			return []byte("True")
+		}
+		return []byte("False")
+	default:
+		return []byte(fmt.Sprint(v))
+	}
+}
+
+// decryptSOPSValue decrypt the encrypted values of value, whose keys from the root are path.
+func decryptSOPSValue(key []byte, value any, path []string) (any, error) {
+	switch v := value.(type) {
+	case map[string]any:
+		for k, child := range v {
+			decrypted, err := decryptSOPSValue(key, child, append(path[:len(path):len(path)], k))
+			if err != nil {
+				return nil, err
+			}
+			v[k] = decrypted
+		}
+		return v, nil
+	case []any:
+		// Items of lists are authenticated using the path of the list.
+		for i, child := range v {
+			decrypted, err := decryptSOPSValue(key, child, path)
+			if err != nil {
+				return nil, err
+			}
+			v[i] = decrypted
+		}
+		return v, nil
+	case string:
+		match := sopsValuePattern.FindStringSubmatch(v)
+		if match == nil {
+			return v, nil
+		}
+		plaintext, err := decryptSOPSString(key, match[1], match[2], match[3], strings.Join(path, ":")+":")
+		if err != nil {
+			return nil, fmt.Errorf("error decrypting value of [%s]: %w", strings.Join(path, "."), err)
+		}
+		switch match[4] {
+		case "int":
+			return strconv.Atoi(plaintext)
+		case "float":
+			return strconv.ParseFloat(plaintext, 64)
+		case "bool":
+			return strconv.ParseBool(plaintext)
+		default:
+			return plaintext, nil
+		}
+	default:
+		return v, nil
+	}
+}
+
+// decryptSOPSString decrypt a value encrypted using AES256_GCM, authenticated with the additional data aad.
+func decryptSOPSString(key []byte, data string, iv string, tag string, aad string) (string, error) {
+	ciphertext, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return "", err
+	}
+	nonce, err := base64.StdEncoding.DecodeString(iv)
+	if err != nil {
+		return "", err
+	}
+	authTag, err := base64.StdEncoding.DecodeString(tag)
+	if err != nil {
+		return "", err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return "", err
+	}
+	gcm, err := cipher.NewGCMWithNonceSize(block, len(nonce))
+	if err != nil {
+		return "", err
+	}
+	plaintext, err := gcm.Open(nil, nonce, append(ciphertext, authTag...), []byte(aad))
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
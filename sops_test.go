@@ -0,0 +1,158 @@
+package corefx
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// sopsTestDataKey data key of the files of testdata/sops, returned by the vault of newSOPSTestVault.
+// The files are encrypted in the format of sops 3.8 using a hc_vault key, tampered.enc.json has a value changed after
+// encryption.
+var sopsTestDataKey = []byte("corefx-sops-test-data-key-32byte")
+
+// newSOPSTestVault start a vault transit engine decrypting the data key of the files of testdata/sops.
+func newSOPSTestVault(t *testing.T) {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct {
+			Ciphertext string `json:"ciphertext"`
+		}
+		if r.URL.Path != "/v1/sops/decrypt/app" || r.Header.Get("X-Vault-Token") != "test-token" ||
+			json.NewDecoder(r.Body).Decode(&body) != nil || body.Ciphertext != "vault:v1:test" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{
+			"data": map[string]string{"plaintext": base64.StdEncoding.EncodeToString(sopsTestDataKey)},
+		})
+	}))
+	t.Cleanup(server.Close)
+	t.Setenv("VAULT_ADDR", server.URL)
+	t.Setenv("VAULT_TOKEN", "test-token")
+}
+
+func TestReadSOPSConfigFile(t *testing.T) {
+	newSOPSTestVault(t)
+	want := map[string]any{
+		"db":               map[string]any{"password": "s3cr3t", "port": 5432},
+		"hosts":            []any{"a.local", "b.local"},
+		"debug":            true,
+		"name_unencrypted": "demo",
+	}
+	for _, path := range []string{"testdata/sops/app.enc.json", "testdata/sops/app.enc.yaml"} {
+		t.Run(path, func(t *testing.T) {
+			settings, err := readConfigFileSettings(path)
+			if err != nil {
+				t.Fatalf("readConfigFileSettings() error = %v", err)
+			}
+			if !reflect.DeepEqual(settings, want) {
+				t.Errorf("readConfigFileSettings() = %v, want %v", settings, want)
+			}
+		})
+	}
+}
+
+func TestReadSOPSConfigFileTampered(t *testing.T) {
+	newSOPSTestVault(t)
+	_, err := readConfigFileSettings("testdata/sops/tampered.enc.json")
+	if err == nil || !strings.Contains(err.Error(), "sops mac mismatch") {
+		t.Errorf("readConfigFileSettings() error = %v, want mac mismatch", err)
+	}
+}
+
+func TestReadSOPSConfigFileModified(t *testing.T) {
+	newSOPSTestVault(t)
+	b, err := os.ReadFile("testdata/sops/app.enc.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data := string(b)
+	var file struct {
+		Hosts []string `json:"hosts"`
+		SOPS  struct {
+			MAC string `json:"mac"`
+		} `json:"sops"`
+	}
+	if err := json.Unmarshal(b, &file); err != nil {
+		t.Fatal(err)
+	}
+	first, second := strconv.Quote(file.Hosts[0]), strconv.Quote(file.Hosts[1])
+
+	tests := []struct {
+		name    string
+		old     string
+		new     string
+		wantErr string
+	}{
+		{
+			name:    "value removed",
+			old:     ",\n\t\t" + second,
+			new:     "",
+			wantErr: "sops mac mismatch",
+		},
+		{
+			name:    "value added",
+			old:     `"name_unencrypted": "demo",`,
+			new:     `"name_unencrypted": "demo", "extra_unencrypted": "x",`,
+			wantErr: "sops mac mismatch",
+		},
+		{
+			name:    "values reordered",
+			old:     first + ",\n\t\t" + second,
+			new:     second + ",\n\t\t" + first,
+			wantErr: "sops mac mismatch",
+		},
+		{
+			name:    "value moved",
+			old:     ",\n\t\t" + second + "\n\t],",
+			new:     "\n\t],\n\t\"other\": " + second + ",",
+			wantErr: "error decrypting value of [other]",
+		},
+		{
+			name:    "mac modified",
+			old:     file.SOPS.MAC,
+			new:     strings.Replace(file.SOPS.MAC, "data:", "data:AA", 1),
+			wantErr: "error decrypting sops mac",
+		},
+		{
+			name:    "mac removed",
+			old:     file.SOPS.MAC,
+			new:     "",
+			wantErr: "error missing sops mac",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if !strings.Contains(data, tt.old) {
+				t.Fatalf("%q not found in testdata/sops/app.enc.json", tt.old)
+			}
+			path := t.TempDir() + "/app.enc.json"
+			if err := os.WriteFile(path, []byte(strings.Replace(data, tt.old, tt.new, 1)), 0o600); err != nil {
+				t.Fatal(err)
+			}
+			_, err := readConfigFileSettings(path)
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("readConfigFileSettings() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestReadSOPSConfigFileUnsupportedKey(t *testing.T) {
+	path := t.TempDir() + "/app.enc.json"
+	data := `{"password": "ENC[AES256_GCM,data:AA==,iv:AA==,tag:AA==,type:str]", "sops": {"age": [{"recipient": "age1x"}]}}`
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	_, err := readConfigFileSettings(path)
+	if err == nil || !strings.Contains(err.Error(), "unsupported sops key types [age]") {
+		t.Errorf("readConfigFileSettings() error = %v, want unsupported age key", err)
+	}
+}
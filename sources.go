@@ -0,0 +1,265 @@
+package corefx
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"go.uber.org/fx"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+)
+
+// ConfigSource a source of config values.
+// Sources passed to LoadConfig are merged in order, later sources override earlier ones.
+type ConfigSource interface {
+	// MergeInto merge config values of this source into v.
+	// The cfg is the config object being loaded, which can be used to discover config keys.
+	MergeInto(v *viper.Viper, cfg any) error
+}
+
+// ConfigSourceFunc adapter to use a function as a ConfigSource.
+type ConfigSourceFunc func(v *viper.Viper, cfg any) error
+
+func (f ConfigSourceFunc) MergeInto(v *viper.Viper, cfg any) error {
+	return f(v, cfg)
+}
+
+// ConfigSourcesConfig optional interface that a CoreConfig can implement to customize config sources and their
+// precedence, instead of the default defaults -> files -> env -> flags chain.
+// When implemented, AppConfigLocationValue, profile specific files and WithPFlags are ignored,
+// and config watching only apply to sources registered through AppConfigLocationValue.
+type ConfigSourcesConfig interface {
+	// AppConfigSourcesValue list of config sources, merged in order, later sources override earlier ones.
+	// For example, to let config file win over env:
+	// []ConfigSource{DefaultsSource(), EnvSource(""), FileSource("configs/app.json")}.
+	AppConfigSourcesValue() ([]ConfigSource, error)
+}
+
+// configSources config sources registered using WithConfigSources.
+type configSources []ConfigSource
+
+// WithConfigSources load the config from sources instead of its config locations, env and flags, overriding
+// ConfigSourcesConfig. Must be used together with NewModule. The dotenv file is not loaded.
+// For example, corefx.WithConfigSources(corefx.DefaultsSource()) load the config using only its current values and
+// default tags, which is useful in tests.
+func WithConfigSources(sources ...ConfigSource) fx.Option {
+	return fx.Supply(configSources(append([]ConfigSource{}, sources...)))
+}
+
+// LoadConfig load config into cfg pointer from sources, merged in order, later sources override earlier ones.
+// Keys follow the json tag of cfg fields.
+// Placeholders like ${ENV_VAR} or ${ENV_VAR:default} in string values are replaced after merging.
+// A fresh viper instance is used for each call, use LoadConfigWithViper to inspect it after loading.
+func LoadConfig(cfg any, sources ...ConfigSource) error {
+	return LoadConfigWithViper(viper.New(), cfg, sources...)
+}
+
+// LoadConfigWithViper load config into cfg pointer from sources using the specified viper instance.
+// See LoadConfig.
+func LoadConfigWithViper(v *viper.Viper, cfg any, sources ...ConfigSource) error {
+	if err := loadConfigWithViper(v, cfg, nil, sources...); err != nil {
+		return err
+	}
+	return expandPlaceholders(cfg)
+}
+
+// loadConfigWithViper load config from sources, recording the origin of values into origins if not nil.
+func loadConfigWithViper(v *viper.Viper, cfg any, origins *configOrigins, sources ...ConfigSource) error {
+	if reflect.ValueOf(cfg).Type().Kind() != reflect.Pointer {
+		return errors.New("error LoadConfig require a pointer to config struct")
+	}
+	if err := applyDefaults(cfg); err != nil {
+		return err
+	}
+	for _, source := range sources {
+		if err := mergeSource(v, cfg, source, origins); err != nil {
+			return err
+		}
+	}
+	return v.Unmarshal(cfg, jsonDecoderConfig)
+}
+
+// DefaultsSource use the current values of the config object as config values.
+// Usually the first source, so values set in the config constructor act as defaults.
+func DefaultsSource() ConfigSource {
+	return sourceOf(ConfigOriginDefault, nil, func(_ *viper.Viper, cfg any) (map[string]any, error) {
+		return configMapOf(cfg)
+	})
+}
+
+// FileSource read config values from a JSON or YAML file, missing file is ignored.
+// The path may be prefixed by "file:".
+// The file can include other files using the "$include" directive, for example {"$include": ["features/*.json"]}.
+func FileSource(path string) ConfigSource {
+	path = strings.TrimPrefix(path, "file:")
+	return sourceOf(ConfigOriginFile, fixedLocation(path), func(_ *viper.Viper, _ any) (map[string]any, error) {
+		settings, err := readConfigFile(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return settings, err
+	})
+}
+
+// DirSource read config values from a directory of files, each file name is a key and its content is the value,
+// which is the layout of Kubernetes ConfigMap and Secret volumes. Missing directory is ignored.
+// The path may be prefixed by "dir:". Nested keys are separated by "." or "__" in file names, for example
+// database__url set database.url. Hidden files are ignored and a trailing newline of content is trimmed.
+func DirSource(path string) ConfigSource {
+	path = strings.TrimPrefix(path, "dir:")
+	return sourceOf(ConfigOriginFile, fixedLocation(path), func(_ *viper.Viper, _ any) (map[string]any, error) {
+		settings, err := readConfigDir(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return settings, err
+	})
+}
+
+// readConfigDir read the files of dir as config values.
+func readConfigDir(dir string) (map[string]any, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	settings := map[string]any{}
+	for _, entry := range entries {
+		if strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		path := filepath.Join(dir, entry.Name())
+		// Stat follow symlinks, Kubernetes mount each key as a symlink into a hidden data dir.
+		info, err := os.Stat(path)
+		if err != nil {
+			return nil, err
+		}
+		if info.IsDir() {
+			continue
+		}
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		key := strings.ReplaceAll(strings.ToLower(entry.Name()), "__", ".")
+		setNested(settings, key, strings.TrimRight(string(content), "\r\n"))
+	}
+	return settings, nil
+}
+
+// RemoteSource read config values from a remote location, missing remote config is ignored.
+// See CoreConfig.AppConfigLocationValue for supported remote locations.
+// Return error if the remote config cannot be fetched in 30s.
+func RemoteSource(location string) ConfigSource {
+	return sourceOf(ConfigOriginRemote, fixedLocation(location), func(_ *viper.Viper, cfg any) (map[string]any, error) {
+		ctx, cancel := context.WithTimeout(context.Background(), remoteConfigLoadTimeout)
+		defer cancel()
+		data, _, err := fetchRemoteConfig(ctx, cfg, location, "")
+		if err != nil || data == nil {
+			return nil, err
+		}
+		remote := viper.New()
+		remote.SetConfigType(remoteConfigTypeOf(location))
+		if err := remote.ReadConfig(bytes.NewReader(data)); err != nil {
+			return nil, err
+		}
+		return remote.AllSettings(), nil
+	})
+}
+
+// EnvSource read config values from env variables, optionally prefixed, for example MYAPP_LOG_LEVEL.
+// Nested keys are separated by "__", for example DB__URL for db.url.
+// Only keys of the config object and keys merged by previous sources are read.
+func EnvSource(prefix string) ConfigSource {
+	envLocationOf := func(key string) string {
+		return envNameOf(key, prefix)
+	}
+	return sourceOf(ConfigOriginEnv, envLocationOf, func(v *viper.Viper, cfg any) (map[string]any, error) {
+		settings, err := configMapOf(cfg)
+		if err != nil {
+			return nil, err
+		}
+		keys := map[string]struct{}{}
+		for _, key := range flattenKeys(settings, "") {
+			keys[key] = struct{}{}
+		}
+		for _, key := range v.AllKeys() {
+			keys[key] = struct{}{}
+		}
+
+		env := map[string]any{}
+		for key := range keys {
+			if value, ok := os.LookupEnv(envNameOf(key, prefix)); ok {
+				setNested(env, key, value)
+			}
+		}
+		return env, nil
+	})
+}
+
+// FlagsSource read config values from flags that are explicitly set.
+// Flag names are mapped to config keys by replacing "-" with "_", for example --log-level set log_level.
+func FlagsSource(flagSet *pflag.FlagSet) ConfigSource {
+	return sourceOf(ConfigOriginFlag, flagNameOf, func(_ *viper.Viper, _ any) (map[string]any, error) {
+		flags := map[string]any{}
+		flagSet.Visit(func(flag *pflag.Flag) {
+			var value any = flag.Value.String()
+			if slice, ok := flag.Value.(pflag.SliceValue); ok {
+				value = slice.GetSlice()
+			}
+			setNested(flags, strings.ReplaceAll(flag.Name, "-", "_"), value)
+		})
+		return flags, nil
+	})
+}
+
+// flagNameOf return the flag name of a config key, for example --log-level for log_level.
+func flagNameOf(key string) string {
+	return "--" + strings.ReplaceAll(key, "_", "-")
+}
+
+// configMapOf return the json representation of config as a map.
+func configMapOf(cfg any) (map[string]any, error) {
+	b, err := json.Marshal(cfg)
+	if err != nil {
+		return nil, err
+	}
+	settings := map[string]any{}
+	if err := json.Unmarshal(b, &settings); err != nil {
+		return nil, err
+	}
+	return settings, nil
+}
+
+// flattenKeys return the dot separated keys of all leaf values in settings.
+func flattenKeys(settings map[string]any, prefix string) []string {
+	keys := make([]string, 0, len(settings))
+	for key, value := range settings {
+		key = joinConfigPath(prefix, strings.ToLower(key))
+		if nested, ok := value.(map[string]any); ok && len(nested) > 0 {
+			keys = append(keys, flattenKeys(nested, key)...)
+			continue
+		}
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// setNested set value of a dot separated key in settings, creating nested maps as needed.
+func setNested(settings map[string]any, key string, value any) {
+	parts := strings.Split(key, ".")
+	for _, part := range parts[:len(parts)-1] {
+		nested, ok := settings[part].(map[string]any)
+		if !ok {
+			nested = map[string]any{}
+			settings[part] = nested
+		}
+		settings = nested
+	}
+	settings[parts[len(parts)-1]] = value
+}
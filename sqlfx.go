@@ -0,0 +1,153 @@
+package corefx
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"go.uber.org/fx"
+	"log/slog"
+	"time"
+)
+
+type DatabaseConfig interface {
+	// DatabaseDriverValue name of the database/sql driver, for example "postgres" or "mysql".
+	// The driver package must be imported by the application.
+	DatabaseDriverValue() string
+	// DatabaseDsnValue data source name passed to the driver.
+	DatabaseDsnValue() string
+	// DatabaseMaxOpenConnsValue max number of open connections, zero means unlimited.
+	DatabaseMaxOpenConnsValue() int
+	// DatabaseMaxIdleConnsValue max number of idle connections, zero keep the database/sql default.
+	DatabaseMaxIdleConnsValue() int
+	// DatabaseConnMaxLifetimeValue max duration a connection may be reused, zero means forever.
+	DatabaseConnMaxLifetimeValue() time.Duration
+	// DatabaseConnMaxIdleTimeValue max duration a connection may be idle, zero means forever.
+	DatabaseConnMaxIdleTimeValue() time.Duration
+}
+
+type DatabaseEnv struct {
+	DatabaseDriver          string        `json:"database_driver" mapstructure:"database_driver"`
+	DatabaseDsn             string        `json:"database_dsn" mapstructure:"database_dsn" secret:"true"`
+	DatabaseMaxOpenConns    int           `json:"database_max_open_conns" mapstructure:"database_max_open_conns"`
+	DatabaseMaxIdleConns    int           `json:"database_max_idle_conns" mapstructure:"database_max_idle_conns"`
+	DatabaseConnMaxLifetime time.Duration `json:"database_conn_max_lifetime" mapstructure:"database_conn_max_lifetime"`
+	DatabaseConnMaxIdleTime time.Duration `json:"database_conn_max_idle_time" mapstructure:"database_conn_max_idle_time"`
+}
+
+func (e DatabaseEnv) DatabaseDriverValue() string {
+	return e.DatabaseDriver
+}
+
+func (e DatabaseEnv) DatabaseDsnValue() string {
+	return e.DatabaseDsn
+}
+
+func (e DatabaseEnv) DatabaseMaxOpenConnsValue() int {
+	return e.DatabaseMaxOpenConns
+}
+
+func (e DatabaseEnv) DatabaseMaxIdleConnsValue() int {
+	return e.DatabaseMaxIdleConns
+}
+
+func (e DatabaseEnv) DatabaseConnMaxLifetimeValue() time.Duration {
+	return e.DatabaseConnMaxLifetime
+}
+
+func (e DatabaseEnv) DatabaseConnMaxIdleTimeValue() time.Duration {
+	return e.DatabaseConnMaxIdleTime
+}
+
+var _ DatabaseConfig = (*DatabaseEnv)(nil)
+
+// Migrator run database migrations when the app starts, before the components depending on the database are started.
+type Migrator interface {
+	Migrate(ctx context.Context, db *sql.DB) error
+}
+
+// MigratorFunc adapter to use a function as a Migrator.
+type MigratorFunc func(ctx context.Context, db *sql.DB) error
+
+func (f MigratorFunc) Migrate(ctx context.Context, db *sql.DB) error {
+	return f(ctx, db)
+}
+
+// AsMigrator annotate a Migrator constructor to register it into the migrator group.
+// The order of migrators in the group is not guaranteed, use a single Migrator to run migrations in order.
+// For example, fx.Provide(corefx.AsMigrator(newSchemaMigrator)).
+func AsMigrator(f any) any {
+	return fx.Annotate(
+		f,
+		fx.As(new(Migrator)),
+		fx.ResultTags(`group:"sql_migrators"`),
+	)
+}
+
+type SQLDBParams struct {
+	fx.In
+	Loaded    loadedConfig
+	Config    DatabaseConfig
+	Migrators []Migrator `group:"sql_migrators"`
+	Logger    *slog.Logger
+	Lifecycle fx.Lifecycle
+}
+
+// NewSQLDB open a database using the DatabaseConfig.
+// When the app starts, the database is pinged and the registered migrators are run, failing the app start on error.
+// The database is closed when the app stops.
+func NewSQLDB(p SQLDBParams) (*sql.DB, error) {
+	if p.Config.DatabaseDriverValue() == "" {
+		return nil, errors.New("error database driver is not configured")
+	}
+	db, err := sql.Open(p.Config.DatabaseDriverValue(), p.Config.DatabaseDsnValue())
+	if err != nil {
+		return nil, fmt.Errorf("error opening database [%s]: %w", p.Config.DatabaseDriverValue(), err)
+	}
+	db.SetMaxOpenConns(p.Config.DatabaseMaxOpenConnsValue())
+	if p.Config.DatabaseMaxIdleConnsValue() > 0 {
+		db.SetMaxIdleConns(p.Config.DatabaseMaxIdleConnsValue())
+	}
+	db.SetConnMaxLifetime(p.Config.DatabaseConnMaxLifetimeValue())
+	db.SetConnMaxIdleTime(p.Config.DatabaseConnMaxIdleTimeValue())
+
+	p.Lifecycle.Append(fx.Hook{
+		OnStart: func(ctx context.Context) error {
+			if err := startSQLDB(ctx, p.Logger, db, p.Config.DatabaseDriverValue(), p.Migrators); err != nil {
+				// OnStop is not called when OnStart failed.
+				_ = db.Close()
+				return err
+			}
+			return nil
+		},
+		OnStop: func(_ context.Context) error {
+			return db.Close()
+		},
+	})
+	return db, nil
+}
+
+// startSQLDB ping the database and run migrators.
+func startSQLDB(ctx context.Context, logger *slog.Logger, db *sql.DB, driver string, migrators []Migrator) error {
+	if err := db.PingContext(ctx); err != nil {
+		return fmt.Errorf("error connecting to database [%s]: %w", driver, err)
+	}
+	for _, migrator := range migrators {
+		if err := migrator.Migrate(ctx, db); err != nil {
+			return fmt.Errorf("error migrating database [%T]: %w", migrator, err)
+		}
+	}
+	if len(migrators) > 0 {
+		logger.Info("Migrated database", slog.Int("migrators", len(migrators)))
+	}
+	return nil
+}
+
+// NewSQLModule provide a *sql.DB configured by the DatabaseConfig and managed by the app lifecycle.
+// Must be used together with NewModule, and the env config must register as DatabaseConfig.
+// Migrations registered using AsMigrator are run when the app starts.
+func NewSQLModule() fx.Option {
+	return fx.Module("corefx.sql",
+		fx.Provide(NewSQLDB),
+	)
+}
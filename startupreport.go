@@ -0,0 +1,198 @@
+package corefx
+
+import (
+	"cmp"
+	"go.uber.org/fx"
+	"go.uber.org/fx/fxevent"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// startupReportSlowest max number of hooks listed in the startup report.
+const startupReportSlowest = 5
+
+const (
+	LifecycleTimingInvoke  = "invoke"
+	LifecycleTimingOnStart = "OnStart"
+	LifecycleTimingOnStop  = "OnStop"
+)
+
+// LifecycleTiming duration of an fx invoke or lifecycle hook.
+type LifecycleTiming struct {
+	// Kind of the timing: "invoke", "OnStart" or "OnStop".
+	Kind string `json:"kind"`
+	// Name of the invoked function or hook.
+	Name string `json:"name"`
+	// Module fx module of invokes, empty for the root module and hooks.
+	Module string `json:"module,omitempty"`
+	// Caller constructor that registered the hook, empty for invokes.
+	Caller string `json:"caller,omitempty"`
+	// Duration of invokes include the constructors run to build their parameters.
+	Duration time.Duration `json:"duration"`
+}
+
+// LifecycleReport timings of the fx invokes and lifecycle hooks of the app, collected from fx events, so slow
+// startups can be diagnosed and exported as metrics.
+type LifecycleReport struct {
+	begin time.Time
+
+	mu          sync.Mutex
+	timings     []LifecycleTiming
+	invokeStart time.Time
+	startup     time.Duration
+	// modules names of the corefx modules that provided a constructor, without the "corefx." prefix.
+	modules []string
+	// banner called once the app started, see registerBanner.
+	banner func(modules []string, startup time.Duration)
+	// stopped called once the app stopped or rolled back, then cleared, see onStopped.
+	stopped []func()
+}
+
+// Timings return the timings recorded so far, in execution order.
+func (r *LifecycleReport) Timings() []LifecycleTiming {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return slices.Clone(r.timings)
+}
+
+// StartupDuration return the duration from NewModule until the app started, zero if not started yet.
+func (r *LifecycleReport) StartupDuration() time.Duration {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.startup
+}
+
+// record record the timing of event, return true if event is the successful start of the app.
+func (r *LifecycleReport) record(event fxevent.Event) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	switch e := event.(type) {
+	case *fxevent.Provided:
+		if module, ok := strings.CutPrefix(e.ModuleName, "corefx."); ok && !slices.Contains(r.modules, module) {
+			r.modules = append(r.modules, module)
+		}
+	case *fxevent.Invoking:
+		r.invokeStart = time.Now()
+	case *fxevent.Invoked:
+		if !r.invokeStart.IsZero() {
+			r.timings = append(r.timings, LifecycleTiming{
+				Kind:     LifecycleTimingInvoke,
+				Name:     e.FunctionName,
+				Module:   e.ModuleName,
+				Duration: time.Since(r.invokeStart),
+			})
+			r.invokeStart = time.Time{}
+		}
+	case *fxevent.OnStartExecuted:
+		r.timings = append(r.timings, LifecycleTiming{
+			Kind:     LifecycleTimingOnStart,
+			Name:     e.FunctionName,
+			Caller:   e.CallerName,
+			Duration: e.Runtime,
+		})
+	case *fxevent.OnStopExecuted:
+		r.timings = append(r.timings, LifecycleTiming{
+			Kind:     LifecycleTimingOnStop,
+			Name:     e.FunctionName,
+			Caller:   e.CallerName,
+			Duration: e.Runtime,
+		})
+	case *fxevent.Started:
+		if e.Err == nil && r.startup == 0 {
+			r.startup = time.Since(r.begin)
+			return true
+		}
+	}
+	return false
+}
+
+// onStarted register the function to call once the app started.
+func (r *LifecycleReport) onStarted(f func(modules []string, startup time.Duration)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.banner = f
+}
+
+// started call the function registered using onStarted, if any.
+// onStopped register f to be called once the app stopped, after all its OnStop hooks, or rolled back a failed start.
+func (r *LifecycleReport) onStopped(f func()) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.stopped = append(r.stopped, f)
+}
+
+// stop call the functions registered using onStopped.
+func (r *LifecycleReport) stop() {
+	r.mu.Lock()
+	stopped := r.stopped
+	r.stopped = nil
+	r.mu.Unlock()
+	for _, f := range stopped {
+		f()
+	}
+}
+
+func (r *LifecycleReport) started() {
+	r.mu.Lock()
+	banner, modules, startup := r.banner, slices.Clone(r.modules), r.startup
+	r.mu.Unlock()
+	if banner != nil {
+		banner(modules, startup)
+	}
+}
+
+// summaryAttrs return the log attributes of the startup summary: the startup duration, the invoke duration of each
+// module, and the slowest OnStart hooks.
+func (r *LifecycleReport) summaryAttrs() []slog.Attr {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var modules []string
+	moduleDurations := map[string]time.Duration{}
+	var hooks []LifecycleTiming
+	for _, timing := range r.timings {
+		switch timing.Kind {
+		case LifecycleTimingInvoke:
+			module := timing.Module
+			if module == "" {
+				module = "root"
+			}
+			if _, ok := moduleDurations[module]; !ok {
+				modules = append(modules, module)
+			}
+			moduleDurations[module] += timing.Duration
+		case LifecycleTimingOnStart:
+			hooks = append(hooks, timing)
+		}
+	}
+
+	moduleAttrs := make([]any, 0, len(modules))
+	for _, module := range modules {
+		moduleAttrs = append(moduleAttrs, slog.Duration(module, moduleDurations[module]))
+	}
+	slices.SortStableFunc(hooks, func(a, b LifecycleTiming) int {
+		return cmp.Compare(b.Duration, a.Duration)
+	})
+	hookAttrs := make([]any, 0, startupReportSlowest)
+	for _, hook := range hooks[:min(len(hooks), startupReportSlowest)] {
+		hookAttrs = append(hookAttrs, slog.Duration(hook.Caller, hook.Duration))
+	}
+	return []slog.Attr{
+		slog.Duration("duration", r.startup),
+		slog.Group("modules", moduleAttrs...),
+		slog.Group("slowest_hooks", hookAttrs...),
+	}
+}
+
+// startupReportFlag supplied by WithStartupReport.
+type startupReportFlag bool
+
+// WithStartupReport log a summary of the startup when the app started, using the fx logger at info level: the
+// startup duration, the duration of invokes of each module (including the constructors they run), and the slowest
+// OnStart hooks. The timings are always available using the injectable *LifecycleReport.
+// Must be used together with NewModule.
+func WithStartupReport() fx.Option {
+	return fx.Supply(startupReportFlag(true))
+}
@@ -0,0 +1,272 @@
+package corefx
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"go.uber.org/fx"
+	"io"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// journaldSocket the native protocol socket of systemd-journald.
+const journaldSocket = "/run/systemd/journal/socket"
+
+// SystemLogConfig optional interface that a CoreConfig can implement to send logs to the system logger,
+// alongside the console/JSON handler.
+type SystemLogConfig interface {
+	// LogSyslogValue address of the syslog server, records are sent using RFC5424 format.
+	// For example udp://localhost:514, tcp://localhost:601 or unix:///dev/log.
+	// Return empty string to disable syslog output.
+	LogSyslogValue() string
+	// LogJournaldValue whether to send logs to systemd-journald using its native protocol.
+	LogJournaldValue() bool
+}
+
+type SystemLogEnv struct {
+	LogSyslog   string `json:"log_syslog" mapstructure:"log_syslog"`
+	LogJournald bool   `json:"log_journald" mapstructure:"log_journald"`
+}
+
+func (e SystemLogEnv) LogSyslogValue() string {
+	return e.LogSyslog
+}
+
+func (e SystemLogEnv) LogJournaldValue() bool {
+	return e.LogJournald
+}
+
+var _ SystemLogConfig = (*SystemLogEnv)(nil)
+
+// systemLogHandlersOf create the syslog and journald handlers enabled by config, which are closed when the app stops.
+func systemLogHandlersOf(c SystemLogConfig, appName string, lc fx.Lifecycle) ([]slog.Handler, error) {
+	var handlers []slog.Handler
+	var closers []io.Closer
+	if c.LogSyslogValue() != "" {
+		w, err := newSyslogWriter(c.LogSyslogValue(), appName)
+		if err != nil {
+			return nil, err
+		}
+		handlers, closers = append(handlers, newFlatHandler(w.handle)), append(closers, w)
+	}
+	if c.LogJournaldValue() {
+		w := newJournaldWriter(appName)
+		handlers, closers = append(handlers, newFlatHandler(w.handle)), append(closers, w)
+	}
+	for _, closer := range closers {
+		lc.Append(fx.Hook{
+			OnStop: func(_ context.Context) error {
+				return closer.Close()
+			},
+		})
+	}
+	return handlers, nil
+}
+
+// syslogSeverityOf return the syslog severity of level: debug 7, info 6, warn 4, error 3.
+func syslogSeverityOf(level slog.Level) int {
+	switch {
+	case level >= slog.LevelError:
+		return 3
+	case level >= slog.LevelWarn:
+		return 4
+	case level >= slog.LevelInfo:
+		return 6
+	default:
+		return 7
+	}
+}
+
+// textOf format the message and attributes in key=value format.
+func textOf(message string, attrs []slog.Attr) string {
+	var b strings.Builder
+	b.WriteString(message)
+	for _, attr := range attrs {
+		b.WriteByte(' ')
+		b.WriteString(attr.Key)
+		b.WriteByte('=')
+		value := attr.Value.String()
+		if value == "" || strings.ContainsAny(value, " \t\r\n\"=") {
+			value = strconv.Quote(value)
+		}
+		b.WriteString(value)
+	}
+	return b.String()
+}
+
+// syslogWriter send RFC5424 messages to a syslog server, reconnecting on write errors.
+type syslogWriter struct {
+	network  string
+	address  string
+	hostname string
+	appName  string
+
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+// newSyslogWriter create a syslog writer, the connection is opened on the first write.
+func newSyslogWriter(location string, appName string) (*syslogWriter, error) {
+	u, err := url.Parse(location)
+	if err != nil {
+		return nil, err
+	}
+	w := &syslogWriter{network: u.Scheme, address: u.Host, appName: appName}
+	switch u.Scheme {
+	case "udp", "tcp":
+	case "unix", "unixgram":
+		w.address = u.Path
+	default:
+		return nil, fmt.Errorf("error unsupported syslog address [%s]", location)
+	}
+	if w.hostname, err = os.Hostname(); err != nil || w.hostname == "" {
+		w.hostname = "-"
+	}
+	if w.appName == "" {
+		w.appName = "-"
+	}
+	return w, nil
+}
+
+func (w *syslogWriter) handle(r slog.Record, attrs []slog.Attr) error {
+	// Facility user-level (1).
+	priority := 1*8 + syslogSeverityOf(r.Level)
+	message := fmt.Sprintf("<%d>1 %s %s %s %d - - %s", priority,
+		r.Time.Format(time.RFC3339Nano), w.hostname, w.appName, os.Getpid(), textOf(r.Message, attrs))
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	err := w.write(message)
+	if err != nil {
+		// Retry once with a new connection.
+		w.close()
+		err = w.write(message)
+	}
+	return err
+}
+
+func (w *syslogWriter) write(message string) error {
+	if w.conn == nil {
+		conn, err := w.dial()
+		if err != nil {
+			return err
+		}
+		w.conn = conn
+	}
+	if w.network == "tcp" || w.network == "unix" {
+		// Stream transports use octet counting framing, see RFC6587.
+		message = strconv.Itoa(len(message)) + " " + message
+	}
+	_, err := w.conn.Write([]byte(message))
+	return err
+}
+
+func (w *syslogWriter) dial() (net.Conn, error) {
+	if w.network != "unix" {
+		return net.DialTimeout(w.network, w.address, 5*time.Second)
+	}
+	// Unix syslog sockets, such as /dev/log, are usually datagram sockets.
+	if conn, err := net.Dial("unixgram", w.address); err == nil {
+		w.network = "unixgram"
+		return conn, nil
+	}
+	return net.Dial("unix", w.address)
+}
+
+func (w *syslogWriter) close() {
+	if w.conn != nil {
+		_ = w.conn.Close()
+		w.conn = nil
+	}
+}
+
+func (w *syslogWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.close()
+	return nil
+}
+
+// journaldWriter send records to systemd-journald using its native protocol.
+type journaldWriter struct {
+	identifier string
+
+	mu   sync.Mutex
+	conn *net.UnixConn
+}
+
+func newJournaldWriter(identifier string) *journaldWriter {
+	return &journaldWriter{identifier: identifier}
+}
+
+func (w *journaldWriter) handle(r slog.Record, attrs []slog.Attr) error {
+	var b bytes.Buffer
+	writeJournaldField(&b, "MESSAGE", r.Message)
+	writeJournaldField(&b, "PRIORITY", strconv.Itoa(syslogSeverityOf(r.Level)))
+	if w.identifier != "" {
+		writeJournaldField(&b, "SYSLOG_IDENTIFIER", w.identifier)
+	}
+	for _, attr := range attrs {
+		if name := journaldFieldNameOf(attr.Key); name != "" {
+			writeJournaldField(&b, name, attr.Value.String())
+		}
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.conn == nil {
+		conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: journaldSocket, Net: "unixgram"})
+		if err != nil {
+			return err
+		}
+		w.conn = conn
+	}
+	_, err := w.conn.Write(b.Bytes())
+	return err
+}
+
+func (w *journaldWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.conn == nil {
+		return nil
+	}
+	err := w.conn.Close()
+	w.conn = nil
+	return err
+}
+
+// writeJournaldField write a field using the native protocol, values with newlines use the binary format.
+func writeJournaldField(b *bytes.Buffer, name string, value string) {
+	b.WriteString(name)
+	if !strings.Contains(value, "\n") {
+		b.WriteByte('=')
+		b.WriteString(value)
+		b.WriteByte('\n')
+		return
+	}
+	b.WriteByte('\n')
+	_ = binary.Write(b, binary.LittleEndian, uint64(len(value)))
+	b.WriteString(value)
+	b.WriteByte('\n')
+}
+
+// journaldFieldNameOf convert an attribute key into a journald field name, which only contain A-Z, 0-9 and _,
+// and must not start with _ or a digit.
+func journaldFieldNameOf(key string) string {
+	name := []byte(strings.ToUpper(key))
+	for i, c := range name {
+		if (c < 'A' || c > 'Z') && (c < '0' || c > '9') {
+			name[i] = '_'
+		}
+	}
+	return strings.TrimLeft(string(name), "_0123456789")
+}
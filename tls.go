@@ -0,0 +1,230 @@
+package corefx
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// tlsReloadInterval min duration between checks of the TLS files for changes.
+const tlsReloadInterval = 10 * time.Second
+
+type TLSConfig interface {
+	// TLSCertValue certificate chain in PEM, either a file path or the inline PEM content.
+	TLSCertValue() string
+	// TLSKeyValue private key of the certificate in PEM, either a file path or the inline PEM content.
+	TLSKeyValue() string
+	// TLSCAValue certificate authorities in PEM, either a file path or the inline PEM content.
+	// Servers use them to verify client certificates, clients trust them in addition to the system ones.
+	TLSCAValue() string
+	// TLSClientAuthValue require and verify client certificates against the certificate authorities when serving.
+	TLSClientAuthValue() bool
+}
+
+type TLSEnv struct {
+	TLSCert       string `json:"tls_cert" mapstructure:"tls_cert"`
+	TLSKey        string `json:"tls_key" mapstructure:"tls_key" secret:"true"`
+	TLSCA         string `json:"tls_ca" mapstructure:"tls_ca"`
+	TLSClientAuth bool   `json:"tls_client_auth" mapstructure:"tls_client_auth"`
+}
+
+func (e TLSEnv) TLSCertValue() string {
+	return e.TLSCert
+}
+
+func (e TLSEnv) TLSKeyValue() string {
+	return e.TLSKey
+}
+
+func (e TLSEnv) TLSCAValue() string {
+	return e.TLSCA
+}
+
+func (e TLSEnv) TLSClientAuthValue() bool {
+	return e.TLSClientAuth
+}
+
+var _ TLSConfig = (*TLSEnv)(nil)
+
+// TLS the certificate and certificate authorities of a TLSConfig.
+// Files are checked for changes at most every 10 seconds during handshakes and reloaded when rotated,
+// if the new files are invalid the previous ones are kept. Inline PEM values are never reloaded.
+type TLS struct {
+	config TLSConfig
+	logger *slog.Logger
+
+	mu        sync.Mutex
+	cert      *tls.Certificate
+	ca        []byte
+	modTimes  map[string]time.Time
+	checkedAt time.Time
+}
+
+// NewTLS load the TLS material of config, errors reloading the files are logged using logger, or slog.Default if nil.
+// Use ServerConfig and ClientConfig to get the tls.Config of servers and clients.
+func NewTLS(logger *slog.Logger, config TLSConfig) (*TLS, error) {
+	if (config.TLSCertValue() == "") != (config.TLSKeyValue() == "") {
+		return nil, errors.New("error tls cert and key must be set together")
+	}
+	if config.TLSClientAuthValue() && config.TLSCAValue() == "" {
+		return nil, errors.New("error tls client auth requires tls ca")
+	}
+	t := &TLS{config: config, logger: logger}
+	if err := t.load(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// ServerConfig return the tls.Config of servers, for example http.Server.TLSConfig.
+// Require a certificate.
+func (t *TLS) ServerConfig() *tls.Config {
+	getCertificate := func(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+		cert, _ := t.current()
+		if cert == nil {
+			return nil, errors.New("error tls certificate is not configured")
+		}
+		return cert, nil
+	}
+	base := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: getCertificate,
+		// Protocols of http.Server by default, recent Go versions only set them on a copy of the config.
+		NextProtos: []string{"h2", "http/1.1"},
+	}
+	base.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		_, ca := t.current()
+		if ca == nil {
+			return nil, nil
+		}
+		// Clone the base config, so its NextProtos are kept to serve HTTP/2.
+		config := base.Clone()
+		config.GetConfigForClient = nil
+		config.ClientCAs = x509.NewCertPool()
+		config.ClientCAs.AppendCertsFromPEM(ca)
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+		if t.config.TLSClientAuthValue() {
+			config.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+		return config, nil
+	}
+	return base
+}
+
+// ClientConfig return the tls.Config of clients, for example http.Transport.TLSClientConfig.
+// The certificate, if any, is sent when requested by servers. The certificate authorities are read when called.
+func (t *TLS) ClientConfig() *tls.Config {
+	config := &tls.Config{
+		MinVersion: tls.VersionTLS12,
+		GetClientCertificate: func(*tls.CertificateRequestInfo) (*tls.Certificate, error) {
+			cert, _ := t.current()
+			if cert == nil {
+				return &tls.Certificate{}, nil
+			}
+			return cert, nil
+		},
+	}
+	if _, ca := t.current(); ca != nil {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		pool.AppendCertsFromPEM(ca)
+		config.RootCAs = pool
+	}
+	return config
+}
+
+// current return the current certificate and certificate authorities, reloading them if their files changed.
+func (t *TLS) current() (*tls.Certificate, []byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if time.Since(t.checkedAt) >= tlsReloadInterval {
+		t.checkedAt = time.Now()
+		if t.changed() {
+			if err := t.reload(); err != nil {
+				logger := t.logger
+				if logger == nil {
+					logger = slog.Default()
+				}
+				logger.Warn("Error reloading tls files, keep using the previous ones", slog.Any("err", err))
+			}
+		}
+	}
+	return t.cert, t.ca
+}
+
+func (t *TLS) load() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.checkedAt = time.Now()
+	return t.reload()
+}
+
+// changed whether any file was modified since loaded. Must be called with mu held.
+func (t *TLS) changed() bool {
+	for path, modTime := range t.modTimes {
+		stat, err := os.Stat(path)
+		if err != nil || !stat.ModTime().Equal(modTime) {
+			return true
+		}
+	}
+	return false
+}
+
+// reload read the certificate and certificate authorities. Must be called with mu held.
+func (t *TLS) reload() error {
+	modTimes := map[string]time.Time{}
+	var cert *tls.Certificate
+	if t.config.TLSCertValue() != "" {
+		certPEM, err := readPEM("tls cert", t.config.TLSCertValue(), modTimes)
+		if err != nil {
+			return err
+		}
+		keyPEM, err := readPEM("tls key", t.config.TLSKeyValue(), modTimes)
+		if err != nil {
+			return err
+		}
+		pair, err := tls.X509KeyPair(certPEM, keyPEM)
+		if err != nil {
+			return fmt.Errorf("error loading tls cert and key: %w", err)
+		}
+		cert = &pair
+	}
+	var ca []byte
+	if t.config.TLSCAValue() != "" {
+		var err error
+		ca, err = readPEM("tls ca", t.config.TLSCAValue(), modTimes)
+		if err != nil {
+			return err
+		}
+		if !x509.NewCertPool().AppendCertsFromPEM(ca) {
+			return errors.New("error tls ca contains no certificate")
+		}
+	}
+	t.cert, t.ca, t.modTimes = cert, ca, modTimes
+	return nil
+}
+
+// readPEM return value if it is inline PEM, otherwise read the file at path value and record its modification time.
+func readPEM(name string, value string, modTimes map[string]time.Time) ([]byte, error) {
+	if strings.Contains(value, "-----BEGIN ") {
+		return []byte(value), nil
+	}
+	stat, err := os.Stat(value)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s file [%s]: %w", name, value, err)
+	}
+	data, err := os.ReadFile(value)
+	if err != nil {
+		return nil, fmt.Errorf("error reading %s file [%s]: %w", name, value, err)
+	}
+	modTimes[value] = stat.ModTime()
+	return data, nil
+}
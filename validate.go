@@ -0,0 +1,99 @@
+package corefx
+
+import (
+	"errors"
+	"fmt"
+	"go.uber.org/fx"
+	"reflect"
+	"strconv"
+)
+
+// ConfigValidator optional interface that config struct, or any nested struct, can implement to validate itself.
+// Validate is invoked after the config is loaded and before the app starts.
+// Useful for cross-field rules that cannot be expressed using required fields.
+type ConfigValidator interface {
+	Validate() error
+}
+
+// StructValidator validate a struct using its tags, returning field-path-qualified errors.
+// For example, the Struct method of go-playground/validator: validator.New().Struct.
+type StructValidator func(s any) error
+
+// WithStructValidation validate the loaded config using a StructValidator, after required fields and ConfigValidator.
+// Must be used together with NewModule.
+// For example, corefx.WithStructValidation(validator.New().Struct) to run go-playground/validator tags.
+func WithStructValidation(validate StructValidator) fx.Option {
+	return fx.Supply(validate)
+}
+
+// validateConfig invoke ConfigValidator on config and all nested structs.
+// Return an error joining every validation error.
+func validateConfig(cfg any) error {
+	var errs []error
+	walkConfigValidator(reflect.ValueOf(cfg), "", true, &errs)
+	return errors.Join(errs...)
+}
+
+// walkConfigValidator invoke ConfigValidator on v and its children.
+// Embedded structs are not validated by themselves, as their Validate method is promoted to the parent.
+func walkConfigValidator(v reflect.Value, fieldPath string, self bool, errs *[]error) {
+	if !v.IsValid() {
+		return
+	}
+	if self {
+		if err := invokeConfigValidator(v); err != nil {
+			if fieldPath != "" {
+				err = fmt.Errorf("[%s] %w", fieldPath, err)
+			}
+			*errs = append(*errs, err)
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Pointer, reflect.Interface:
+		if !v.IsNil() {
+			// The pointer itself is already validated.
+			walkConfigValidator(v.Elem(), fieldPath, false, errs)
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			walkConfigValidator(v.Index(i), fieldPath+"["+strconv.Itoa(i)+"]", true, errs)
+		}
+	case reflect.Map:
+		for _, k := range sortedMapKeys(v) {
+			walkConfigValidator(v.MapIndex(k), fieldPath+"["+fmt.Sprint(k.Interface())+"]", true, errs)
+		}
+	case reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			if field.Anonymous && field.Type.Kind() == reflect.Struct {
+				walkConfigValidator(v.Field(i), fieldPath, false, errs)
+				continue
+			}
+			walkConfigValidator(v.Field(i), joinConfigPath(fieldPath, field.Name), true, errs)
+		}
+	default:
+	}
+}
+
+// invokeConfigValidator invoke Validate if v implements ConfigValidator, either by value or by pointer.
+func invokeConfigValidator(v reflect.Value) error {
+	if (v.Kind() == reflect.Pointer || v.Kind() == reflect.Interface) && v.IsNil() {
+		return nil
+	}
+	if v.CanInterface() {
+		if validator, ok := v.Interface().(ConfigValidator); ok {
+			return validator.Validate()
+		}
+	}
+	if v.Kind() != reflect.Pointer && v.CanAddr() && v.Addr().CanInterface() {
+		if validator, ok := v.Addr().Interface().(ConfigValidator); ok {
+			return validator.Validate()
+		}
+	}
+	return nil
+}
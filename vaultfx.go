@@ -0,0 +1,132 @@
+package corefx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go.uber.org/fx"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+type VaultConfig interface {
+	// VaultAddrValue address of vault server, for example https://vault.internal:8200.
+	// Default to VAULT_ADDR env if empty.
+	VaultAddrValue() string
+	// VaultTokenValue token used to authenticate with vault.
+	// Default to VAULT_TOKEN env if empty.
+	VaultTokenValue() string
+}
+
+type VaultEnv struct {
+	VaultAddr  string `json:"vault_addr" mapstructure:"vault_addr"`
+	VaultToken string `json:"vault_token" mapstructure:"vault_token" secret:"true"`
+}
+
+func (e VaultEnv) VaultAddrValue() string {
+	return e.VaultAddr
+}
+
+func (e VaultEnv) VaultTokenValue() string {
+	return e.VaultToken
+}
+
+var _ VaultConfig = (*VaultEnv)(nil)
+
+// VaultSecretResolver resolve "vault:<path>#<key>" references by reading secrets from HashiCorp Vault.
+// Both KV version 1 and version 2 secret engines are supported, for version 2 the path must include the data
+// segment, for example "vault:secret/data/myapp#db_password".
+type VaultSecretResolver struct {
+	config VaultConfig
+	client *http.Client
+}
+
+type VaultSecretResolverParams struct {
+	fx.In
+	Config VaultConfig `optional:"true"`
+}
+
+// NewVaultSecretResolver create a vault secret resolver.
+// The vault config is read lazily, so it can be loaded from the same config file as other values.
+func NewVaultSecretResolver(p VaultSecretResolverParams) *VaultSecretResolver {
+	return &VaultSecretResolver{
+		config: p.Config,
+		client: &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (r *VaultSecretResolver) SecretScheme() string {
+	return "vault"
+}
+
+func (r *VaultSecretResolver) ResolveSecret(ctx context.Context, ref string) (string, error) {
+	path, key, ok := strings.Cut(ref, "#")
+	if !ok || path == "" || key == "" {
+		return "", fmt.Errorf("error invalid vault reference [%s], expected <path>#<key>", ref)
+	}
+
+	addr, token := os.Getenv("VAULT_ADDR"), os.Getenv("VAULT_TOKEN")
+	if r.config != nil {
+		if r.config.VaultAddrValue() != "" {
+			addr = r.config.VaultAddrValue()
+		}
+		if r.config.VaultTokenValue() != "" {
+			token = r.config.VaultTokenValue()
+		}
+	}
+	if addr == "" {
+		return "", errors.New("error vault address is not configured")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		strings.TrimSuffix(addr, "/")+"/v1/"+strings.TrimPrefix(path, "/"), nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("X-Vault-Token", token)
+	res, err := r.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("error reading vault secret [%s]: status %d", path, res.StatusCode)
+	}
+
+	var body struct {
+		Data map[string]any `json:"data"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return "", err
+	}
+	data := body.Data
+	// KV version 2 wrap secret data inside another data field.
+	if nested, ok := data["data"].(map[string]any); ok {
+		if _, hasMetadata := data["metadata"]; hasMetadata {
+			data = nested
+		}
+	}
+	value, ok := data[key]
+	if !ok {
+		return "", fmt.Errorf("error vault secret [%s] does not contain key [%s]", path, key)
+	}
+	if s, ok := value.(string); ok {
+		return s, nil
+	}
+	return fmt.Sprint(value), nil
+}
+
+var _ SecretResolver = (*VaultSecretResolver)(nil)
+
+// NewVaultModule resolve "vault:<path>#<key>" config values from HashiCorp Vault.
+// Must be used together with NewModule.
+// The env config can register as VaultConfig to configure vault address and token,
+// otherwise VAULT_ADDR and VAULT_TOKEN env are used.
+func NewVaultModule() fx.Option {
+	return fx.Module("corefx.vault",
+		fx.Provide(AsSecretResolver(NewVaultSecretResolver)),
+	)
+}
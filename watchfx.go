@@ -0,0 +1,431 @@
+package corefx
+
+import (
+	"context"
+	"errors"
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/viper"
+	"go.uber.org/fx"
+	"log/slog"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+// configWatchDelay delay between the last config file change event and the reload.
+const configWatchDelay = 100 * time.Millisecond
+
+// kubernetesDataLink symlink swapped by Kubernetes when updating mounted ConfigMap and Secret volumes.
+const kubernetesDataLink = "..data"
+
+// ConfigChangeListener listener that get notified after config is reloaded.
+// The old config is the config before reloading, the new config is the reloaded config, both must not be modified.
+type ConfigChangeListener func(old CoreConfig, new CoreConfig)
+
+// AsConfigChangeListener annotate a ConfigChangeListener constructor to register it into the config change listener group.
+// For example, fx.Provide(corefx.AsConfigChangeListener(newMyListener)).
+func AsConfigChangeListener(f any) any {
+	return fx.Annotate(
+		f,
+		fx.ResultTags(`group:"config_change_listeners"`),
+	)
+}
+
+// ConfigWatcher reload config at runtime and notify registered listeners.
+// The config is reloaded into a new config object, which is published atomically, the injected config object is never
+// modified, so it can be read from any goroutine. Components that need the reloaded values should read the current
+// config using Config, or register a ConfigChangeListener.
+type ConfigWatcher struct {
+	mu sync.Mutex
+	// config current config, replaced but never modified when reloading.
+	config    CoreConfig
+	defaults  CoreConfig
+	params    LoadJSONConfigParams
+	listeners []ConfigChangeListener
+	watcher   *fsnotify.Watcher
+	cancel    context.CancelFunc
+	viper     *viper.Viper
+	origins   *configOrigins
+	// logger of the app, set when watching starts.
+	logger *slog.Logger
+}
+
+// NewConfigWatcher create a config watcher.
+// The watcher is initialized when the config is loaded by the corefx module.
+func NewConfigWatcher() *ConfigWatcher {
+	return &ConfigWatcher{}
+}
+
+// OnConfigChange register a listener that get notified after config is reloaded.
+func (w *ConfigWatcher) OnConfigChange(listener ConfigChangeListener) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.listeners = append(w.listeners, listener)
+}
+
+// load config and keep a copy of the unloaded config to be used as defaults when reloading.
+func (w *ConfigWatcher) load(p LoadJSONConfigParams) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.defaults = copyConfig(p.Config)
+	w.params = p
+	origins := newConfigOrigins()
+	v, err := loadJSONConfigViper(p, origins)
+	if err != nil {
+		return err
+	}
+	w.config = p.Config
+	w.viper = v
+	w.origins = origins
+	return nil
+}
+
+// Config return the current config, which is the injected config object until the config is reloaded,
+// or nil if the config is not loaded. The returned config must not be modified.
+func (w *ConfigWatcher) Config() CoreConfig {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.config
+}
+
+// Viper return the viper instance used to load the current config, nil if the config is not loaded.
+func (w *ConfigWatcher) Viper() *viper.Viper {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.viper
+}
+
+// ConfigReport return the effective config values and where they came from, such as defaults, config files, remote
+// config, env or flags, sorted by key. Secret values are masked.
+func (w *ConfigWatcher) ConfigReport() ([]ConfigValueOrigin, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.config == nil {
+		return nil, errors.New("error config is not loaded")
+	}
+	return w.origins.report(w.config)
+}
+
+// Reload reload config from config locations into a new config object, publish it as the current config, see Config,
+// and notify listeners. If reloading failed, the current config is kept.
+func (w *ConfigWatcher) Reload() error {
+	w.mu.Lock()
+	old, err := w.reload()
+	config, listeners := w.config, w.listeners
+	w.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	for _, listener := range listeners {
+		listener(old, config)
+	}
+	return nil
+}
+
+// reload config and return the old config.
+func (w *ConfigWatcher) reload() (CoreConfig, error) {
+	if w.config == nil {
+		return nil, errors.New("error config is not loaded")
+	}
+	if reflect.ValueOf(w.config).Kind() != reflect.Pointer {
+		return nil, errors.New("error config reloading require a pointer to config struct")
+	}
+
+	fresh := copyConfig(w.defaults)
+	p := w.params
+	p.Config = fresh
+	origins := newConfigOrigins()
+	v, err := loadJSONConfigViper(p, origins)
+	if err != nil {
+		return nil, err
+	}
+	old := w.config
+	w.config = fresh
+	w.viper = v
+	w.origins = origins
+	return old, nil
+}
+
+// start watching config files and remote config for changes.
+func (w *ConfigWatcher) start(logger *slog.Logger) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.logger = logger
+	locations, err := configLocationsOf(w.config)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	w.cancel = cancel
+	for _, location := range locations {
+		if !isRemoteLocation(location) {
+			continue
+		}
+		go watchRemoteConfig(ctx, logger, w.config, location, func() {
+			w.reloadAndLog(location)
+		})
+	}
+
+	files, err := watchedFilesOf(w.config, locations)
+	if err != nil {
+		return err
+	}
+	sourceDirs, err := watchedDirsOf(locations)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 && len(sourceDirs) == 0 {
+		return nil
+	}
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	// Watch the directories instead of the files, so we can handle files that are replaced or created later.
+	dirs := make(map[string]struct{}, len(files)+len(sourceDirs))
+	for file := range files {
+		dirs[filepath.Dir(file)] = struct{}{}
+	}
+	for dir := range sourceDirs {
+		dirs[dir] = struct{}{}
+	}
+	for dir := range dirs {
+		if err := watcher.Add(dir); err != nil {
+			logger.Warn("Cannot watch config dir", slog.String("dir", dir), slog.Any("err", err))
+		}
+	}
+	w.watcher = watcher
+
+	go func() {
+		var timer *time.Timer
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if !isConfigChangeEvent(event, files, sourceDirs) {
+					continue
+				}
+				// Editors and file writes usually produce multiple events, wait for them to settle before reloading.
+				if timer != nil {
+					timer.Stop()
+				}
+				name := event.Name
+				timer = time.AfterFunc(configWatchDelay, func() {
+					w.reloadAndLog(name)
+				})
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				logger.Warn("Error watching config", slog.Any("err", err))
+			}
+		}
+	}()
+	return nil
+}
+
+// reloadAndLog reload config triggered by a change of source and log the result.
+func (w *ConfigWatcher) reloadAndLog(source string) {
+	if err := w.Reload(); err != nil {
+		w.logger.Error("Error reloading config", slog.String("source", source), slog.Any("err", err))
+		return
+	}
+	w.logger.Info("Config reloaded", slog.String("source", source))
+}
+
+// stop watching config files and remote config.
+func (w *ConfigWatcher) stop() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.cancel != nil {
+		w.cancel()
+		w.cancel = nil
+	}
+	if w.watcher == nil {
+		return nil
+	}
+	err := w.watcher.Close()
+	w.watcher = nil
+	return err
+}
+
+type registerConfigChangeListenersParams struct {
+	fx.In
+	Watcher   *ConfigWatcher
+	Listeners []ConfigChangeListener `group:"config_change_listeners"`
+}
+
+// registerConfigChangeListeners register all listeners in the config change listener group into the watcher.
+func registerConfigChangeListeners(p registerConfigChangeListenersParams) {
+	for _, listener := range p.Listeners {
+		if listener != nil {
+			p.Watcher.OnConfigChange(listener)
+		}
+	}
+}
+
+// WithConfigWatch watch config files and remote config, reload config automatically when they are changed.
+// Must be used together with NewModule.
+// Registered ConfigChangeListener are notified after each reload, log level is re-applied automatically.
+func WithConfigWatch() fx.Option {
+	return fx.Module("corefx.watch",
+		fx.Invoke(func(lc fx.Lifecycle, w *ConfigWatcher, logger *slog.Logger) {
+			lc.Append(fx.Hook{
+				OnStart: func(_ context.Context) error {
+					return w.start(logger)
+				},
+				OnStop: func(_ context.Context) error {
+					return w.stop()
+				},
+			})
+		}),
+	)
+}
+
+// watchedFilesOf return the absolute path of config files that should be watched, including profile specific files.
+func watchedFilesOf(cfg CoreConfig, locations []string) (map[string]struct{}, error) {
+	if profile := cfg.ProfileValue(); profile != "" {
+		locations = append(locations[:len(locations):len(locations)], profileLocationsOf(locations, profile)...)
+	}
+
+	files := make(map[string]struct{}, len(locations))
+	for _, location := range locations {
+		if !strings.HasPrefix(location, "file:") {
+			continue
+		}
+		path, err := filepath.Abs(location[5:])
+		if err != nil {
+			return nil, err
+		}
+		files[path] = struct{}{}
+		for _, included := range includedFilesOf(path) {
+			files[filepath.Clean(included)] = struct{}{}
+		}
+	}
+	return files, nil
+}
+
+// watchedDirsOf return the absolute path of config directories that should be watched.
+func watchedDirsOf(locations []string) (map[string]struct{}, error) {
+	dirs := make(map[string]struct{}, len(locations))
+	for _, location := range locations {
+		if !strings.HasPrefix(location, "dir:") {
+			continue
+		}
+		path, err := filepath.Abs(location[4:])
+		if err != nil {
+			return nil, err
+		}
+		dirs[path] = struct{}{}
+	}
+	return dirs, nil
+}
+
+// isConfigChangeEvent whether the event change a watched config file or a file of a watched config directory.
+// Kubernetes update mounted ConfigMap and Secret by atomically swapping the ..data symlink, which is handled as a
+// change of all files in its directory.
+func isConfigChangeEvent(event fsnotify.Event, files map[string]struct{}, dirs map[string]struct{}) bool {
+	name := filepath.Clean(event.Name)
+	base := filepath.Base(name)
+	if base == kubernetesDataLink {
+		if _, ok := dirs[filepath.Dir(name)]; ok {
+			return true
+		}
+		for file := range files {
+			if filepath.Dir(file) == filepath.Dir(name) {
+				return true
+			}
+		}
+		return false
+	}
+	if _, ok := files[name]; ok {
+		return event.Has(fsnotify.Write) || event.Has(fsnotify.Create)
+	}
+	if _, ok := dirs[filepath.Dir(name)]; ok && !strings.HasPrefix(base, ".") {
+		return event.Op != fsnotify.Chmod
+	}
+	return false
+}
+
+// copyConfig create a deep copy of config object, so maps, slices and pointers of the copy are not shared.
+// Non-pointer configs are returned as is.
+func copyConfig(cfg CoreConfig) CoreConfig {
+	v := reflect.ValueOf(cfg)
+	if v.Kind() != reflect.Pointer || v.IsNil() {
+		return cfg
+	}
+	return deepCopy(v, map[copiedPointer]reflect.Value{}).Interface().(CoreConfig)
+}
+
+// copiedPointer a pointer copied by deepCopy.
+type copiedPointer struct {
+	typ  reflect.Type
+	addr uintptr
+}
+
+// deepCopy copy v and the values it references, copied keep the copies of pointers, so shared and cyclic pointers
+// are copied once. Unexported fields, functions and channels are copied shallowly.
+func deepCopy(v reflect.Value, copied map[copiedPointer]reflect.Value) reflect.Value {
+	switch v.Kind() {
+	case reflect.Pointer:
+		if v.IsNil() {
+			return v
+		}
+		key := copiedPointer{typ: v.Type(), addr: v.Pointer()}
+		if cp, ok := copied[key]; ok {
+			return cp
+		}
+		cp := reflect.New(v.Elem().Type())
+		copied[key] = cp
+		cp.Elem().Set(deepCopy(v.Elem(), copied))
+		return cp
+	case reflect.Interface:
+		if v.IsNil() {
+			return v
+		}
+		cp := reflect.New(v.Type()).Elem()
+		cp.Set(deepCopy(v.Elem(), copied))
+		return cp
+	case reflect.Map:
+		if v.IsNil() {
+			return v
+		}
+		cp := reflect.MakeMapWithSize(v.Type(), v.Len())
+		iter := v.MapRange()
+		for iter.Next() {
+			cp.SetMapIndex(deepCopy(iter.Key(), copied), deepCopy(iter.Value(), copied))
+		}
+		return cp
+	case reflect.Slice:
+		if v.IsNil() {
+			return v
+		}
+		cp := reflect.MakeSlice(v.Type(), v.Len(), v.Len())
+		for i := 0; i < v.Len(); i++ {
+			cp.Index(i).Set(deepCopy(v.Index(i), copied))
+		}
+		return cp
+	case reflect.Array:
+		cp := reflect.New(v.Type()).Elem()
+		for i := 0; i < v.Len(); i++ {
+			cp.Index(i).Set(deepCopy(v.Index(i), copied))
+		}
+		return cp
+	case reflect.Struct:
+		cp := reflect.New(v.Type()).Elem()
+		cp.Set(v)
+		for i := 0; i < v.NumField(); i++ {
+			if cp.Field(i).CanSet() {
+				cp.Field(i).Set(deepCopy(v.Field(i), copied))
+			}
+		}
+		return cp
+	default:
+		return v
+	}
+}
@@ -0,0 +1,341 @@
+package corefx
+
+import (
+	"context"
+	"fmt"
+	"go.uber.org/fx"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+const (
+	// WorkerRestartOnFailure restart workers that returned an error or panicked.
+	WorkerRestartOnFailure = "on-failure"
+	// WorkerRestartAlways restart workers whenever they return, until the app stops.
+	WorkerRestartAlways = "always"
+	// WorkerRestartNever never restart workers.
+	WorkerRestartNever = "never"
+)
+
+const (
+	WorkerStatusPending = "pending"
+	WorkerStatusRunning = "running"
+	// WorkerStatusStandby the worker is LeaderOnly and waiting for this instance to become the leader.
+	WorkerStatusStandby = "standby"
+	// WorkerStatusBackoff the worker returned and is waiting to be restarted.
+	WorkerStatusBackoff = "backoff"
+	// WorkerStatusStopped the worker returned without error, or was cancelled when the app stopped.
+	WorkerStatusStopped = "stopped"
+	// WorkerStatusFailed the worker returned an error or panicked, and is not restarted.
+	WorkerStatusFailed = "failed"
+)
+
+type WorkerConfig interface {
+	// WorkerRestartPolicyValue when workers are restarted after returning:
+	// "on-failure" (on error or panic), "always" or "never".
+	WorkerRestartPolicyValue() string
+	// WorkerBackoffMinValue delay before restarting a worker, doubled after each consecutive failure. Must be positive.
+	WorkerBackoffMinValue() time.Duration
+	// WorkerBackoffMaxValue max delay before restarting a worker, must not be less than the min delay.
+	// The backoff is reset when the worker ran for longer than this delay.
+	WorkerBackoffMaxValue() time.Duration
+}
+
+type WorkerEnv struct {
+	WorkerRestartPolicy string        `json:"worker_restart_policy" mapstructure:"worker_restart_policy" default:"on-failure"`
+	WorkerBackoffMin    time.Duration `json:"worker_backoff_min" mapstructure:"worker_backoff_min" default:"1s"`
+	WorkerBackoffMax    time.Duration `json:"worker_backoff_max" mapstructure:"worker_backoff_max" default:"1m"`
+}
+
+func (e WorkerEnv) WorkerRestartPolicyValue() string {
+	return e.WorkerRestartPolicy
+}
+
+func (e WorkerEnv) WorkerBackoffMinValue() time.Duration {
+	return e.WorkerBackoffMin
+}
+
+func (e WorkerEnv) WorkerBackoffMaxValue() time.Duration {
+	return e.WorkerBackoffMax
+}
+
+var _ WorkerConfig = (*WorkerEnv)(nil)
+
+// Worker a long-running background task managed by the WorkerManager.
+// Run must return when ctx is cancelled.
+type Worker interface {
+	Run(ctx context.Context) error
+}
+
+// WorkerFunc adapter to use a function as a Worker.
+type WorkerFunc func(ctx context.Context) error
+
+func (f WorkerFunc) Run(ctx context.Context) error {
+	return f(ctx)
+}
+
+// NamedWorker optional interface that Worker can implement to name itself in logs and WorkerState.
+// Default to the type name of the worker.
+type NamedWorker interface {
+	WorkerName() string
+}
+
+// AsWorker annotate a Worker constructor to register it into the worker group.
+// For example, fx.Provide(corefx.AsWorker(newOutboxRelay)).
+func AsWorker(f any) any {
+	return fx.Annotate(
+		f,
+		fx.As(new(Worker)),
+		fx.ResultTags(`group:"workers"`),
+	)
+}
+
+// WorkerState state of a worker managed by the WorkerManager.
+type WorkerState struct {
+	Name   string `json:"name"`
+	Status string `json:"status"`
+	// Restarts number of times the worker was restarted.
+	Restarts int `json:"restarts"`
+	// LastError error of the last failed run, empty if the worker never failed.
+	LastError string `json:"last_error,omitempty"`
+	// Since time of the last status change.
+	Since time.Time `json:"since"`
+}
+
+// WorkerManager run the registered workers in background goroutines, restarting them according to the WorkerConfig.
+type WorkerManager struct {
+	config  WorkerConfig
+	elector LeaderElector
+	workers []Worker
+	logger  *slog.Logger
+	clock   Clock
+
+	mu     sync.Mutex
+	states []WorkerState
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+type WorkerManagerParams struct {
+	fx.In
+	Loaded    loadedConfig
+	Config    WorkerConfig  `optional:"true"`
+	Workers   []Worker      `group:"workers"`
+	Elector   LeaderElector `optional:"true"`
+	Logger    *slog.Logger
+	Clock     Clock
+	Lifecycle fx.Lifecycle
+}
+
+// NewWorkerManager create a worker manager, which start workers when the app starts, after the components they depend
+// on, and cancel them when the app stops, waiting for them to return.
+// Workers returning an error or panicking are logged and restarted with exponential backoff, according to the
+// WorkerConfig, or the WorkerEnv defaults if not registered.
+// Workers implementing LeaderOnly only run while this instance is the leader elected by the LeaderElector.
+func NewWorkerManager(p WorkerManagerParams) (*WorkerManager, error) {
+	config := p.Config
+	if config == nil {
+		env := WorkerEnv{}
+		if err := applyDefaults(&env); err != nil {
+			return nil, err
+		}
+		config = env
+	}
+	switch config.WorkerRestartPolicyValue() {
+	case WorkerRestartOnFailure, WorkerRestartAlways, WorkerRestartNever:
+	default:
+		return nil, fmt.Errorf("error unsupported worker restart policy [%s]", config.WorkerRestartPolicyValue())
+	}
+	if config.WorkerBackoffMinValue() <= 0 || config.WorkerBackoffMaxValue() < config.WorkerBackoffMinValue() {
+		return nil, fmt.Errorf("error invalid worker backoff [%s, %s], min must be positive and not greater than max",
+			config.WorkerBackoffMinValue(), config.WorkerBackoffMaxValue())
+	}
+
+	m := &WorkerManager{
+		config:  config,
+		elector: p.Elector,
+		workers: p.Workers,
+		logger:  p.Logger,
+		clock:   p.Clock,
+		states:  make([]WorkerState, len(p.Workers)),
+	}
+	now := p.Clock.Now()
+	for i, worker := range p.Workers {
+		m.states[i] = WorkerState{Name: workerNameOf(worker), Status: WorkerStatusPending, Since: now}
+		if isLeaderOnly(worker) && p.Elector == nil {
+			return nil, fmt.Errorf("error worker [%s] is leader-only, but no LeaderElector is provided", m.states[i].Name)
+		}
+	}
+	p.Lifecycle.Append(fx.Hook{
+		OnStart: m.start,
+		OnStop:  m.stop,
+	})
+	return m, nil
+}
+
+// States return a snapshot of the state of workers, in registration order.
+func (m *WorkerManager) States() []WorkerState {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	states := make([]WorkerState, len(m.states))
+	copy(states, m.states)
+	return states
+}
+
+func (m *WorkerManager) start(_ context.Context) error {
+	// Workers outlive the start context.
+	ctx, cancel := context.WithCancel(context.Background())
+	m.cancel = cancel
+	for i := range m.workers {
+		m.wg.Add(1)
+		go m.run(ctx, i)
+	}
+	if len(m.workers) > 0 {
+		m.logger.Info("Started workers", slog.Int("workers", len(m.workers)))
+	}
+	return nil
+}
+
+func (m *WorkerManager) stop(ctx context.Context) error {
+	m.cancel()
+	done := make(chan struct{})
+	go func() {
+		m.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("error waiting for workers to stop: %w", ctx.Err())
+	}
+}
+
+// run run the worker at index i until ctx is cancelled or the restart policy stop it.
+func (m *WorkerManager) run(ctx context.Context, i int) {
+	defer m.wg.Done()
+	worker := m.workers[i]
+	logger := m.logger.With(slog.String("worker", m.states[i].Name))
+	failures := 0
+	for {
+		runCtx, cancel := ctx, context.CancelFunc(func() {})
+		if isLeaderOnly(worker) {
+			m.update(i, func(s *WorkerState) {
+				s.Status = WorkerStatusStandby
+			})
+			var err error
+			if runCtx, cancel, err = m.elector.AwaitLeadership(ctx); err != nil {
+				m.update(i, func(s *WorkerState) {
+					s.Status = WorkerStatusStopped
+				})
+				return
+			}
+		}
+		m.update(i, func(s *WorkerState) {
+			s.Status = WorkerStatusRunning
+		})
+		start := m.clock.Now()
+		err := runRecovered(runCtx, worker.Run)
+		lostLeadership := runCtx.Err() != nil
+		cancel()
+		if ctx.Err() != nil {
+			m.update(i, func(s *WorkerState) {
+				s.Status = WorkerStatusStopped
+			})
+			return
+		}
+		if lostLeadership {
+			logger.Info("Worker cancelled, leadership lost")
+			failures = 0
+			continue
+		}
+
+		policy := m.config.WorkerRestartPolicyValue()
+		if err == nil {
+			if policy != WorkerRestartAlways {
+				logger.Info("Worker completed")
+				m.update(i, func(s *WorkerState) {
+					s.Status = WorkerStatusStopped
+				})
+				return
+			}
+			failures = 0
+		} else {
+			logger.Error("Worker failed", slog.Any("err", err))
+			if policy == WorkerRestartNever {
+				m.update(i, func(s *WorkerState) {
+					s.Status = WorkerStatusFailed
+					s.LastError = err.Error()
+				})
+				return
+			}
+			if m.clock.Now().Sub(start) >= m.config.WorkerBackoffMaxValue() {
+				failures = 0
+			}
+			failures++
+		}
+
+		backoff := workerBackoffOf(m.config, failures)
+		m.update(i, func(s *WorkerState) {
+			s.Status = WorkerStatusBackoff
+			if err != nil {
+				s.LastError = err.Error()
+			}
+		})
+		logger.Info("Restarting worker", slog.Duration("backoff", backoff))
+		select {
+		case <-ctx.Done():
+			m.update(i, func(s *WorkerState) {
+				s.Status = WorkerStatusStopped
+			})
+			return
+		case <-m.clock.After(backoff):
+		}
+		m.update(i, func(s *WorkerState) {
+			s.Restarts++
+		})
+	}
+}
+
+// update update the state of the worker at index i.
+func (m *WorkerManager) update(i int, f func(s *WorkerState)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	status := m.states[i].Status
+	f(&m.states[i])
+	if m.states[i].Status != status {
+		m.states[i].Since = m.clock.Now()
+	}
+}
+
+// workerBackoffOf return the delay before restarting a worker after consecutive failures,
+// doubling from the min backoff up to the max backoff.
+func workerBackoffOf(config WorkerConfig, failures int) time.Duration {
+	backoff := config.WorkerBackoffMinValue()
+	for i := 1; i < failures && backoff < config.WorkerBackoffMaxValue(); i++ {
+		backoff *= 2
+	}
+	return min(backoff, config.WorkerBackoffMaxValue())
+}
+
+// workerNameOf return the name of the worker.
+func workerNameOf(worker Worker) string {
+	if named, ok := worker.(NamedWorker); ok {
+		return named.WorkerName()
+	}
+	return fmt.Sprintf("%T", worker)
+}
+
+// NewWorkerModule run the workers registered using AsWorker with the app lifecycle, and provide the *WorkerManager
+// to inspect their states. Must be used together with NewModule.
+// The env config can register as WorkerConfig to configure the restart policy, otherwise the WorkerEnv defaults are
+// used.
+func NewWorkerModule() fx.Option {
+	return fx.Module("corefx.worker",
+		fx.Provide(NewWorkerManager),
+		fx.Invoke(func(_ *WorkerManager) {
+			// force initialization of the manager.
+		}),
+	)
+}